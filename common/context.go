@@ -0,0 +1,60 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// InterruptContext returns a context that is cancelled when the process is interrupted (for example, by pressing
+// Ctrl-C) and a function that stops handling interrupts and cancels the context, which must be called once the context
+// is no longer needed. The first interrupt writes a message to stdout and only cancels the context, which allows the
+// current repository to be finished or cleanly abandoned and a summary of the repositories processed so far to be
+// printed. A second interrupt terminates the process immediately.
+func InterruptContext(stdout io.Writer) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the CLI framework exits as soon as it is interrupted, so its handler is removed
+	signal.Reset(os.Interrupt)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			// once no channel is notified, the default behavior of terminating the process is restored
+			signal.Stop(signals)
+			fmt.Fprintln(stdout)
+			fmt.Fprintln(stdout, "Interrupted: finishing the current step (press Ctrl-C again to exit immediately)...")
+			cancel()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancel()
+		})
+	}
+}
+
+// OperationContext returns a context for the processing of a single repository that is done when the provided context
+// is done or, if timeout is positive, once timeout has elapsed.
+func OperationContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
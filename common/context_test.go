@@ -0,0 +1,51 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package common_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/common"
+)
+
+func TestInterruptContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx, stop := common.InterruptContext(buf)
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skipf("interrupt cannot be sent on this platform: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "context was not cancelled by the interrupt")
+	}
+	assert.Equal(t, context.Canceled, ctx.Err())
+	assert.Equal(t, "\nInterrupted: finishing the current step (press Ctrl-C again to exit immediately)...\n", buf.String())
+}
+
+func TestOperationContext(t *testing.T) {
+	ctx, cancel := common.OperationContext(context.Background(), 0)
+	_, hasDeadline := ctx.Deadline()
+	assert.False(t, hasDeadline)
+	cancel()
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	ctx, cancel = common.OperationContext(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+}
@@ -5,6 +5,13 @@
 package common
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/google/go-github/github"
 	"github.com/gregjones/httpcache"
 	"github.com/gregjones/httpcache/diskcache"
@@ -20,7 +27,14 @@ const (
 	GitHubTokenFlagName     = "github-token"
 	CopyrightAuthorFlagName = "author"
 	cacheDirFlagName        = "cache-dir"
+	DryRunFlagName          = "dry-run"
+	InfoAPIFlagName         = "info-api"
+	maxRateLimitWaitName    = "max-rate-limit-wait"
+	maxRetriesFlagName      = "max-retries"
 	organizationFlagName    = "organization"
+	parallelismFlagName     = "parallelism"
+	retryBackoffFlagName    = "retry-backoff"
+	timeoutFlagName         = "timeout"
 	userFlagName            = "user"
 )
 
@@ -37,19 +51,58 @@ var (
 		Name:  cacheDirFlagName,
 		Usage: "directory in which to cache GitHub API responses (if absent, an in-memory cache is used instead)",
 	}
+	DryRunFlag = flag.BoolFlag{
+		Name:  DryRunFlagName,
+		Usage: "print the changes that would be made without making them (requests that could modify state are rejected)",
+	}
+	InfoAPIFlag = flag.StringFlag{
+		Name:  InfoAPIFlagName,
+		Usage: `API used to retrieve the information about repositories: "graphql" retrieves most of it for a page of repositories at once, "rest" retrieves it one repository at a time (and works with all tokens and GitHub Enterprise versions) and "auto" uses "graphql" if a token is specified and "rest" otherwise`,
+		Value: infoAPIREST,
+	}
 	organizationFlag = flag.StringFlag{
 		Name:  organizationFlagName,
-		Usage: "GitHub organization for which repositories are resolved",
+		Usage: "GitHub organization for which repositories are resolved (can be repeated or be a comma-separated list to resolve the repositories of multiple organizations)",
 	}
 	userFlag = flag.StringFlag{
 		Name:  userFlagName,
 		Usage: "GitHub user for which repositories are resolved",
 	}
+	parallelismFlag = flag.StringFlag{
+		Name:  parallelismFlagName,
+		Usage: "maximum number of repositories that are processed concurrently",
+		Value: "1",
+	}
+	maxRetriesFlag = flag.StringFlag{
+		Name:  maxRetriesFlagName,
+		Usage: "maximum number of times that a GitHub API request that fails because of a rate limit or a server error is retried",
+		Value: strconv.Itoa(defaultMaxRetries),
+	}
+	retryBackoffFlag = flag.DurationFlag{
+		Name:  retryBackoffFlagName,
+		Usage: "delay before the first retry of a GitHub API request that fails because of a server error (doubled for every retry)",
+		Value: defaultRetryBackoff.String(),
+	}
+	maxRateLimitWaitFlag = flag.DurationFlag{
+		Name:  maxRateLimitWaitName,
+		Usage: "maximum time to wait for a GitHub API rate limit to reset (0 to never wait)",
+		Value: defaultMaxRateLimitWait.String(),
+	}
+	timeoutFlag = flag.DurationFlag{
+		Name:  timeoutFlagName,
+		Usage: "maximum time to spend on a single repository before its processing is abandoned (0 for no limit)",
+		Value: "0s",
+	}
 	AllFlags = []flag.Flag{
 		GitHubTokenFlag,
 		cacheDirFlag,
 		organizationFlag,
 		userFlag,
+		parallelismFlag,
+		maxRetriesFlag,
+		retryBackoffFlag,
+		maxRateLimitWaitFlag,
+		timeoutFlag,
 		CopyrightAuthorFlag,
 	}
 	RepositoryFlags = []flag.Flag{
@@ -57,18 +110,42 @@ var (
 		cacheDirFlag,
 		organizationFlag,
 		userFlag,
+		parallelismFlag,
+		maxRetriesFlag,
+		retryBackoffFlag,
+		maxRateLimitWaitFlag,
+		timeoutFlag,
 	}
 )
 
+// values of the info API flag
+const (
+	infoAPIAuto    = "auto"
+	infoAPIGraphQL = "graphql"
+	infoAPIREST    = "rest"
+)
+
 type GitHubParams interface {
 	Token() string
 	CacheDir() string
+	// DryRun returns true if the dry run flag is specified. If true, the clients returned by CachingOAuthGitHubClient
+	// reject requests that could modify state.
+	DryRun() bool
 	CachingOAuthGitHubClient() *github.Client
+	// InfoFetcher returns the repository.InfoFetcher that uses the provided client and the API specified by the info API
+	// flag.
+	InfoFetcher(client *github.Client) repository.InfoFetcher
+	// Timeout returns the maximum time to spend on a single repository (0 if there is no limit).
+	Timeout() time.Duration
 }
 
 type gitHubParams struct {
-	token    string
-	cacheDir string
+	token     string
+	cacheDir  string
+	dryRun    bool
+	infoAPI   string
+	timeout   time.Duration
+	rateLimit RateLimitTransport // configuration of the transport used by the clients
 }
 
 func (p *gitHubParams) Token() string {
@@ -79,47 +156,138 @@ func (p *gitHubParams) CacheDir() string {
 	return p.cacheDir
 }
 
+func (p *gitHubParams) DryRun() bool {
+	return p.dryRun
+}
+
+func (p *gitHubParams) Timeout() time.Duration {
+	return p.timeout
+}
+
+func (p *gitHubParams) InfoFetcher(client *github.Client) repository.InfoFetcher {
+	if p.infoAPI == infoAPIGraphQL || (p.infoAPI == infoAPIAuto && p.token != "") {
+		return repository.NewGraphQLInfoFetcher(client)
+	}
+	return repository.NewRESTInfoFetcher(client)
+}
+
 type GitHubRepositoryParams interface {
 	GitHubParams
-	RepositoryKey() string
-	ProcessRepos(client *github.Client, repos []string, f repository.ProcessFunc) error
+	// Owners returns the users or organizations whose repositories are processed.
+	Owners() []string
+	// Parallelism returns the maximum number of repositories that ProcessRepos processes concurrently.
+	Parallelism() int
+	// ProcessRepos runs the provided function for the provided repositories or, if repos is empty, for all of the
+	// repositories of the owners. Repositories are specified by name if there is only one owner and must otherwise be
+	// specified as "owner/repo". Up to Parallelism repositories are processed concurrently (see repository.Pool): the
+	// output that the function writes for each repository is written to stdout in the order in which the repositories
+	// are listed, and processing stops at the first repository for which the function returns an error (repositories
+	// that are already being processed are finished and their output is written). If fetcher is non-nil, the
+	// repositories are passed to the function using its ProcessFunc, so the function should use it to retrieve the Info
+	// of the repositories. The function is provided a context that is done when the provided context is done or when the
+	// timeout for the repository has elapsed (see OperationContext). Once the provided context is done, no further
+	// repositories are processed and the error of the context is returned.
+	ProcessRepos(ctx context.Context, client *github.Client, fetcher repository.InfoFetcher, repos []string, stdout io.Writer, f repository.OutputProcessFunc) error
+}
+
+type ownerType int
+
+const (
+	unknownOwner ownerType = iota // owner is a user or an organization (determined using the GitHub API)
+	userOwner
+	organizationOwner
+)
+
+type repositoryOwner struct {
+	name string
+	typ  ownerType
 }
 
 type gitHubRepositoryParams struct {
 	GitHubParams
-	user         string
-	organization string
+	owners      []repositoryOwner
+	parallelism int
+}
+
+func (p *gitHubRepositoryParams) Parallelism() int {
+	return p.parallelism
 }
 
-func (p *gitHubRepositoryParams) RepositoryKey() string {
-	if p.organization != "" {
-		return p.organization
+func (p *gitHubRepositoryParams) Owners() []string {
+	var owners []string
+	for _, owner := range p.owners {
+		owners = append(owners, owner.name)
 	}
-	return p.user
+	return owners
 }
 
-func (p *gitHubRepositoryParams) ProcessRepos(client *github.Client, repos []string, f repository.ProcessFunc) error {
+func (p *gitHubRepositoryParams) ProcessRepos(ctx context.Context, client *github.Client, fetcher repository.InfoFetcher, repos []string, stdout io.Writer, f repository.OutputProcessFunc) error {
+	timeout := p.Timeout()
+	processRepo := func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
+		ctx, cancel := OperationContext(ctx, timeout)
+		defer cancel()
+		return f(ctx, repo, progress, stdout)
+	}
+	processFunc := func(pool *repository.Pool) repository.ProcessFunc {
+		if fetcher == nil {
+			return pool.ProcessFunc(processRepo)
+		}
+		return fetcher.ProcessFunc(pool.ProcessFunc(processRepo))
+	}
+
 	// if provided list of repos is empty, process all
 	if len(repos) == 0 {
-		var err error
-		if p.organization != "" {
-			err = repository.ProcessOrgRepos(client, p.organization, f)
-		} else {
-			err = repository.ProcessUserRepos(client, p.user, f)
-		}
-		if err != nil {
-			return errors.Wrapf(err, "failed to retrieve repositories")
+		for _, owner := range p.owners {
+			pool := repository.NewPool(p.parallelism, stdout)
+			var err error
+			switch owner.typ {
+			case organizationOwner:
+				err = repository.ProcessOrgRepos(ctx, client, owner.name, processFunc(pool))
+			case userOwner:
+				err = repository.ProcessUserRepos(ctx, client, owner.name, processFunc(pool))
+			default:
+				err = repository.ProcessOwnerRepos(ctx, client, owner.name, processFunc(pool))
+			}
+			if poolErr := pool.Wait(); poolErr != nil {
+				// error of a repository takes precedence over errors that occurred while listing later repositories
+				err = poolErr
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to retrieve repositories for %s", owner.name)
+			}
 		}
 		return nil
 	}
 
 	// otherwise, process provided repositories
+	pool := repository.NewPool(p.parallelism, stdout)
+	if err := p.processNamedRepos(ctx, client, repos, processFunc(pool)); err != nil {
+		if poolErr := pool.Wait(); poolErr != nil {
+			return poolErr
+		}
+		return err
+	}
+	return pool.Wait()
+}
+
+func (p *gitHubRepositoryParams) processNamedRepos(ctx context.Context, client *github.Client, repos []string, f repository.ProcessFunc) error {
 	for i, currRepo := range repos {
-		repo, _, err := client.Repositories.Get(p.RepositoryKey(), currRepo)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		owner, name := "", currRepo
+		if idx := strings.Index(currRepo, "/"); idx != -1 {
+			owner, name = currRepo[:idx], currRepo[idx+1:]
+		} else if len(p.owners) == 1 {
+			owner = p.owners[0].name
+		} else {
+			return errors.Errorf(`repository %s must be specified as "owner/repo" when the repositories of multiple owners are processed`, currRepo)
+		}
+		repo, err := repository.GetRepository(ctx, client, owner, name)
 		if err != nil {
-			return errors.Wrapf(err, "failed to retrieve repository %s for %s", currRepo, p.RepositoryKey())
+			return errors.Wrapf(err, "failed to retrieve repository %s for %s", name, owner)
 		}
-		if err := f(repo, repository.Progress{
+		if err := f(ctx, repo, repository.Progress{
 			CurrPageRepo:     i,
 			CurrPageNumRepos: len(repos),
 		}); err != nil {
@@ -129,15 +297,59 @@ func (p *gitHubRepositoryParams) ProcessRepos(client *github.Client, repos []str
 	return nil
 }
 
-func NewGitHubParams(ctx cli.Context) GitHubParams {
+func NewGitHubParams(ctx cli.Context) (GitHubParams, error) {
 	var cacheDir string
 	if ctx.Has(cacheDirFlagName) {
 		cacheDir = ctx.String(cacheDirFlagName)
 	}
+	rateLimit := NewRateLimitTransport(ctx.App.Stdout)
+	if ctx.Has(maxRetriesFlagName) {
+		n, err := strconv.Atoi(ctx.String(maxRetriesFlagName))
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("--%s must be a non-negative integer, was %q", maxRetriesFlagName, ctx.String(maxRetriesFlagName))
+		}
+		rateLimit.MaxRetries = n
+	}
+	if ctx.Has(retryBackoffFlagName) {
+		rateLimit.Backoff = ctx.Duration(retryBackoffFlagName)
+	}
+	if ctx.Has(maxRateLimitWaitName) {
+		rateLimit.MaxWait = ctx.Duration(maxRateLimitWaitName)
+	}
+	var timeout time.Duration
+	if ctx.Has(timeoutFlagName) {
+		if timeout = ctx.Duration(timeoutFlagName); timeout < 0 {
+			return nil, errors.Errorf("--%s must not be negative, was %v", timeoutFlagName, timeout)
+		}
+	}
+	infoAPI := infoAPIREST
+	if ctx.Has(InfoAPIFlagName) {
+		switch infoAPI = ctx.String(InfoAPIFlagName); infoAPI {
+		case infoAPIAuto, infoAPIGraphQL, infoAPIREST:
+		default:
+			return nil, errors.Errorf(`--%s must be "%s", "%s" or "%s", was %q`, InfoAPIFlagName, infoAPIAuto, infoAPIGraphQL, infoAPIREST, infoAPI)
+		}
+	}
 	return &gitHubParams{
-		token:    ctx.String(GitHubTokenFlagName),
-		cacheDir: cacheDir,
+		token:     ctx.String(GitHubTokenFlagName),
+		cacheDir:  cacheDir,
+		dryRun:    ctx.Has(DryRunFlagName) && ctx.Bool(DryRunFlagName),
+		infoAPI:   infoAPI,
+		timeout:   timeout,
+		rateLimit: *rateLimit,
+	}, nil
+}
+
+// parallelism returns the value of the parallelism flag (1 if the flag is not specified).
+func parallelism(ctx cli.Context) (int, error) {
+	if !ctx.Has(parallelismFlagName) {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(ctx.String(parallelismFlagName))
+	if err != nil || n < 1 {
+		return 0, errors.Errorf("--%s must be a positive integer, was %q", parallelismFlagName, ctx.String(parallelismFlagName))
 	}
+	return n, nil
 }
 
 func NewGitHubRepositoryParams(ctx cli.Context) (GitHubRepositoryParams, error) {
@@ -147,18 +359,147 @@ func NewGitHubRepositoryParams(ctx cli.Context) (GitHubRepositoryParams, error)
 		return nil, errors.Errorf("user and organization cannot both be provided")
 	}
 
+	var owners []repositoryOwner
+	if ctx.String(userFlagName) != "" {
+		owners = append(owners, repositoryOwner{
+			name: ctx.String(userFlagName),
+			typ:  userOwner,
+		})
+	}
+	if ctx.String(organizationFlagName) != "" {
+		for _, org := range strings.Split(ctx.String(organizationFlagName), ",") {
+			owners = append(owners, repositoryOwner{
+				name: org,
+				typ:  organizationOwner,
+			})
+		}
+	}
+	params, err := NewGitHubParams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := parallelism(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &gitHubRepositoryParams{
-		GitHubParams: NewGitHubParams(ctx),
-		user:         ctx.String(userFlagName),
-		organization: ctx.String(organizationFlagName),
+		GitHubParams: params,
+		owners:       owners,
+		parallelism:  n,
 	}, nil
 }
 
+// HasOwnerFlags returns true if the user or organization flag is specified.
+func HasOwnerFlags(ctx cli.Context) bool {
+	return ctx.String(userFlagName) != "" || ctx.String(organizationFlagName) != ""
+}
+
+// NewGitHubOwnersParams returns the parameters for processing the repositories of the provided owners. Whether each
+// owner is a user or an organization is determined using the GitHub API.
+func NewGitHubOwnersParams(ctx cli.Context, owners []string) (GitHubRepositoryParams, error) {
+	gitHubParams, err := NewGitHubParams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := parallelism(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := &gitHubRepositoryParams{
+		GitHubParams: gitHubParams,
+		parallelism:  n,
+	}
+	for _, owner := range owners {
+		params.owners = append(params.owners, repositoryOwner{
+			name: owner,
+		})
+	}
+	return params, nil
+}
+
+// JoinRepeatedFlags returns the provided command-line arguments for the provided command with all of the occurrences of
+// the organization flag combined into a single occurrence (at the position of the first one) whose value is the
+// comma-separated list of their values. This allows the flag to be repeated. Arguments after "--" and arguments that
+// are the values of other flags of the command or its subcommands are not treated as occurrences of the flag.
+func JoinRepeatedFlags(cmd cli.Command, args []string) []string {
+	return joinRepeatedFlag(args, "--"+organizationFlagName, valueFlagNames(cmd))
+}
+
+// Returns the names (with hyphens) of the flags of the provided command and its subcommands that take a value.
+func valueFlagNames(cmd cli.Command) map[string]struct{} {
+	names := make(map[string]struct{})
+	commands := []cli.Command{cmd}
+	for len(commands) > 0 {
+		curr := commands[0]
+		commands = append(commands[1:], curr.Subcommands...)
+		for _, f := range curr.Flags {
+			if _, isBool := f.(flag.BoolFlag); isBool || !f.HasLeader() {
+				continue
+			}
+			for _, name := range f.FullNames() {
+				names[name] = struct{}{}
+			}
+		}
+	}
+	return names
+}
+
+func joinRepeatedFlag(args []string, flagName string, valueFlags map[string]struct{}) []string {
+	var output, values []string
+	first := -1
+args:
+	for i := 0; i < len(args); i++ {
+		var value string
+		switch {
+		case args[i] == "--":
+			// the remaining arguments are not flags
+			output = append(output, args[i:]...)
+			break args
+		case args[i] == flagName && i+1 < len(args):
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], flagName+"="):
+			value = strings.TrimPrefix(args[i], flagName+"=")
+		default:
+			output = append(output, args[i])
+			if _, ok := valueFlags[args[i]]; ok && i+1 < len(args) {
+				// the next argument is the value of the flag even if it looks like a flag
+				output = append(output, args[i+1])
+				i++
+			}
+			continue
+		}
+		if first == -1 {
+			first = len(output)
+			output = append(output, flagName, "")
+		}
+		values = append(values, value)
+	}
+	if first != -1 {
+		output[first+1] = strings.Join(values, ",")
+	}
+	return output
+}
+
 func (p *gitHubParams) CachingOAuthGitHubClient() *github.Client {
-	return CachingOAuthGitHubClient(p.token, p.cacheDir)
+	rateLimit := p.rateLimit
+	httpClient := cachingOAuthHTTPClient(p.token, p.cacheDir, &rateLimit)
+	if p.dryRun {
+		return repository.NewDryRunClient(httpClient)
+	}
+	return github.NewClient(httpClient)
 }
 
+// CachingOAuthGitHubClient returns a GitHub client that uses the provided token (if it is non-empty) and caches
+// responses in the provided directory (or in memory if it is empty). Requests are sent using a RateLimitTransport with
+// the default configuration that does not write messages.
 func CachingOAuthGitHubClient(token, cacheDir string) *github.Client {
+	return github.NewClient(cachingOAuthHTTPClient(token, cacheDir, NewRateLimitTransport(nil)))
+}
+
+// cachingOAuthHTTPClient returns an HTTP client that caches responses and sends requests using the provided rate limit
+// transport, which uses the OAuth transport for the provided token (if it is non-empty).
+func cachingOAuthHTTPClient(token, cacheDir string, rateLimit *RateLimitTransport) *http.Client {
 	var cache httpcache.Cache
 	if cacheDir != "" {
 		cache = diskcache.New(cacheDir)
@@ -172,7 +513,8 @@ func CachingOAuthGitHubClient(token, cacheDir string) *github.Client {
 			&oauth2.Token{AccessToken: token},
 		)
 		tc := oauth2.NewClient(oauth2.NoContext, ts)
-		cachedTransport.Transport = tc.Transport
+		rateLimit.Transport = tc.Transport
 	}
-	return github.NewClient(cachedTransport.Client())
+	cachedTransport.Transport = rateLimit
+	return cachedTransport.Client()
 }
@@ -0,0 +1,59 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package common_test
+
+import (
+	"testing"
+
+	"github.com/palantir/pkg/cli"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmiyake/ghcli/common"
+)
+
+func TestJoinRepeatedFlags(t *testing.T) {
+	cmd := cli.Command{
+		Subcommands: []cli.Command{
+			{
+				Name:  "verify",
+				Flags: append(common.AllFlags, common.DryRunFlag),
+			},
+		},
+	}
+	for i, currCase := range []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"ghspec", "verify", "--user", "nmiyake", "spec.yml"},
+			want: []string{"ghspec", "verify", "--user", "nmiyake", "spec.yml"},
+		},
+		{
+			args: []string{"ghspec", "verify", "--organization", "org1", "spec.yml"},
+			want: []string{"ghspec", "verify", "--organization", "org1", "spec.yml"},
+		},
+		{
+			args: []string{"ghspec", "verify", "--organization", "org1", "--github-token", "token", "--organization=org2,org3", "--organization", "org4", "spec.yml"},
+			want: []string{"ghspec", "verify", "--organization", "org1,org2,org3,org4", "--github-token", "token", "spec.yml"},
+		},
+		{
+			// flag without a value is left for the parser to report
+			args: []string{"ghspec", "verify", "--organization"},
+			want: []string{"ghspec", "verify", "--organization"},
+		},
+		{
+			// arguments after "--" are not flags
+			args: []string{"ghspec", "verify", "--organization", "org1", "--", "--organization", "org2"},
+			want: []string{"ghspec", "verify", "--organization", "org1", "--", "--organization", "org2"},
+		},
+		{
+			// value of another flag is not an occurrence of the flag
+			args: []string{"ghspec", "verify", "--author", "--organization", "--organization", "org1", "--dry-run", "--organization", "org2"},
+			want: []string{"ghspec", "verify", "--author", "--organization", "--organization", "org1,org2", "--dry-run"},
+		},
+	} {
+		assert.Equal(t, currCase.want, common.JoinRepeatedFlags(cmd, currCase.args), "Case %d", i)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+const (
+	defaultMaxRetries       = 3
+	defaultRetryBackoff     = time.Second
+	defaultMaxRateLimitWait = time.Hour
+)
+
+// RateLimitTransport is an http.RoundTripper for the GitHub API that waits for rate limits to reset and retries
+// requests that fail because of a rate limit or a transient server error:
+//
+//   - If a response reports that the rate limit is exhausted (X-RateLimit-Remaining is 0), the response is returned
+//     only after the time in X-RateLimit-Reset, and the request is retried if it was rejected.
+//   - If a request is rejected because of a secondary rate limit, it is retried after the delay in Retry-After.
+//   - If an idempotent request (one that uses the GET, HEAD, OPTIONS, PUT or DELETE method or a GraphQL query) fails
+//     with a 502, 503 or 504 status, it is retried after a delay that starts at Backoff and doubles for every retry
+//     (with random jitter). Other requests are not retried because the server may have processed them.
+//
+// Waiting stops as soon as the context of the request is done, in which case the response is returned if it was
+// successful and the error of the context is returned otherwise.
+type RateLimitTransport struct {
+	// Transport sends the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// MaxRetries is the maximum number of times that a request is retried.
+	MaxRetries int
+	// Backoff is the delay before the first retry of a request that failed because of a server error.
+	Backoff time.Duration
+	// MaxWait is the maximum time to wait for a rate limit. If a rate limit would require waiting longer, the response is
+	// returned without waiting. Retries after server errors are only bounded by MaxRetries.
+	MaxWait time.Duration
+	// Stdout is the writer to which a message is written whenever the transport waits. If nil, no messages are written.
+	Stdout io.Writer
+}
+
+// NewRateLimitTransport returns a RateLimitTransport with the default configuration that writes its messages to the
+// provided writer.
+func NewRateLimitTransport(stdout io.Writer) *RateLimitTransport {
+	return &RateLimitTransport{
+		MaxRetries: defaultMaxRetries,
+		Backoff:    defaultRetryBackoff,
+		MaxWait:    defaultMaxRateLimitWait,
+		Stdout:     stdout,
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	// the body is read into memory so that it can be sent again when the request is retried
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	newAttempt := func() *http.Request {
+		attemptReq := *req
+		if body != nil {
+			attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		return &attemptReq
+	}
+	idempotent := isIdempotent(newAttempt())
+
+	for attempt := 0; ; attempt++ {
+		resp, err := transport.RoundTrip(newAttempt())
+		if err != nil {
+			return nil, err
+		}
+
+		wait, reason, retry := t.delay(resp, attempt, idempotent)
+		if retry && attempt >= t.MaxRetries {
+			return resp, nil
+		}
+		if !retry {
+			// response was successful but used the last request before the rate limit resets
+			if wait > 0 {
+				t.printf("%s: waiting %v until %s before continuing...\n", reason, round(wait, time.Second), time.Now().Add(wait).Format("15:04:05 MST"))
+				_ = sleep(req.Context(), wait)
+			}
+			return resp, nil
+		}
+
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+		t.printf("%s: waiting %v before retrying %s %s (retry %d/%d)...\n", reason, round(wait, time.Millisecond), req.Method, req.URL.Path, attempt+1, t.MaxRetries)
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// round returns the provided duration rounded to the nearest multiple of the provided unit.
+func round(d, unit time.Duration) time.Duration {
+	return (d + unit/2) / unit * unit
+}
+
+// sleep waits for the provided duration. Returns the error of the provided context if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delay returns the time to wait after the provided response to the provided attempt of a request, the reason for
+// waiting and whether the request should be retried after waiting. A rate limit that would require waiting longer than
+// MaxWait is not waited for. A request that failed because of a server error is only retried if it is idempotent.
+func (t *RateLimitTransport) delay(resp *http.Response, attempt int, idempotent bool) (time.Duration, string, bool) {
+	rejected := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+	if retryAfter := resp.Header.Get("Retry-After"); rejected && retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil {
+			wait := time.Duration(secs) * time.Second
+			if wait > t.MaxWait {
+				return 0, "", false
+			}
+			return wait, "Secondary rate limit exceeded", true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// wait an additional second to account for clock differences
+			wait := time.Unix(reset, 0).Add(time.Second).Sub(time.Now())
+			if wait < 0 {
+				wait = 0
+			}
+			if wait > t.MaxWait {
+				return 0, "", false
+			}
+			return wait, "Rate limit exhausted", rejected
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if idempotent {
+			return t.backoff(attempt), fmt.Sprintf("Server returned %s", resp.Status), true
+		}
+	}
+	return 0, "", false
+}
+
+// isIdempotent returns true if sending the provided request more than once has the same effect as sending it once.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "PUT", "DELETE":
+		return true
+	}
+	return repository.IsReadOnly(req)
+}
+
+// backoff returns the delay before retrying the provided attempt of a request that failed because of a server error,
+// which is a random duration between half of and the full backoff for the attempt.
+func (t *RateLimitTransport) backoff(attempt int) time.Duration {
+	backoff := t.Backoff << uint(attempt)
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func (t *RateLimitTransport) printf(format string, a ...interface{}) {
+	if t.Stdout != nil {
+		fmt.Fprintf(t.Stdout, format, a...)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package common_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/common"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	pastReset := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	futureReset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	for i, currCase := range []struct {
+		name         string
+		responses    []func(w http.ResponseWriter)
+		wantStatus   int
+		wantRequests int
+		wantOutput   string
+	}{
+		{
+			name: "server errors are retried",
+			responses: []func(w http.ResponseWriter){
+				statusResponse(http.StatusServiceUnavailable),
+				statusResponse(http.StatusBadGateway),
+				statusResponse(http.StatusOK),
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 3,
+			wantOutput:   "Server returned 503 Service Unavailable: waiting",
+		},
+		{
+			name: "server errors are retried at most MaxRetries times",
+			responses: []func(w http.ResponseWriter){
+				statusResponse(http.StatusGatewayTimeout),
+				statusResponse(http.StatusGatewayTimeout),
+				statusResponse(http.StatusGatewayTimeout),
+				statusResponse(http.StatusGatewayTimeout),
+				statusResponse(http.StatusOK),
+			},
+			wantStatus:   http.StatusGatewayTimeout,
+			wantRequests: 3,
+			wantOutput:   "(retry 2/2)",
+		},
+		{
+			name: "secondary rate limit is retried after Retry-After",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusForbidden)
+				},
+				statusResponse(http.StatusOK),
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantOutput:   "Secondary rate limit exceeded: waiting 0s before retrying GET /repos/octocat/Hello-World (retry 1/2)...\n",
+		},
+		{
+			name: "exhausted rate limit is retried after reset",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", pastReset)
+					w.WriteHeader(http.StatusForbidden)
+				},
+				statusResponse(http.StatusOK),
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantOutput:   "Rate limit exhausted: waiting 0s before retrying",
+		},
+		{
+			name: "rate limit that resets after MaxWait is not waited for",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", futureReset)
+					w.WriteHeader(http.StatusForbidden)
+				},
+				statusResponse(http.StatusOK),
+			},
+			wantStatus:   http.StatusForbidden,
+			wantRequests: 1,
+		},
+		{
+			name: "other errors are not retried",
+			responses: []func(w http.ResponseWriter){
+				statusResponse(http.StatusNotFound),
+				statusResponse(http.StatusOK),
+			},
+			wantStatus:   http.StatusNotFound,
+			wantRequests: 1,
+		},
+	} {
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			currCase.responses[requests](w)
+			requests++
+		}))
+
+		buf := &bytes.Buffer{}
+		client := &http.Client{
+			Transport: &common.RateLimitTransport{
+				MaxRetries: 2,
+				Backoff:    time.Millisecond,
+				MaxWait:    time.Minute,
+				Stdout:     buf,
+			},
+		}
+		resp, err := client.Get(ts.URL + "/repos/octocat/Hello-World")
+		require.NoError(t, err, "Case %d: %s", i, currCase.name)
+		_ = resp.Body.Close()
+		ts.Close()
+
+		assert.Equal(t, currCase.wantStatus, resp.StatusCode, "Case %d: %s", i, currCase.name)
+		assert.Equal(t, currCase.wantRequests, requests, "Case %d: %s", i, currCase.name)
+		if currCase.wantOutput == "" {
+			assert.Empty(t, buf.String(), "Case %d: %s", i, currCase.name)
+		} else {
+			assert.Contains(t, buf.String(), currCase.wantOutput, "Case %d: %s", i, currCase.name)
+		}
+	}
+}
+
+func TestRateLimitTransportRetriesServerErrorsWhenRateLimitsAreNotWaitedFor(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: &common.RateLimitTransport{
+			MaxRetries: 1,
+			Backoff:    time.Millisecond,
+			MaxWait:    0,
+		},
+	}
+	resp, err := client.Get(ts.URL + "/repos/octocat/Hello-World")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, requests)
+}
+
+func TestRateLimitTransportRetriesRequestBody(t *testing.T) {
+	for i, currCase := range []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		failure    func(w http.ResponseWriter)
+		wantStatus int
+		wantSent   int
+	}{
+		{
+			name:       "idempotent request is retried with its body after server error",
+			method:     "PUT",
+			path:       "/repos/octocat/Hello-World/topics",
+			body:       `{"names": ["go"]}`,
+			failure:    statusResponse(http.StatusBadGateway),
+			wantStatus: http.StatusOK,
+			wantSent:   2,
+		},
+		{
+			name:       "GraphQL query is retried after server error",
+			method:     "POST",
+			path:       "/graphql",
+			body:       `{"query": "query { viewer { login } }"}`,
+			failure:    statusResponse(http.StatusServiceUnavailable),
+			wantStatus: http.StatusOK,
+			wantSent:   2,
+		},
+		{
+			name:       "POST request is not retried after server error because it may have been processed",
+			method:     "POST",
+			path:       "/repos/octocat/Hello-World/issues",
+			body:       `{"title": "bug"}`,
+			failure:    statusResponse(http.StatusBadGateway),
+			wantStatus: http.StatusBadGateway,
+			wantSent:   1,
+		},
+		{
+			name:       "GraphQL mutation is not retried after server error",
+			method:     "POST",
+			path:       "/graphql",
+			body:       `{"query": "mutation { addStar(input: {}) { clientMutationId } }"}`,
+			failure:    statusResponse(http.StatusGatewayTimeout),
+			wantStatus: http.StatusGatewayTimeout,
+			wantSent:   1,
+		},
+		{
+			name:   "POST request is retried with its body after secondary rate limit",
+			method: "POST",
+			path:   "/repos/octocat/Hello-World/issues",
+			body:   `{"title": "bug"}`,
+			failure: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantStatus: http.StatusOK,
+			wantSent:   2,
+		},
+	} {
+		var bodies []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodies = append(bodies, string(body))
+			if len(bodies) == 1 {
+				currCase.failure(w)
+			}
+		}))
+
+		client := &http.Client{
+			Transport: &common.RateLimitTransport{
+				MaxRetries: 1,
+				Backoff:    time.Millisecond,
+				MaxWait:    time.Minute,
+			},
+		}
+		req, err := http.NewRequest(currCase.method, ts.URL+currCase.path, bytes.NewBufferString(currCase.body))
+		require.NoError(t, err, "Case %d: %s", i, currCase.name)
+		resp, err := client.Do(req)
+		require.NoError(t, err, "Case %d: %s", i, currCase.name)
+		_ = resp.Body.Close()
+		ts.Close()
+
+		assert.Equal(t, currCase.wantStatus, resp.StatusCode, "Case %d: %s", i, currCase.name)
+		require.Len(t, bodies, currCase.wantSent, "Case %d: %s", i, currCase.name)
+		for _, body := range bodies {
+			assert.Equal(t, currCase.body, body, "Case %d: %s", i, currCase.name)
+		}
+	}
+}
+
+func TestRateLimitTransportStopsWaitingWhenContextDone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: &common.RateLimitTransport{
+			MaxRetries: 1,
+			MaxWait:    time.Hour,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = client.Do(req.WithContext(ctx))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	assert.True(t, time.Since(start) < 30*time.Second, "request waited %v after the context was done", time.Since(start))
+}
+
+func TestRateLimitTransportWaitInterruptedThroughAPICall(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	client := github.NewClient(&http.Client{
+		Transport: &common.RateLimitTransport{
+			MaxRetries: 1,
+			MaxWait:    time.Hour,
+		},
+	})
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	// cancelling the context is what happens when the user interrupts the program
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	err := repository.ProcessOwnerRepos(ctx, client, "octocat", func(ctx context.Context, repo *github.Repository, progress repository.Progress) error {
+		return nil
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.True(t, time.Since(start) < 30*time.Second, "request waited %v after the context was cancelled", time.Since(start))
+}
+
+func statusResponse(status int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+	}
+}
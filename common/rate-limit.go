@@ -22,7 +22,11 @@ func RateLimit() cli.Command {
 			GitHubTokenFlag,
 		},
 		Action: func(ctx cli.Context) error {
-			return doRateLimit(NewGitHubParams(ctx), ctx.App.Stdout)
+			params, err := NewGitHubParams(ctx)
+			if err != nil {
+				return err
+			}
+			return doRateLimit(params, ctx.App.Stdout)
 		},
 	}
 }
@@ -0,0 +1,85 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/palantir/pkg/cli"
+	"github.com/palantir/pkg/cli/flag"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+)
+
+const (
+	dirFlagName             = "dir"
+	headerTemplatesFlagName = "templates"
+)
+
+var (
+	dirFlag = flag.StringFlag{
+		Name:  dirFlagName,
+		Usage: "directory that contains the files to process",
+		Value: ".",
+	}
+	headerTemplatesFlag = flag.StringFlag{
+		Name:  headerTemplatesFlagName,
+		Usage: "YML file that defines header templates per license and comment style",
+	}
+)
+
+func Headers() cli.Command {
+	return cli.Command{
+		Name:  "headers",
+		Usage: "check and fix the license headers of source files in a local directory",
+		Subcommands: []cli.Command{
+			headersCommand("check", "verify that source files have the correct license header", false),
+			headersCommand("fix", "add or replace the license headers of source files that do not have the correct header", true),
+		},
+	}
+}
+
+func headersCommand(name, usage string, fix bool) cli.Command {
+	return cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: []flag.Flag{
+			dirFlag,
+			authorFlag,
+			headerTemplatesFlag,
+			licenseParam,
+		},
+		Action: func(ctx cli.Context) error {
+			templates, err := license.LoadHeaderTemplates(ctx.String(headerTemplatesFlagName))
+			if err != nil {
+				return err
+			}
+			headers, err := license.NewHeaders(ctx.String(licenseParamName), templates, ctx.String(authorFlagName), time.Now().Year())
+			if err != nil {
+				return err
+			}
+			return doHeaders(ctx.String(dirFlagName), headers, fix, ctx.App.Stdout)
+		},
+	}
+}
+
+func doHeaders(dir string, headers *license.Headers, fix bool, stdout io.Writer) error {
+	paths, err := license.ProcessDirHeaders(dir, headers, fix)
+	if err != nil {
+		return err
+	}
+	if fix {
+		fmt.Fprintln(stdout, repoMessage(fmt.Sprintf("Fixed license headers of %s", pluralize(len(paths), "file", "files")), paths))
+		return nil
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	fmt.Fprintln(stdout, repoMessage(fmt.Sprintf("%s had missing or incorrect license headers", pluralize(len(paths), "file", "files")), paths))
+	return errors.Errorf("license headers are not correct")
+}
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"sort"
@@ -58,7 +59,8 @@ func List() cli.Command {
 func doList(gitHubToken string, header, aliases bool, stdout io.Writer) error {
 	client := common.CachingOAuthGitHubClient(gitHubToken, "")
 
-	licenses, _, err := client.Licenses.List()
+	// the command does not handle interrupts, so interrupting it terminates the process along with any request
+	licenses, err := license.ListLicenses(context.Background(), client)
 	if err != nil {
 		return errors.Wrapf(err, "failed to list licenses")
 	}
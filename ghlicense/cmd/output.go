@@ -5,6 +5,7 @@
 package cmd
 
 import (
+	"context"
 	"io/ioutil"
 	"time"
 
@@ -47,7 +48,7 @@ func Write() cli.Command {
 
 func Print() cli.Command {
 	return outputCommand("print", "print the content of a license", func(ctx cli.Context, license string) error {
-		ctx.Printf(license)
+		ctx.Printf("%s", license)
 		return nil
 	})
 }
@@ -66,7 +67,7 @@ func outputCommand(name, usage string, f func(ctx cli.Context, license string) e
 		Action: func(ctx cli.Context) error {
 			year := time.Now().Year()
 			cache := license.NewCache(common.CachingOAuthGitHubClient(ctx.String(common.GitHubTokenFlagName), ""))
-			license, err := license.Create(ctx.String(licenseParamName), cache, license.NewAuthorInfo(ctx.String(authorFlagName), year, year))
+			license, err := license.Create(context.Background(), ctx.String(licenseParamName), cache, license.NewAuthorInfo(ctx.String(authorFlagName), year, year))
 			if err != nil {
 				return err
 			}
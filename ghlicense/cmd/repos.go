@@ -5,9 +5,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/google/go-github/github"
 	"github.com/palantir/pkg/cli"
@@ -39,7 +42,9 @@ func Verify() cli.Command {
 			if err != nil {
 				return err
 			}
-			return doRepositoryLicense(params, ctx.Slice(reposParamName), ctx.String(common.CopyrightAuthorFlagName), verifyLicenses, false, ctx.App.Stdout)
+			runCtx, stop := common.InterruptContext(ctx.App.Stdout)
+			defer stop()
+			return doRepositoryLicense(runCtx, params, ctx.Slice(reposParamName), ctx.String(common.CopyrightAuthorFlagName), verifyLicenses, false, ctx.App.Stdout)
 		},
 	}
 }
@@ -51,13 +56,16 @@ func Fix() cli.Command {
 		Flags: append(common.AllFlags,
 			reposParam,
 			common.PromptFlag,
+			common.DryRunFlag,
 		),
 		Action: func(ctx cli.Context) error {
 			params, err := common.NewGitHubRepositoryParams(ctx)
 			if err != nil {
 				return err
 			}
-			return doRepositoryLicense(params, ctx.Slice(reposParamName), ctx.String(common.CopyrightAuthorFlagName), fixLicenses, ctx.Bool(common.PromptFlagName), ctx.App.Stdout)
+			runCtx, stop := common.InterruptContext(ctx.App.Stdout)
+			defer stop()
+			return doRepositoryLicense(runCtx, params, ctx.Slice(reposParamName), ctx.String(common.CopyrightAuthorFlagName), fixLicenses, ctx.Bool(common.PromptFlagName), ctx.App.Stdout)
 		},
 	}
 }
@@ -69,7 +77,10 @@ const (
 	fixLicenses
 )
 
-func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, copyrightAuthor string, mode processMode, prompt bool, stdout io.Writer) error {
+// doRepositoryLicense verifies (and, depending on the mode, fixes) the licenses of the provided repositories. If the
+// provided context is cancelled, the repositories that have not been processed yet are skipped and the summary covers
+// only the repositories that were processed.
+func doRepositoryLicense(ctx context.Context, params common.GitHubRepositoryParams, repos []string, copyrightAuthor string, mode processMode, prompt bool, stdout io.Writer) error {
 	client := params.CachingOAuthGitHubClient()
 	cache := license.NewCache(client)
 
@@ -77,20 +88,29 @@ func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, c
 	var unableToDetermineRepos []string
 	var badRepos []string
 	numFixPRsOpened := 0
+	var resultsMutex sync.Mutex // guards the results above (repositories may be processed concurrently)
 
-	f := func(repo *github.Repository, progress repository.Progress) error {
+	if prompt && mode == fixLicenses && !params.DryRun() && params.Parallelism() > 1 {
+		return errors.Errorf("--%s cannot be used if the parallelism is greater than 1", common.PromptFlagName)
+	}
+
+	f := func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
 		fmt.Fprintf(stdout, "Verifying license for repository %s (%v)...", *repo.Name, progress)
 
-		repoLicense, err := license.VerifyCorrect(client, repo, copyrightAuthor, cache)
+		repoLicense, err := license.VerifyCorrect(ctx, client, repo, copyrightAuthor, cache)
 		switch {
 		case err == nil:
+			resultsMutex.Lock()
 			okRepos = append(okRepos, *repo.Name)
+			resultsMutex.Unlock()
 			fmt.Fprintf(stdout, "OK")
 			fmt.Fprintln(stdout)
 			return nil
 		case license.IsMissing(err):
 			msg := fmt.Sprintf("%s: %s", *repo.Name, err.Error())
+			resultsMutex.Lock()
 			unableToDetermineRepos = append(unableToDetermineRepos, msg)
+			resultsMutex.Unlock()
 			fmt.Fprintf(stdout, "unable to detect license")
 			fmt.Fprintln(stdout)
 			return nil
@@ -100,7 +120,9 @@ func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, c
 			if diff != "" {
 				msg += ":" + strings.NewReplacer("\n", "\n\t\t").Replace("\n"+diff)
 			}
+			resultsMutex.Lock()
 			badRepos = append(badRepos, msg)
+			resultsMutex.Unlock()
 			fmt.Fprintf(stdout, "incorrect")
 			fmt.Fprintln(stdout)
 
@@ -108,7 +130,8 @@ func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, c
 				return nil
 			}
 
-			repoInfo, err := repository.GetInfo(client, repo)
+			// fixing the license does not use any of the optional sections of the information
+			repoInfo, err := repository.NewRESTInfoFetcher(client).GetInfo(ctx, repo, 0)
 			if err != nil {
 				fmt.Fprintf(stdout, "Failed to get information required to fix repository: %v\n", err)
 				return nil
@@ -116,7 +139,7 @@ func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, c
 				return errors.Errorf("repository %s is an empty repository", *repo.Name)
 			}
 
-			if prompt {
+			if prompt && !params.DryRun() {
 				ok, err := common.Prompt("Open PR for fix", stdout)
 				if err != nil {
 					return err
@@ -127,10 +150,14 @@ func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, c
 				}
 			}
 
-			if err := license.ApplyStandard(client, repoInfo, *repoLicense.License.Key, copyrightAuthor, license.DefaultPRParams(*repoLicense.License.Name), cache, stdout); err != nil {
+			prParams := license.DefaultPRParams(*repoLicense.License.Name)
+			prParams.DryRun = params.DryRun()
+			if err := license.ApplyStandard(ctx, client, repoInfo, *repoLicense.License.Key, copyrightAuthor, prParams, cache, stdout); err != nil {
 				return err
 			}
+			resultsMutex.Lock()
 			numFixPRsOpened++
+			resultsMutex.Unlock()
 			return nil
 		default:
 			fmt.Fprintln(stdout)
@@ -138,16 +165,30 @@ func doRepositoryLicense(params common.GitHubRepositoryParams, repos []string, c
 		}
 	}
 
-	if err := params.ProcessRepos(client, repos, f); err != nil {
+	// if interrupted, the error is the one of the repository that was abandoned and a partial summary is printed instead
+	if err := params.ProcessRepos(ctx, client, nil, repos, stdout, f); err != nil && ctx.Err() == nil {
 		return err
 	}
 
+	// repositories may be processed concurrently, so results are sorted to make the summary deterministic
+	for _, results := range [][]string{okRepos, badRepos, unableToDetermineRepos} {
+		sort.Sort(repository.CaseInsensitiveStrings(results))
+	}
+
 	if mode == verifyLicenses {
 		fmt.Fprintln(stdout, repoMessage(fmt.Sprintf("%s had correct license files", pluralizeRepo(len(okRepos))), okRepos))
 		fmt.Fprintln(stdout, repoMessage(fmt.Sprintf("%s had incorrect license files", pluralizeRepo(len(badRepos))), badRepos))
 		fmt.Fprintln(stdout, repoMessage(fmt.Sprintf("Unable to determine license type for %s", pluralizeRepo(len(unableToDetermineRepos))), unableToDetermineRepos))
 	} else {
-		fmt.Fprintf(stdout, "Examined %s and opened %s.\n", pluralizeRepo(len(okRepos)+len(badRepos)+len(unableToDetermineRepos)), pluralizePR(numFixPRsOpened))
+		numExamined := pluralizeRepo(len(okRepos) + len(badRepos) + len(unableToDetermineRepos))
+		if params.DryRun() {
+			fmt.Fprintf(stdout, "Examined %s and would have opened %s (dry run).\n", numExamined, pluralizePR(numFixPRsOpened))
+		} else {
+			fmt.Fprintf(stdout, "Examined %s and opened %s.\n", numExamined, pluralizePR(numFixPRsOpened))
+		}
+	}
+	if ctx.Err() != nil {
+		return errors.Errorf("interrupted after processing %s", pluralizeRepo(len(okRepos)+len(badRepos)+len(unableToDetermineRepos)))
 	}
 	return nil
 }
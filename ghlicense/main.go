@@ -25,6 +25,7 @@ func main() {
 		cmd.Write(),
 		cmd.Verify(),
 		cmd.Fix(),
+		cmd.Headers(),
 	}
-	os.Exit(app.Run(os.Args))
+	os.Exit(app.Run(common.JoinRepeatedFlags(app.Command, os.Args)))
 }
@@ -0,0 +1,186 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/palantir/pkg/cli"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/common"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func PlanSpec() cli.Command {
+	return cli.Command{
+		Name:  "plan",
+		Usage: "write the changes that applying the GitHub repository specification would make to a plan file",
+		Flags: append(common.AllFlags,
+			reposFlag,
+			strictOwnersFlag,
+			patentsTemplateFlag,
+			labelSetsFlag,
+			fileTemplatesFlag,
+			headerTemplatesFlag,
+			pluginsFlag,
+			deleteLabelsFlag,
+			common.InfoAPIFlag,
+			specRepoFlag,
+			specPathFlag,
+			specRefFlag,
+			planFlag,
+			specSourceParam,
+		),
+		Action: func(ctx cli.Context) error {
+			if !ctx.Has(planFlagName) {
+				return errors.Errorf("--%s must be specified", planFlagName)
+			}
+			runCtx, stop := common.InterruptContext(ctx.App.Stdout)
+			defer stop()
+			repoSpec, err := loadSpec(runCtx, ctx)
+			if err != nil {
+				return err
+			}
+			params, err := getRepositoryParams(ctx, repoSpec)
+			if err != nil {
+				return err
+			}
+			analyzers, err := getAnalyzers(params, ctx)
+			if err != nil {
+				return err
+			}
+			return processSpec(runCtx, params, getRepos(ctx), repoSpec, analyzers, planMode, false, ctx.String(planFlagName), ctx.App.Stdout)
+		},
+	}
+}
+
+// applyPlan executes the plan specified by the plan flag of apply.
+func applyPlan(runCtx context.Context, ctx cli.Context) error {
+	if len(ctx.Slice(specFileParamName)) != 0 || ctx.Has(specRepoFlagName) {
+		return errors.Errorf("a specification cannot be provided if --%s is specified", planFlagName)
+	}
+	if ctx.Has(reposFlagName) {
+		return errors.Errorf("--%s cannot be specified if --%s is specified", reposFlagName, planFlagName)
+	}
+	plan, err := spec.ReadPlan(ctx.String(planFlagName))
+	if err != nil {
+		return err
+	}
+	params, err := common.NewGitHubParams(ctx)
+	if err != nil {
+		return err
+	}
+	analyzers, err := getAnalyzers(params, ctx)
+	if err != nil {
+		return err
+	}
+	client := params.CachingOAuthGitHubClient()
+	return doApplyPlan(runCtx, client, params.InfoFetcher(client), plan, analyzers, params.Timeout(), params.DryRun(), ctx.App.Stdout)
+}
+
+// doApplyPlan executes the provided plan. The repositories in the plan are compared with the definitions in the plan
+// before any change is made, and no changes are made if the differences of any repository are not the ones in the
+// plan. Checking or fixing a single repository is abandoned once timeout has elapsed (if it is positive). If the
+// provided context is cancelled while the repositories are being checked, no changes are made; if it is cancelled while
+// they are being fixed, the remaining repositories are skipped and the summary covers only the ones that were fixed.
+func doApplyPlan(ctx context.Context, client *github.Client, fetcher repository.InfoFetcher, plan spec.Plan, analyzers []spec.Analyzer, timeout time.Duration, dryRun bool, stdout io.Writer) error {
+	checked := make([]checkedRepository, len(plan.Repositories))
+	driftedRepos := make(map[string]string) // repos whose differences are not the planned ones (value is the drift)
+	for i, repoPlan := range plan.Repositories {
+		if ctx.Err() != nil {
+			return errors.New("Interrupted while checking repositories against plan: no changes were made")
+		}
+		fmt.Fprintf(stdout, "Checking repository %s against plan (%d/%d)...", repoPlan.FullName, i+1, len(plan.Repositories))
+		repo, drift, err := checkPlannedRepository(ctx, client, fetcher, repoPlan, analyzers, timeout, stdout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.New("Interrupted while checking repositories against plan: no changes were made")
+			}
+			return err
+		}
+		if drift != "" {
+			driftedRepos[repoPlan.FullName] = drift
+			continue
+		}
+		checked[i] = repo
+	}
+	if len(driftedRepos) > 0 {
+		header := fmt.Sprintf("No changes were made because %s changed since the plan was created:", pluralizedRepositories(len(driftedRepos)))
+		return errors.Errorf("%s", strings.Join(diffParts(header, driftedRepos), "\n"))
+	}
+
+	fixedRepos := make(map[string]string)      // repos successfully fixed (value is the differences that were fixed)
+	failedToFixRepos := make(map[string]error) // repos not successfully fixed (value is error encountered)
+	for _, repo := range checked {
+		if ctx.Err() != nil {
+			break
+		}
+		opCtx, cancel := common.OperationContext(ctx, timeout)
+		fixedDiffs, err := fixRepository(opCtx, client, repo.plan, repo.info, analyzers, dryRun, stdout)
+		cancel()
+		if err != nil {
+			failedToFixRepos[repo.plan.FullName] = err
+			continue
+		}
+		fixedRepos[repo.plan.FullName] = fixedDiffs
+	}
+	summaryErr := printApplySummary(nil, fixedRepos, failedToFixRepos, dryRun, stdout)
+	if ctx.Err() != nil {
+		return interruptedError(len(fixedRepos)+len(failedToFixRepos), summaryErr)
+	}
+	return summaryErr
+}
+
+// checkedRepository is a repository that was checked against its plan.
+type checkedRepository struct {
+	info repository.Info
+	plan spec.RepositoryPlan // current plan of the repository, which has the same changes as the plan that was checked
+}
+
+// checkPlannedRepository retrieves the information of the repository in the provided plan and compares it with the
+// definition in the plan. Returns the differences that are not the planned ones as the drift if there are any, in which
+// case the returned repository should not be used.
+func checkPlannedRepository(ctx context.Context, client *github.Client, fetcher repository.InfoFetcher, repoPlan spec.RepositoryPlan, analyzers []spec.Analyzer, timeout time.Duration, stdout io.Writer) (checkedRepository, string, error) {
+	opCtx, cancel := common.OperationContext(ctx, timeout)
+	defer cancel()
+
+	parts := strings.Split(repoPlan.FullName, "/")
+	if len(parts) != 2 {
+		fmt.Fprintln(stdout, "invalid name")
+		return checkedRepository{}, "", errors.Errorf("invalid repository name in plan: %s", repoPlan.FullName)
+	}
+	if err := opCtx.Err(); err != nil {
+		fmt.Fprintln(stdout, "failed to get repository")
+		return checkedRepository{}, "", errors.Wrapf(err, "failed to retrieve repository %s", repoPlan.FullName)
+	}
+	repo, err := repository.GetRepository(opCtx, client, parts[0], parts[1])
+	if err != nil {
+		fmt.Fprintln(stdout, "failed to get repository")
+		return checkedRepository{}, "", errors.Wrapf(err, "failed to retrieve repository %s", repoPlan.FullName)
+	}
+	info, err := fetcher.GetInfo(opCtx, repo, spec.InfoSections(repoPlan.Definition, analyzers))
+	if err != nil {
+		fmt.Fprintln(stdout, "failed to get repository info")
+		return checkedRepository{}, "", err
+	}
+	currentPlan, err := repoPlan.CheckDrift(opCtx, info, analyzers)
+	if err != nil {
+		if ctxErr := opCtx.Err(); ctxErr != nil {
+			fmt.Fprintln(stdout, "failed to compare with plan")
+			return checkedRepository{}, "", errors.Wrapf(ctxErr, "failed to compare repository %s with plan", repoPlan.FullName)
+		}
+		fmt.Fprintln(stdout, "drifted")
+		return checkedRepository{}, err.Error(), nil
+	}
+	fmt.Fprintln(stdout, "OK")
+	return checkedRepository{info: info, plan: currentPlan}, "", nil
+}
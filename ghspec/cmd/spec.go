@@ -5,11 +5,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/google/go-github/github"
 	"github.com/palantir/pkg/cli"
@@ -18,14 +20,27 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/nmiyake/ghcli/common"
+	"github.com/nmiyake/ghcli/license"
 	"github.com/nmiyake/ghcli/repository"
 	"github.com/nmiyake/ghcli/spec"
 )
 
 const (
-	outputFileParamName = "output"
-	specFileParamName   = "spec"
-	reposFlagName       = "repositories"
+	outputFileParamName     = "output"
+	specFileParamName       = "spec"
+	reposFlagName           = "repositories"
+	strictOwnersFlagName    = "strict-owners"
+	patentsTemplateFlagName = "patents-template"
+	labelSetsFlagName       = "label-sets"
+	deleteLabelsFlagName    = "delete-extra-labels"
+	fileTemplatesFlagName   = "file-templates"
+	headerTemplatesFlagName = "header-templates"
+	pluginsFlagName         = "plugins"
+	printDefinitionFlagName = "print-definition"
+	specRepoFlagName        = "spec-repo"
+	specPathFlagName        = "spec-path"
+	specRefFlagName         = "spec-ref"
+	planFlagName            = "plan"
 )
 
 var (
@@ -35,12 +50,66 @@ var (
 	}
 	specFileParam = flag.StringParam{
 		Name:  specFileParamName,
-		Usage: "repository specification file",
+		Usage: "repository specification file or directory",
+	}
+	specSourceParam = flag.StringSlice{
+		Name:     specFileParamName,
+		Usage:    "repository specification file or directory (must be omitted if --" + specRepoFlagName + " is specified)",
+		Optional: true,
+	}
+	specRepoFlag = flag.StringFlag{
+		Name:  specRepoFlagName,
+		Usage: `GitHub repository ("owner/repo") that contains the repository specification file`,
+	}
+	specPathFlag = flag.StringFlag{
+		Name:  specPathFlagName,
+		Usage: "path of the YML or JSON repository specification file in the repository specified by --" + specRepoFlagName,
+	}
+	specRefFlag = flag.StringFlag{
+		Name:  specRefFlagName,
+		Usage: "branch, tag or commit of the repository specified by --" + specRepoFlagName + " (if unspecified, the default branch is used)",
+	}
+	planFlag = flag.StringFlag{
+		Name:  planFlagName,
+		Usage: "plan file that is written by plan and executed by apply (if specified for apply, the specification is not used)",
 	}
 	reposFlag = flag.StringFlag{
 		Name:  reposFlagName,
 		Usage: "repositories to process (if specified, only these repositories are processed)",
 	}
+	strictOwnersFlag = flag.StringFlag{
+		Name:  strictOwnersFlagName,
+		Usage: `action to take for admins that are not owners in the definition ("downgrade" or "remove"; if unspecified, they are ignored)`,
+	}
+	patentsTemplateFlag = flag.StringFlag{
+		Name:  patentsTemplateFlagName,
+		Usage: "file that contains the required content of PATENTS files (if unspecified, only the presence of the file is verified)",
+	}
+	labelSetsFlag = flag.StringFlag{
+		Name:  labelSetsFlagName,
+		Usage: "YML file that maps the names of shared label sets to their labels",
+	}
+	fileTemplatesFlag = flag.StringFlag{
+		Name:  fileTemplatesFlagName,
+		Usage: "directory against which relative paths of file templates in definitions are resolved",
+		Value: ".",
+	}
+	headerTemplatesFlag = flag.StringFlag{
+		Name:  headerTemplatesFlagName,
+		Usage: "YML file that defines license header templates per license and comment style",
+	}
+	pluginsFlag = flag.StringFlag{
+		Name:  pluginsFlagName,
+		Usage: "YML file that lists external executables to run as analyzers",
+	}
+	printDefinitionFlag = flag.StringFlag{
+		Name:  printDefinitionFlagName,
+		Usage: "print the effective definition of the specified repository (with defaults and patterns applied) instead of verifying",
+	}
+	deleteLabelsFlag = flag.BoolFlag{
+		Name:  deleteLabelsFlagName,
+		Usage: "delete labels that are not in the definition (removes the labels from all issues)",
+	}
 )
 
 func CreateSpec() cli.Command {
@@ -49,6 +118,7 @@ func CreateSpec() cli.Command {
 		Usage: "create GitHub repository specification",
 		Flags: append(common.RepositoryFlags,
 			reposFlag,
+			common.InfoAPIFlag,
 			outputFileParam,
 		),
 		Action: func(ctx cli.Context) error {
@@ -56,7 +126,9 @@ func CreateSpec() cli.Command {
 			if err != nil {
 				return err
 			}
-			return doCreateSpec(params, getRepos(ctx), ctx.String(outputFileParamName), ctx.App.Stdout)
+			runCtx, stop := common.InterruptContext(ctx.App.Stdout)
+			defer stop()
+			return doCreateSpec(runCtx, params, getRepos(ctx), ctx.String(outputFileParamName), ctx.App.Stdout)
 		},
 	}
 }
@@ -67,14 +139,38 @@ func VerifySpec() cli.Command {
 		Usage: "verify GitHub repository specification",
 		Flags: append(common.AllFlags,
 			reposFlag,
-			specFileParam,
+			strictOwnersFlag,
+			patentsTemplateFlag,
+			labelSetsFlag,
+			fileTemplatesFlag,
+			headerTemplatesFlag,
+			pluginsFlag,
+			printDefinitionFlag,
+			common.InfoAPIFlag,
+			specRepoFlag,
+			specPathFlag,
+			specRefFlag,
+			specSourceParam,
 		),
 		Action: func(ctx cli.Context) error {
-			params, err := common.NewGitHubRepositoryParams(ctx)
+			runCtx, stop := common.InterruptContext(ctx.App.Stdout)
+			defer stop()
+			repoSpec, err := loadSpec(runCtx, ctx)
+			if err != nil {
+				return err
+			}
+			if ctx.Has(printDefinitionFlagName) {
+				return doPrintDefinition(repoSpec, ctx.String(printDefinitionFlagName), ctx.App.Stdout)
+			}
+			params, err := getRepositoryParams(ctx, repoSpec)
 			if err != nil {
 				return err
 			}
-			return processSpec(params, getRepos(ctx), ctx.String(specFileParamName), getAnalyzers(params, ctx), verifyMode, true, ctx.App.Stdout)
+			analyzers, err := getAnalyzers(params, ctx)
+			if err != nil {
+				return err
+			}
+			return processSpec(runCtx, params, getRepos(ctx), repoSpec, analyzers, verifyMode, true, "", ctx.App.Stdout)
 		},
 	}
 }
@@ -85,15 +181,54 @@ func ApplySpec() cli.Command {
 		Usage: "apply GitHub repository specification",
 		Flags: append(common.AllFlags,
 			reposFlag,
-			specFileParam,
+			strictOwnersFlag,
+			patentsTemplateFlag,
+			labelSetsFlag,
+			fileTemplatesFlag,
+			headerTemplatesFlag,
+			pluginsFlag,
+			deleteLabelsFlag,
+			common.InfoAPIFlag,
+			specRepoFlag,
+			specPathFlag,
+			specRefFlag,
+			planFlag,
+			specSourceParam,
 			common.PromptFlag,
+			common.DryRunFlag,
 		),
 		Action: func(ctx cli.Context) error {
-			params, err := common.NewGitHubRepositoryParams(ctx)
+			runCtx, stop := common.InterruptContext(ctx.App.Stdout)
+			defer stop()
+			if ctx.Has(planFlagName) {
+				return applyPlan(runCtx, ctx)
+			}
+			repoSpec, err := loadSpec(runCtx, ctx)
 			if err != nil {
 				return err
 			}
-			return processSpec(params, getRepos(ctx), ctx.String(specFileParamName), getAnalyzers(params, ctx), applyMode, ctx.Bool(common.PromptFlagName), ctx.App.Stdout)
+			params, err := getRepositoryParams(ctx, repoSpec)
+			if err != nil {
+				return err
+			}
+			analyzers, err := getAnalyzers(params, ctx)
+			if err != nil {
+				return err
+			}
+			return processSpec(runCtx, params, getRepos(ctx), repoSpec, analyzers, applyMode, ctx.Bool(common.PromptFlagName), "", ctx.App.Stdout)
+		},
+	}
+}
+
+func LintSpec() cli.Command {
+	return cli.Command{
+		Name:  "lint",
+		Usage: "check GitHub repository specification for errors without accessing GitHub",
+		Flags: []flag.Flag{
+			specFileParam,
+		},
+		Action: func(ctx cli.Context) error {
+			return doLintSpec(ctx.String(specFileParamName), ctx.App.Stdout)
 		},
 	}
 }
@@ -106,37 +241,154 @@ func getRepos(ctx cli.Context) []string {
 	return repos
 }
 
-func getAnalyzers(params common.GitHubRepositoryParams, ctx cli.Context) []spec.Analyzer {
+// loadSpec returns the specification loaded from the file in the GitHub repository specified by the --spec-repo flag
+// or, if the flag is not specified, from the local file or directory provided as a parameter.
+func loadSpec(runCtx context.Context, ctx cli.Context) (*repository.Spec, error) {
+	specFiles := ctx.Slice(specFileParamName)
+	if !ctx.Has(specRepoFlagName) {
+		if len(specFiles) != 1 {
+			return nil, errors.Errorf("exactly one specification file or directory must be provided unless --%s is specified", specRepoFlagName)
+		}
+		return repository.LoadSpec(specFiles[0])
+	}
+
+	if len(specFiles) != 0 {
+		return nil, errors.Errorf("a specification file or directory cannot be provided if --%s is specified", specRepoFlagName)
+	}
+	if !ctx.Has(specPathFlagName) {
+		return nil, errors.Errorf("--%s must be specified if --%s is specified", specPathFlagName, specRepoFlagName)
+	}
+	parts := strings.Split(ctx.String(specRepoFlagName), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf(`invalid value for flag %s: must be of the form "owner/repo", was %q`, specRepoFlagName, ctx.String(specRepoFlagName))
+	}
+	var ref string
+	if ctx.Has(specRefFlagName) {
+		ref = ctx.String(specRefFlagName)
+	}
+	specRepo := &github.Repository{
+		Owner: &github.User{
+			Login: github.String(parts[0]),
+		},
+		Name: github.String(parts[1]),
+	}
+	params, err := common.NewGitHubParams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetSpecFromFile(runCtx, params.CachingOAuthGitHubClient(), specRepo, ctx.String(specPathFlagName), ref)
+}
+
+// getRepositoryParams returns the parameters for the owners specified by the user or organization flags or, if neither
+// flag is specified, for the owners of the repositories in the provided specification.
+func getRepositoryParams(ctx cli.Context, repoSpec *repository.Spec) (common.GitHubRepositoryParams, error) {
+	if common.HasOwnerFlags(ctx) {
+		return common.NewGitHubRepositoryParams(ctx)
+	}
+	owners := repoSpec.Owners()
+	if len(owners) == 0 {
+		return nil, errors.Errorf("specification does not name any owners, so either user or organization must be provided")
+	}
+	return common.NewGitHubOwnersParams(ctx, owners)
+}
+
+func getAnalyzers(params common.GitHubParams, ctx cli.Context) ([]spec.Analyzer, error) {
 	var authorName string
 	if ctx.Has(common.CopyrightAuthorFlagName) {
 		authorName = ctx.String(common.CopyrightAuthorFlagName)
 	}
+	var strictOwners string
+	if ctx.Has(strictOwnersFlagName) {
+		strictOwners = ctx.String(strictOwnersFlagName)
+	}
+	extraOwnersMode, err := spec.ParseExtraOwnersMode(strictOwners)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value for flag %s", strictOwnersFlagName)
+	}
 
-	return []spec.Analyzer{
-		spec.NewDescriptionAnalyzer(),
-		spec.NewOwnersAnalyzer(),
-		spec.NewLicenseAnalyzer(params.CachingOAuthGitHubClient(), authorName),
-		spec.NewHasPatentsAnalyzer(),
+	var patentsTemplate string
+	if ctx.Has(patentsTemplateFlagName) {
+		bytes, err := ioutil.ReadFile(ctx.String(patentsTemplateFlagName))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read patents template")
+		}
+		patentsTemplate = string(bytes)
 	}
+
+	var labelSets map[string][]repository.Label
+	if ctx.Has(labelSetsFlagName) {
+		bytes, err := ioutil.ReadFile(ctx.String(labelSetsFlagName))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read label sets")
+		}
+		if err := yaml.Unmarshal(bytes, &labelSets); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal label sets")
+		}
+	}
+	deleteLabels := ctx.Has(deleteLabelsFlagName) && ctx.Bool(deleteLabelsFlagName)
+
+	headerTemplates, err := license.LoadHeaderTemplates(ctx.String(headerTemplatesFlagName))
+	if err != nil {
+		return nil, err
+	}
+
+	var plugins []spec.Plugin
+	if ctx.Has(pluginsFlagName) {
+		bytes, err := ioutil.ReadFile(ctx.String(pluginsFlagName))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read plugins")
+		}
+		if err := yaml.Unmarshal(bytes, &plugins); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal plugins")
+		}
+	}
+
+	client := params.CachingOAuthGitHubClient()
+	analyzers := []spec.Analyzer{
+		spec.NewDescriptionAnalyzer(client),
+		spec.NewOwnersAnalyzer(client, extraOwnersMode),
+		spec.NewLicenseAnalyzer(client, authorName),
+		spec.NewHasPatentsAnalyzer(client, patentsTemplate),
+		spec.NewSettingsAnalyzer(client),
+		spec.NewBranchProtectionAnalyzer(client),
+		spec.NewTeamsAnalyzer(client),
+		spec.NewLabelsAnalyzer(client, labelSets, deleteLabels),
+		spec.NewFilesAnalyzer(client, ctx.String(fileTemplatesFlagName)),
+		spec.NewCodeOwnersAnalyzer(client),
+		spec.NewHooksAnalyzer(client),
+		spec.NewHeadersAnalyzer(client, authorName, headerTemplates),
+	}
+	return spec.AddPluginAnalyzers(analyzers, client, plugins)
 }
 
-func doCreateSpec(params common.GitHubRepositoryParams, repos []string, outputFile string, stdout io.Writer) error {
+// doCreateSpec writes the definitions of the provided repositories to outputFile. If the provided context is cancelled,
+// no definitions are written because they would not include all of the repositories.
+func doCreateSpec(ctx context.Context, params common.GitHubRepositoryParams, repos []string, outputFile string, stdout io.Writer) error {
 	client := params.CachingOAuthGitHubClient()
+	fetcher := params.InfoFetcher(client)
 	var defs []repository.Definition
-	if err := params.ProcessRepos(client, repos, func(repo *github.Repository, progress repository.Progress) error {
+	var defsMutex sync.Mutex
+	err := params.ProcessRepos(ctx, client, fetcher, repos, stdout, func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
 		fmt.Fprintf(stdout, "Generating definition for %s...", *repo.FullName)
 		defer func() {
 			fmt.Fprintln(stdout)
 		}()
-		info, err := repository.GetInfo(client, repo)
+		// all of the sections are retrieved because they are all part of the generated definition
+		info, err := fetcher.GetInfo(ctx, repo, repository.AllSections)
 		if err != nil {
 			fmt.Fprintf(stdout, "failed")
 			return err
 		}
+		defsMutex.Lock()
 		defs = append(defs, info.ToDefinition())
+		defsMutex.Unlock()
 		fmt.Fprintf(stdout, "done")
 		return nil
-	}); err != nil {
+	})
+	if ctx.Err() != nil {
+		return errors.Errorf("%s: no definitions were written", interruptedMessage(len(defs)))
+	}
+	if err != nil {
 		return err
 	}
 	sort.Sort(repository.DefinitionSlice(defs))
@@ -153,143 +405,187 @@ func doCreateSpec(params common.GitHubRepositoryParams, repos []string, outputFi
 	return nil
 }
 
+func doPrintDefinition(repoSpec *repository.Spec, fullName string, stdout io.Writer) error {
+	def, ok, err := repoSpec.Definition(fullName)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Errorf("no definition for repository %s", fullName)
+	}
+	bytes, err := yaml.Marshal(def)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %v as YML", def)
+	}
+	fmt.Fprint(stdout, string(bytes))
+	return nil
+}
+
+func doLintSpec(specFile string, stdout io.Writer) error {
+	problems, err := spec.Lint(specFile)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(stdout, problem)
+	}
+	switch len(problems) {
+	case 0:
+		fmt.Fprintln(stdout, "No problems found")
+		return nil
+	case 1:
+		return errors.Errorf("1 problem found")
+	default:
+		return errors.Errorf("%d problems found", len(problems))
+	}
+}
+
 type specMode int
 
 const (
 	verifyMode specMode = iota
 	applyMode
+	planMode
 )
 
-func processSpec(params common.GitHubRepositoryParams, repos []string, specFile string, analyzers []spec.Analyzer, mode specMode, prompt bool, stdout io.Writer) error {
-	bytes, err := ioutil.ReadFile(specFile)
-	if err != nil {
-		return errors.Wrapf(err, "failed to read %s", specFile)
-	}
-
-	var defs []repository.Definition
-	if err := yaml.Unmarshal(bytes, &defs); err != nil {
-		return errors.Wrapf(err, "failed to unmarshal %s", string(bytes))
+// processSpec verifies the repositories against their definitions in the provided specification. In applyMode, the
+// differences are fixed. In planMode, the changes that would be made are written as a plan to planFile. If the provided
+// context is cancelled, the repositories that have not been processed yet are skipped, the summary covers only the
+// repositories that were processed and the plan is not written.
+func processSpec(ctx context.Context, params common.GitHubRepositoryParams, repos []string, repoSpec *repository.Spec, analyzers []spec.Analyzer, mode specMode, prompt bool, planFile string, stdout io.Writer) error {
+	// if repositories of multiple owners are processed, output refers to repositories by their full names and the
+	// summaries of unexpected and missing repositories are grouped by owner
+	multipleOwners := len(params.Owners()) > 1
+	ownersSet := make(map[string]struct{})
+	for _, owner := range params.Owners() {
+		ownersSet[strings.ToLower(owner)] = struct{}{}
 	}
 
-	defsMap := make(map[string]repository.Definition, len(defs))
-	missingReposSet := make(map[string]struct{}, len(defs)) // in definition file but not in GitHub
-	for _, def := range defs {
-		defsMap[def.FullName] = def
-		missingReposSet[def.FullName] = struct{}{}
+	// in definition file but not in GitHub (keys are lower case because names are compared case-insensitively and
+	// values are the names used by the definitions)
+	missingReposSet := make(map[string]string)
+	for _, name := range repoSpec.Names() {
+		if _, ok := ownersSet[strings.ToLower(repoOwner(name))]; ok {
+			missingReposSet[strings.ToLower(name)] = name
+		}
 	}
 
 	var unexpectedRepos []string               // not in definition file but in GitHub
 	diffRepos := make(map[string]string)       // repos that exist but differ from definition
 	var okRepos []string                       // repos that match specification
-	var fixedRepos []string                    // repos successfully fixed
+	fixedRepos := make(map[string]string)      // repos successfully fixed (value is the differences that were fixed)
 	failedToFixRepos := make(map[string]error) // repos not successfully fixed (value is error encountered)
+	var plan spec.Plan                         // changes for repos that differ from definition (planMode only)
+	var resultsMutex sync.Mutex                // guards the results above (repositories may be processed concurrently)
+
+	if prompt && mode == applyMode && !params.DryRun() && params.Parallelism() > 1 {
+		return errors.Errorf("--%s cannot be used if the parallelism is greater than 1", common.PromptFlagName)
+	}
 
 	client := params.CachingOAuthGitHubClient()
-	if err := params.ProcessRepos(client, repos, func(repo *github.Repository, progress repository.Progress) error {
-		fmt.Fprintf(stdout, "Verifying repository %s against definition (%v)...", *repo.Name, progress)
+	fetcher := params.InfoFetcher(client)
+	err := params.ProcessRepos(ctx, client, fetcher, repos, stdout, func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
+		repoName := *repo.Name
+		if multipleOwners {
+			repoName = *repo.FullName
+		}
+		fmt.Fprintf(stdout, "Verifying repository %s against definition (%v)...", repoName, progress)
 
-		wantDef, ok := defsMap[*repo.FullName]
+		wantDef, ok, err := repoSpec.Definition(*repo.FullName)
+		if err != nil {
+			fmt.Fprintln(stdout, "invalid definition")
+			return err
+		}
 		if !ok {
+			resultsMutex.Lock()
 			unexpectedRepos = append(unexpectedRepos, *repo.FullName)
+			resultsMutex.Unlock()
 			fmt.Fprintln(stdout, "no definition for repository")
 			return nil
 		}
 
-		info, err := repository.GetInfo(client, repo)
+		info, err := fetcher.GetInfo(ctx, repo, spec.InfoSections(wantDef, analyzers))
 		if err != nil {
 			fmt.Fprintln(stdout, "failed to get repository info")
 			return err
 		}
 
-		delete(missingReposSet, *repo.FullName)
-
-		var diffs []string
-		for _, analyzer := range analyzers {
-			diff := analyzer.Diff(wantDef, info)
-			if diff != "" {
-				diffs = append(diffs, diff)
-			}
-		}
+		resultsMutex.Lock()
+		delete(missingReposSet, strings.ToLower(*repo.FullName))
+		resultsMutex.Unlock()
 
-		if len(diffs) == 0 {
+		repoPlan := spec.NewRepositoryPlan(ctx, wantDef, info, analyzers)
+		if len(repoPlan.Changes) == 0 {
+			resultsMutex.Lock()
 			okRepos = append(okRepos, *repo.FullName)
+			resultsMutex.Unlock()
 			fmt.Fprintln(stdout, "OK")
 			return nil
 		}
 
+		var diffs []string
+		for _, change := range repoPlan.Changes {
+			diffs = append(diffs, change.Diff)
+		}
+		resultsMutex.Lock()
 		diffRepos[*repo.FullName] = strings.Join(diffs, "\n")
+		if mode == planMode {
+			plan.Repositories = append(plan.Repositories, repoPlan)
+		}
+		resultsMutex.Unlock()
 		fmt.Fprintln(stdout, "differs from definition")
-
 		if mode != applyMode {
 			return nil
 		}
 
-		if prompt {
+		if prompt && !params.DryRun() {
 			ok, err := common.Prompt("Open PR for fix", stdout)
 			if err != nil {
 				return errors.Wrapf(err, "prompt failed")
 			}
 			if !ok {
 				// if user is given prompt and provides non-"Yes" response, skip
+				resultsMutex.Lock()
 				failedToFixRepos[*repo.FullName] = errors.Errorf("user skipped fix")
+				resultsMutex.Unlock()
 				return nil
 			}
 		}
 
-		for _, analyzer := range analyzers {
-			if !analyzer.CanFix() {
-				continue
-			}
-			fmt.Fprintf(stdout, "Fixing %s for repository %s...", analyzer.Name(), *repo.FullName)
-
-			if err := analyzer.Fix(wantDef, info, stdout); err != nil {
-				failedToFixRepos[*repo.FullName] = err
-				fmt.Fprintln(stdout, "failed")
-				return nil
-			}
-
-			fmt.Fprintln(stdout, "OK")
+		fixedDiffs, err := fixRepository(ctx, client, repoPlan, info, analyzers, params.DryRun(), stdout)
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
+		if err != nil {
+			failedToFixRepos[*repo.FullName] = err
+			return nil
 		}
-
-		fixedRepos = append(fixedRepos, *repo.FullName)
+		fixedRepos[*repo.FullName] = fixedDiffs
 		return nil
-	}); err != nil {
+	})
+	interrupted := ctx.Err() != nil
+	if err != nil && !interrupted {
 		return err
 	}
+	if interrupted {
+		// repositories that were not listed before the interrupt are not known to be missing
+		missingReposSet = nil
+	}
+	numProcessed := len(unexpectedRepos) + len(diffRepos) + len(okRepos)
 
 	if mode == verifyMode {
 		var errMsgParts []string
 		if len(unexpectedRepos) > 0 {
-			sort.Sort(repository.CaseInsensitiveStrings(unexpectedRepos))
-			unexpectedParts := []string{fmt.Sprintf("%s without definitions:", pluralizedRepositories(len(unexpectedRepos)))}
-			unexpectedParts = append(unexpectedParts, unexpectedRepos...)
-			errMsgParts = append(errMsgParts, strings.Join(unexpectedParts, "\n\t"))
+			errMsgParts = append(errMsgParts, repositoryParts(fmt.Sprintf("%s without definitions:", pluralizedRepositories(len(unexpectedRepos))), unexpectedRepos, multipleOwners)...)
 		}
 		if len(missingReposSet) > 0 {
 			missingRepos := make([]string, 0, len(missingReposSet))
-			for k := range missingReposSet {
-				missingRepos = append(missingRepos, k)
+			for _, name := range missingReposSet {
+				missingRepos = append(missingRepos, name)
 			}
-			sort.Sort(repository.CaseInsensitiveStrings(missingRepos))
-			missingParts := []string{fmt.Sprintf("%s missing:", pluralizedRepositories(len(missingRepos)))}
-			missingParts = append(missingParts, missingRepos...)
-			errMsgParts = append(errMsgParts, strings.Join(missingParts, "\n\t"))
+			errMsgParts = append(errMsgParts, repositoryParts(fmt.Sprintf("%s missing:", pluralizedRepositories(len(missingRepos))), missingRepos, multipleOwners)...)
 		}
 		if len(diffRepos) > 0 {
-			errMsgParts = append(errMsgParts, fmt.Sprintf("%s differed from definition:", pluralizedRepositories(len(diffRepos))))
-
-			keys := make([]string, 0, len(diffRepos))
-			for k := range diffRepos {
-				keys = append(keys, k)
-			}
-			sort.Sort(repository.CaseInsensitiveStrings(keys))
-
-			for _, k := range keys {
-				errMsgParts = append(errMsgParts, fmt.Sprintf("\t%s:", k))
-				for _, v := range strings.Split(diffRepos[k], "\n") {
-					errMsgParts = append(errMsgParts, "\t\t"+v)
-				}
-			}
+			errMsgParts = append(errMsgParts, diffParts(fmt.Sprintf("%s differed from definition:", pluralizedRepositories(len(diffRepos))), diffRepos)...)
 		}
 		if len(okRepos) > 0 {
 			sort.Sort(repository.CaseInsensitiveStrings(okRepos))
@@ -297,17 +593,126 @@ func processSpec(params common.GitHubRepositoryParams, repos []string, specFile
 			okParts = append(okParts, okRepos...)
 			fmt.Fprintln(stdout, strings.Join(okParts, "\n\t"))
 		}
+		if interrupted {
+			errMsgParts = append(errMsgParts, interruptedMessage(numProcessed))
+		}
 		if len(errMsgParts) > 0 {
 			return errors.Errorf("%s", strings.Join(errMsgParts, "\n"))
 		}
 		return nil
 	}
 
+	if mode == planMode {
+		if len(diffRepos) > 0 {
+			fmt.Fprintln(stdout, strings.Join(diffParts(fmt.Sprintf("%s will be changed:", pluralizedRepositories(len(diffRepos))), diffRepos), "\n"))
+		}
+		if interrupted {
+			// a plan that does not include all of the repositories would be mistaken for a complete one
+			return errors.Errorf("%s: plan was not written", interruptedMessage(numProcessed))
+		}
+		sort.Sort(repositoryPlansByName(plan.Repositories))
+		if err := spec.WritePlan(plan, planFile); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote plan with changes for %s to %s\n", pluralizedRepositories(len(plan.Repositories)), planFile)
+		return nil
+	}
+
 	if len(missingReposSet) > 0 {
-		for k := range missingReposSet {
-			failedToFixRepos[k] = errors.Errorf("repository not present (creating repos not implemented)")
+		for _, name := range missingReposSet {
+			failedToFixRepos[name] = errors.Errorf("repository not present (creating repos not implemented)")
 		}
 	}
+	summaryErr := printApplySummary(okRepos, fixedRepos, failedToFixRepos, params.DryRun(), stdout)
+	if interrupted {
+		return interruptedError(numProcessed, summaryErr)
+	}
+	return summaryErr
+}
+
+// fixRepository runs the fixes of the analyzers for the fixable changes in the provided plan. The file changes of all
+// of the analyzers that implement spec.FileChangeAnalyzer are combined and applied using a single PR, while the other
+// analyzers make their fixes directly. Returns the differences that were fixed. Returns an error if any fix fails or if
+// no analyzer can fix the differences. If the provided context is done, the remaining fixes are abandoned. If dryRun is
+// true, the PR with the combined file changes is printed instead of being opened. The other analyzers still run their
+// fixes, which stop at the first request that would modify the repository because the client is a dry run client in
+// that case.
+func fixRepository(ctx context.Context, client *github.Client, repoPlan spec.RepositoryPlan, info repository.Info, analyzers []spec.Analyzer, dryRun bool, stdout io.Writer) (string, error) {
+	analyzersByName := make(map[string]spec.Analyzer)
+	for _, analyzer := range analyzers {
+		analyzersByName[analyzer.Name()] = analyzer
+	}
+
+	// only run fixes for analyzers that reported a difference
+	var fixedDiffs []string
+	var fixErrs []string
+	var changeSet spec.ChangeSet
+	var abandonErr error
+	for _, change := range repoPlan.Changes {
+		if abandonErr = ctx.Err(); abandonErr != nil {
+			break
+		}
+		analyzer, ok := analyzersByName[change.Analyzer]
+		if !ok || !change.CanFix || !analyzer.CanFix() {
+			continue
+		}
+
+		if fileAnalyzer, ok := analyzer.(spec.FileChangeAnalyzer); ok {
+			fmt.Fprintf(stdout, "Determining file changes of %s for repository %s...", analyzer.Name(), repoPlan.FullName)
+			changes, err := change.FileChanges(ctx, fileAnalyzer, repoPlan.Definition, info)
+			if len(changes.Changes) > 0 {
+				if addErr := changeSet.Add(analyzer.Name(), changes); addErr != nil {
+					err = addErr
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(stdout, "failed")
+				fixErrs = append(fixErrs, fmt.Sprintf("%s: %v", analyzer.Name(), err))
+				continue
+			}
+			fmt.Fprintln(stdout, "OK")
+			fixedDiffs = append(fixedDiffs, change.Diff)
+			continue
+		}
+
+		fmt.Fprintf(stdout, "Fixing %s for repository %s...", analyzer.Name(), repoPlan.FullName)
+		if err := analyzer.Fix(ctx, repoPlan.Definition, info, stdout); err != nil {
+			if repository.IsDryRunError(err) {
+				// fixes that call the API directly stop at the first request that would modify the repository
+				fmt.Fprintf(stdout, "not applied (%v)\n", errors.Cause(err))
+				fixedDiffs = append(fixedDiffs, change.Diff)
+				continue
+			}
+			fmt.Fprintln(stdout, "failed")
+			fixErrs = append(fixErrs, fmt.Sprintf("%s: %v", analyzer.Name(), err))
+			continue
+		}
+		fmt.Fprintln(stdout, "OK")
+		fixedDiffs = append(fixedDiffs, change.Diff)
+	}
+
+	if !changeSet.Empty() && abandonErr == nil {
+		fmt.Fprintf(stdout, "Opening pull request with file changes of %s for repository %s\n", strings.Join(changeSet.Analyzers(), ", "), repoPlan.FullName)
+		if err := changeSet.Apply(ctx, client, info, dryRun, stdout); err != nil {
+			fixErrs = append(fixErrs, fmt.Sprintf("failed to open pull request: %v", err))
+		}
+	}
+	if abandonErr != nil {
+		fixErrs = append(fixErrs, fmt.Sprintf("remaining fixes were abandoned: %v", abandonErr))
+	}
+
+	if len(fixErrs) > 0 {
+		return "", errors.Errorf("%s", strings.Join(fixErrs, "\n"))
+	}
+	if len(fixedDiffs) == 0 {
+		return "", errors.Errorf("no analyzer can fix the differences")
+	}
+	return strings.Join(fixedDiffs, "\n"), nil
+}
+
+// printApplySummary prints the repositories that were OK and that were fixed (or that would have been fixed if dryRun is
+// true) and returns an error that lists the repositories that could not be fixed (if any).
+func printApplySummary(okRepos []string, fixedRepos map[string]string, failedToFixRepos map[string]error, dryRun bool, stdout io.Writer) error {
 	if len(okRepos) > 0 {
 		sort.Sort(repository.CaseInsensitiveStrings(okRepos))
 		okParts := []string{fmt.Sprintf("%s OK", pluralizedRepositories(len(okRepos)))}
@@ -315,9 +720,11 @@ func processSpec(params common.GitHubRepositoryParams, repos []string, specFile
 		fmt.Fprintln(stdout, strings.Join(okParts, "\n\t"))
 	}
 	if len(fixedRepos) > 0 {
-		fixedParts := []string{fmt.Sprintf("%s fixed", pluralizedRepositories(len(okRepos)))}
-		fixedParts = append(fixedParts, fixedRepos...)
-		fmt.Fprintln(stdout, strings.Join(fixedParts, "\n\t"))
+		header := fmt.Sprintf("%s fixed:", pluralizedRepositories(len(fixedRepos)))
+		if dryRun {
+			header = fmt.Sprintf("%s would be fixed (dry run):", pluralizedRepositories(len(fixedRepos)))
+		}
+		fmt.Fprintln(stdout, strings.Join(diffParts(header, fixedRepos), "\n"))
 	}
 	if len(failedToFixRepos) > 0 {
 		failedToFixKeys := make([]string, 0, len(failedToFixRepos))
@@ -328,13 +735,90 @@ func processSpec(params common.GitHubRepositoryParams, repos []string, specFile
 
 		failedParts := []string{fmt.Sprintf("Failed to fix %s:", pluralizedRepositories(len(failedToFixKeys)))}
 		for _, k := range failedToFixKeys {
-			failedParts = append(failedParts, "%s: %s", k, failedToFixRepos[k].Error())
+			failedParts = append(failedParts, fmt.Sprintf("%s: %s", k, failedToFixRepos[k].Error()))
 		}
 		return errors.Errorf("%s", strings.Join(failedParts, "\n\t"))
 	}
 	return nil
 }
 
+// interruptedMessage returns the message that reports that the command was interrupted after processing the provided
+// number of repositories.
+func interruptedMessage(numProcessed int) string {
+	return fmt.Sprintf("Interrupted after processing %s", pluralizedRepositories(numProcessed))
+}
+
+// interruptedError returns the error for a command that was interrupted after processing the provided number of
+// repositories. summaryErr is the error that describes the results of the processed repositories (nil if there were no
+// failures).
+func interruptedError(numProcessed int, summaryErr error) error {
+	if summaryErr != nil {
+		return errors.Errorf("%v\n%s", summaryErr, interruptedMessage(numProcessed))
+	}
+	return errors.Errorf("%s", interruptedMessage(numProcessed))
+}
+
+// diffParts returns the lines for a summary that consists of the provided header followed by the entries of the
+// provided map sorted by key. Each key is indented once and each line of its value is indented twice.
+func diffParts(header string, diffs map[string]string) []string {
+	keys := make([]string, 0, len(diffs))
+	for k := range diffs {
+		keys = append(keys, k)
+	}
+	sort.Sort(repository.CaseInsensitiveStrings(keys))
+
+	parts := []string{header}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("\t%s:", k))
+		for _, v := range strings.Split(diffs[k], "\n") {
+			parts = append(parts, "\t\t"+v)
+		}
+	}
+	return parts
+}
+
+// repositoryParts returns the lines for a summary that consists of the provided header followed by the provided full
+// names of repositories sorted case-insensitively. If byOwner is true, the repositories are grouped by owner in the same
+// format as diffParts.
+func repositoryParts(header string, repos []string, byOwner bool) []string {
+	sort.Sort(repository.CaseInsensitiveStrings(repos))
+	if !byOwner {
+		return []string{strings.Join(append([]string{header}, repos...), "\n\t")}
+	}
+	reposByOwner := make(map[string]string)
+	for _, repo := range repos {
+		owner := repoOwner(repo)
+		if reposByOwner[owner] != "" {
+			reposByOwner[owner] += "\n"
+		}
+		reposByOwner[owner] += repo
+	}
+	return diffParts(header, reposByOwner)
+}
+
+// repositoryPlansByName sorts repository plans case-insensitively by the full names of the repositories.
+type repositoryPlansByName []spec.RepositoryPlan
+
+func (p repositoryPlansByName) Len() int {
+	return len(p)
+}
+
+func (p repositoryPlansByName) Less(i, j int) bool {
+	return strings.ToLower(p[i].FullName) < strings.ToLower(p[j].FullName)
+}
+
+func (p repositoryPlansByName) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// repoOwner returns the owner part of the provided full name of a repository.
+func repoOwner(fullName string) string {
+	if idx := strings.Index(fullName, "/"); idx != -1 {
+		return fullName[:idx]
+	}
+	return fullName
+}
+
 func pluralizedRepositories(num int) string {
 	str := fmt.Sprintf("%d", num)
 	if num == 1 {
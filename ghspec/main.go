@@ -22,7 +22,9 @@ func main() {
 		common.RateLimit(),
 		cmd.CreateSpec(),
 		cmd.VerifySpec(),
+		cmd.PlanSpec(),
 		cmd.ApplySpec(),
+		cmd.LintSpec(),
 	}
-	os.Exit(app.Run(os.Args))
+	os.Exit(app.Run(common.JoinRepeatedFlags(app.Command, os.Args)))
 }
@@ -5,8 +5,15 @@
 package license
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
@@ -15,30 +22,50 @@ import (
 )
 
 type PRParams struct {
-	Branch string
-	Title  string
-	Body   string
+	Branch        string
+	Title         string
+	Body          string
+	CommitMessage string // message for the commit that contains the changes (if empty, Title is used)
+	// DryRun is true if the changes should be printed instead of made, in which case ApplyFileChanges only makes read
+	// requests.
+	DryRun bool
 }
 
 func DefaultPRParams(licenseName string) PRParams {
 	return PRParams{
-		Branch: "cli-update-license",
-		Title:  "Update LICENSE",
-		Body:   fmt.Sprintf("Use standard version of %s.", licenseName),
+		Branch:        "cli-update-license",
+		Title:         "Update LICENSE",
+		Body:          fmt.Sprintf("Use standard version of %s.", licenseName),
+		CommitMessage: "Update license",
 	}
 }
 
+// FileChange represents a change to a single file in a repository.
+type FileChange struct {
+	Path    string // path of the file relative to the root of the repository
+	Content string // desired content of the file (ignored if Delete is true)
+	Delete  bool   // if true, the file is removed from the repository
+	Mode    string // Git file mode of the file (defaults to "100644")
+}
+
+func (c FileChange) mode() string {
+	if c.Mode == "" {
+		return "100644"
+	}
+	return c.Mode
+}
+
 // ApplyStandard applies the standard license of the specified type to the specified repository. Calls Create to get the
 // content of the license and calls Apply to apply that license to the repository. copyrightAuthor is used as the author
 // name for the license if it uses an author template. If the license uses an author template, then the creation year of
 // the repository is used as the creation year and the last update year of the repository is used as the update year.
 // See documentation for the Apply function for further information.
-func ApplyStandard(client *github.Client, repo repository.Info, licenseType, copyrightAuthor string, prParams PRParams, cache Cache, stdout io.Writer) error {
-	wantLicenseContent, err := Create(licenseType, cache, NewAuthorInfo(copyrightAuthor, repo.CreatedAt.Time.Year(), repo.UpdatedAt.Time.Year()))
+func ApplyStandard(ctx context.Context, client *github.Client, repo repository.Info, licenseType, copyrightAuthor string, prParams PRParams, cache Cache, stdout io.Writer) error {
+	wantLicenseContent, err := Create(ctx, licenseType, cache, NewAuthorInfo(copyrightAuthor, repo.CreatedAt.Time.Year(), repo.UpdatedAt.Time.Year()))
 	if err != nil {
 		return err
 	}
-	return Apply(client, repo, wantLicenseContent, prParams, stdout)
+	return Apply(ctx, client, repo, wantLicenseContent, prParams, stdout)
 }
 
 // Apply applies the provided license content to the repository by opening a PR on the repository to modify the content
@@ -46,13 +73,39 @@ func ApplyStandard(client *github.Client, repo repository.Info, licenseType, cop
 // the repository, a PR is created directly on the repository, otherwise, a PR is created on a fork of the repository
 // (and a fork is created if it does not already exist). prParams is used to specify the behavior of how the PR is
 // created (branch name, commit title, commit body, etc.).
-func Apply(client *github.Client, repo repository.Info, licenseContent string, prParams PRParams, stdout io.Writer) error {
-	defaultBranch, _, err := client.Repositories.GetBranch(*repo.Owner.Login, *repo.Name, *repo.DefaultBranch)
-	if err != nil {
+func Apply(ctx context.Context, client *github.Client, repo repository.Info, licenseContent string, prParams PRParams, stdout io.Writer) error {
+	return ApplyFileChanges(ctx, client, repo, []FileChange{
+		{
+			Path:    *repo.RepoLicense.Path,
+			Content: licenseContent,
+		},
+	}, prParams, stdout)
+}
+
+// ApplyFileChanges opens a PR on the repository that makes the provided file changes in a single commit on top of the
+// default branch. The PR is opened from the repository or from a fork of the repository in the same manner as Apply.
+// If the branch specified by prParams already exists (for example, because it was created by a previous call), it is
+// force-updated to the new commit, and if there is already an open PR from the branch, its title and body are updated
+// instead of opening another PR. If the commit of the existing branch already has the same content as the new commit,
+// the branch is left as it is, and the open PR is only updated if its title or body differ. This makes it safe to call
+// ApplyFileChanges repeatedly for the same repository.
+// If prParams.DryRun is true, only the read requests are made and the files, target repository, branch, commit message
+// and PR that would be created are printed instead.
+//
+// If the provided context is done before the branch is created or updated, the requests in progress are cancelled, the
+// error of the context is returned and the branch is left as it was. Once the branch has been created or updated, the PR
+// is created or updated regardless of the context so that a branch is never left without a PR.
+func ApplyFileChanges(ctx context.Context, client *github.Client, repo repository.Info, changes []FileChange, prParams PRParams, stdout io.Writer) error {
+	dryRun := prParams.DryRun
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var defaultBranch github.Branch
+	if _, err := repository.Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/branches/%v", *repo.Owner.Login, *repo.Name, *repo.DefaultBranch), nil, &defaultBranch); err != nil {
 		return errors.Wrapf(err, "failed to get default branch for %s", *repo.Name)
 	}
 
-	latestCommit, _, err := client.Git.GetCommit(*repo.Owner.Login, *repo.Name, *defaultBranch.Commit.SHA)
+	latestCommit, err := getCommit(ctx, client, &repo.Repository, *defaultBranch.Commit.SHA)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get latest commit for branch %s", *defaultBranch.Name)
 	}
@@ -64,18 +117,30 @@ func Apply(client *github.Client, repo repository.Info, licenseContent string, p
 		prRepo = &repo.Repository
 	} else {
 		// user cannot push to repository -- find or create fork
-		if userForkRepo, err := repository.GetUserFork(client, &repo.Repository); err != nil {
+		if userForkRepo, err := repository.GetUserFork(ctx, client, &repo.Repository); err != nil {
 			return errors.Wrapf(err, "failed to get fork of repository %s for current authenticated user", *repo.Name)
 		} else if userForkRepo != nil {
 			// user fork of desired directory already exists -- use it
 			fmt.Fprintf(stdout, "User does not have push permissions to repository, but has an existing fork\n")
 			prRepo = userForkRepo
+		} else if dryRun {
+			fmt.Fprintf(stdout, "User does not have push permissions to repository and does not have an existing fork\n")
+			user := &github.User{}
+			if _, err := repository.Do(ctx, client, "GET", "user", nil, user); err != nil {
+				return errors.Wrapf(err, "failed to get current authenticated user")
+			}
+			// fork does not exist yet, so it is represented by its owner and name
+			prRepo = &github.Repository{
+				Owner:    user,
+				Name:     repo.Name,
+				FullName: github.String(*user.Login + "/" + *repo.Name),
+			}
 		} else {
 			// user fork of desired repository does not exist -- create it
 			fmt.Fprintf(stdout, "User does not have push permissions to repository and does not have an existing fork\n")
 
 			fmt.Fprintf(stdout, "Forking repository...")
-			newForkedRepo, err := repository.CreateFork(client, &repo.Repository, 0)
+			newForkedRepo, err := repository.CreateFork(ctx, client, &repo.Repository, 0)
 			if err != nil {
 				return errors.Wrapf(err, "failed to create fork of repository %s for current authenticated user", *repo.Name)
 			}
@@ -84,61 +149,370 @@ func Apply(client *github.Client, repo repository.Info, licenseContent string, p
 		}
 	}
 
-	fmt.Fprintf(stdout, "Creating tree...")
-	createdTree, _, err := client.Git.CreateTree(*prRepo.Owner.Login, *prRepo.Name, *latestCommit.Tree.SHA, []github.TreeEntry{
-		{
-			Path:    repo.RepoLicense.Path,
-			Mode:    github.String("100644"),
-			Type:    github.String("blob"),
-			Content: github.String(licenseContent),
-		},
-	})
-	if err != nil {
-		return errors.Wrapf(err, "failed to create tree")
+	commitMessage := prParams.CommitMessage
+	if commitMessage == "" {
+		commitMessage = prParams.Title
 	}
-	fmt.Fprintf(stdout, "OK\n")
 
-	fmt.Fprintf(stdout, "Creating commit...")
-	createdCommit, _, err := client.Git.CreateCommit(*prRepo.Owner.Login, *prRepo.Name, &github.Commit{
-		Message: github.String("Update license"),
-		Parents: []github.Commit{
-			*defaultBranch.Commit,
-		},
-		Tree: createdTree,
-	})
+	isFork := prRepo != &repo.Repository
+	prBranchName := prParams.Branch
+	if isFork {
+		// if repo is a fork, prepend branch name with "username:"
+		prBranchName = *prRepo.Owner.Login + ":" + prBranchName
+	}
+
+	// a PR opened by a previous run is updated rather than duplicated
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	branchSHA, err := getBranchSHA(ctx, client, prRepo, prParams.Branch)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create commit")
+		return err
+	}
+	branchExists := branchSHA != ""
+	existingPR, err := getOpenPR(ctx, client, repo, *prRepo.Owner.Login+":"+prParams.Branch)
+	if err != nil {
+		return err
 	}
-	fmt.Fprintf(stdout, "OK\n")
 
-	fmt.Fprintf(stdout, "Creating branch...")
-	_, _, err = client.Git.CreateRef(*prRepo.Owner.Login, *prRepo.Name, &github.Reference{
-		Ref: github.String("refs/heads/" + prParams.Branch),
-		Object: &github.GitObject{
-			SHA: createdCommit.SHA,
-		},
-	})
+	if dryRun {
+		printDryRun(repo, prRepo, isFork, changes, prParams, commitMessage, prBranchName, *defaultBranch.Name, branchExists, existingPR, stdout)
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Creating tree...")
+	createdTree, err := createTree(ctx, client, repo, prRepo, *latestCommit.Tree.SHA, changes)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create reference")
+		return err
 	}
 	fmt.Fprintf(stdout, "OK\n")
 
-	prBranchName := prParams.Branch
-	if *repo.ID != *prRepo.ID {
-		// if repo is a fork, prepend branch name with "username:"
-		prBranchName = *prRepo.Owner.Login + ":" + prBranchName
+	upToDate := false
+	if branchExists {
+		branchCommit, err := getCommit(ctx, client, prRepo, branchSHA)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get latest commit for branch %s", prParams.Branch)
+		}
+		upToDate = *branchCommit.Tree.SHA == *createdTree.SHA
+	}
+	if upToDate {
+		// force-pushing an identical commit would only add noise to the PR
+		fmt.Fprintf(stdout, "Branch %s already contains the changes\n", prParams.Branch)
+	} else if err := pushCommit(ctx, client, prRepo, prParams.Branch, branchExists, commitMessage, defaultBranch.Commit, createdTree, stdout); err != nil {
+		return err
+	}
+
+	// the branch has been pushed, so the PR requests are not cancelled with the provided context: interrupting at this
+	// point would leave a branch without a PR (a second interrupt still exits immediately)
+	prCtx := detachedContext{ctx}
+
+	if existingPR != nil {
+		if upToDate && existingPR.Title != nil && *existingPR.Title == prParams.Title && existingPR.Body != nil && *existingPR.Body == prParams.Body {
+			fmt.Fprintf(stdout, "Pull request #%d is up to date\n", *existingPR.Number)
+			return nil
+		}
+		fmt.Fprintf(stdout, "Updating pull request #%d...", *existingPR.Number)
+		if _, err := repository.Do(prCtx, client, "PATCH", fmt.Sprintf("repos/%v/%v/pulls/%d", *repo.Owner.Login, *repo.Name, *existingPR.Number), &github.PullRequest{
+			Title: github.String(prParams.Title),
+			Body:  github.String(prParams.Body),
+		}, nil); err != nil {
+			return errors.Wrapf(err, "failed to update PR #%d", *existingPR.Number)
+		}
+		fmt.Fprintf(stdout, "OK\n")
+		return nil
 	}
 
 	fmt.Fprintf(stdout, "Creating pull request...")
-	_, _, err = client.PullRequests.Create(*repo.Owner.Login, *repo.Name, &github.NewPullRequest{
+	if _, err := repository.Do(prCtx, client, "POST", fmt.Sprintf("repos/%v/%v/pulls", *repo.Owner.Login, *repo.Name), &github.NewPullRequest{
 		Title: github.String(prParams.Title),
 		Body:  github.String(prParams.Body),
 		Head:  &prBranchName,
 		Base:  defaultBranch.Name,
-	})
-	if err != nil {
+	}, nil); err != nil {
 		return errors.Wrapf(err, "failed to create PR")
 	}
 	fmt.Fprintf(stdout, "OK\n")
 	return nil
 }
+
+// pushCommit creates a commit with the provided tree on top of the provided parent in prRepo and points the branch with
+// the provided name at it. An existing branch is force-updated and a branch that does not exist is created. If the
+// provided context is done before the branch is created or updated, the error of the context is returned.
+func pushCommit(ctx context.Context, client *github.Client, prRepo *github.Repository, branch string, branchExists bool, commitMessage string, parent *github.Commit, tree *github.Tree, stdout io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Creating commit...")
+	var createdCommit github.Commit
+	if _, err := repository.Do(ctx, client, "POST", fmt.Sprintf("repos/%v/%v/git/commits", *prRepo.Owner.Login, *prRepo.Name), struct {
+		Message string   `json:"message"`
+		Tree    string   `json:"tree"`
+		Parents []string `json:"parents"`
+	}{
+		Message: commitMessage,
+		Tree:    *tree.SHA,
+		Parents: []string{*parent.SHA},
+	}, &createdCommit); err != nil {
+		return errors.Wrapf(err, "failed to create commit")
+	}
+	fmt.Fprintf(stdout, "OK\n")
+
+	// the tree and commit are not reachable until the branch points to them, so this is the last point at which the
+	// changes can be abandoned
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if branchExists {
+		// branch was created by a previous run, so it is reset to the new commit
+		fmt.Fprintf(stdout, "Updating branch...")
+		if _, err := repository.Do(ctx, client, "PATCH", fmt.Sprintf("repos/%v/%v/git/refs/heads/%v", *prRepo.Owner.Login, *prRepo.Name, branch), struct {
+			SHA   string `json:"sha"`
+			Force bool   `json:"force"`
+		}{
+			SHA:   *createdCommit.SHA,
+			Force: true,
+		}, nil); err != nil {
+			return errors.Wrapf(err, "failed to update reference")
+		}
+	} else {
+		fmt.Fprintf(stdout, "Creating branch...")
+		if _, err := repository.Do(ctx, client, "POST", fmt.Sprintf("repos/%v/%v/git/refs", *prRepo.Owner.Login, *prRepo.Name), struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		}{
+			Ref: "refs/heads/" + branch,
+			SHA: *createdCommit.SHA,
+		}, nil); err != nil {
+			return errors.Wrapf(err, "failed to create reference")
+		}
+	}
+	fmt.Fprintf(stdout, "OK\n")
+	return nil
+}
+
+// getBranchSHA returns the SHA of the commit of the branch with the provided name in the provided repository, or an
+// empty string if the repository does not have such a branch.
+func getBranchSHA(ctx context.Context, client *github.Client, repo *github.Repository, branch string) (string, error) {
+	var ref github.Reference
+	resp, err := repository.Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/git/refs/heads/%v", *repo.Owner.Login, *repo.Name, branch), nil, &ref)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			// if there is no exact match, the API returns the list of references that start with the name
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to get branch %s of %s", branch, *repo.FullName)
+	}
+	if ref.Object == nil || ref.Object.SHA == nil {
+		return "", errors.Errorf("branch %s of %s does not point to a commit", branch, *repo.FullName)
+	}
+	return *ref.Object.SHA, nil
+}
+
+// getOpenPR returns the open PR on the provided repository from the provided head (of the form "owner:branch"), or nil
+// if there is no such PR.
+func getOpenPR(ctx context.Context, client *github.Client, repo repository.Info, head string) (*github.PullRequest, error) {
+	var pulls []*github.PullRequest
+	if _, err := repository.Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/pulls?state=open&head=%s", *repo.Owner.Login, *repo.Name, url.QueryEscape(head)), nil, &pulls); err != nil {
+		return nil, errors.Wrapf(err, "failed to list pull requests for %s", *repo.FullName)
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return pulls[0], nil
+}
+
+// getCommit returns the Git commit with the provided SHA in the provided repository.
+func getCommit(ctx context.Context, client *github.Client, repo *github.Repository, sha string) (*github.Commit, error) {
+	var commit github.Commit
+	if _, err := repository.Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/git/commits/%v", *repo.Owner.Login, *repo.Name, sha), nil, &commit); err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+// printDryRun prints the changes that ApplyFileChanges would make.
+func printDryRun(repo repository.Info, prRepo *github.Repository, isFork bool, changes []FileChange, prParams PRParams, commitMessage, head, base string, branchExists bool, existingPR *github.PullRequest, stdout io.Writer) {
+	target := *prRepo.FullName
+	if isFork {
+		target += " (fork of " + *repo.FullName + ")"
+	}
+	fmt.Fprintf(stdout, "Dry run: the following changes would be made\n")
+	fmt.Fprintf(stdout, "Target repository: %s\n", target)
+	if branchExists {
+		fmt.Fprintf(stdout, "Branch: %s (exists and would be force-updated)\n", prParams.Branch)
+	} else {
+		fmt.Fprintf(stdout, "Branch: %s\n", prParams.Branch)
+	}
+	fmt.Fprintf(stdout, "Commit message: %s\n", commitMessage)
+	fmt.Fprintf(stdout, "Files:\n")
+	for _, change := range changes {
+		if change.Delete {
+			fmt.Fprintf(stdout, "\t%s (deleted)\n", change.Path)
+			continue
+		}
+		fmt.Fprintf(stdout, "\t%s (mode %s):\n", change.Path, change.mode())
+		for _, line := range strings.Split(strings.TrimSuffix(change.Content, "\n"), "\n") {
+			fmt.Fprintf(stdout, "\t\t%s\n", line)
+		}
+	}
+	if existingPR != nil {
+		fmt.Fprintf(stdout, "Pull request: update #%d on %s from %s into %s\n", *existingPR.Number, *repo.FullName, head, base)
+	} else {
+		fmt.Fprintf(stdout, "Pull request: %s from %s into %s\n", *repo.FullName, head, base)
+	}
+	fmt.Fprintf(stdout, "Title: %s\n", prParams.Title)
+	fmt.Fprintf(stdout, "Body:\n")
+	for _, line := range strings.Split(prParams.Body, "\n") {
+		fmt.Fprintf(stdout, "\t%s\n", line)
+	}
+}
+
+// createTree creates a tree in prRepo that applies the provided changes to the tree of repo with the provided SHA. The
+// tree creation API cannot express deletions relative to a base tree, so every directory that contains a deleted file
+// (and each of its ancestors) is rebuilt from its entries without the deleted files. The entries of a directory are
+// listed without those of its subdirectories, so the subtrees of a rebuilt directory that do not contain deleted files
+// are reused by SHA. The other changes are then applied on top of the resulting tree.
+func createTree(ctx context.Context, client *github.Client, repo repository.Info, prRepo *github.Repository, treeSHA string, changes []FileChange) (*github.Tree, error) {
+	deleted := make(map[string]bool)
+	deletedDirs := make(map[string]bool) // directories that contain a deleted file (directly or in a subdirectory)
+	var entries []github.TreeEntry
+	for _, change := range changes {
+		if change.Delete {
+			deleted[change.Path] = true
+			for dir := path.Dir(change.Path); dir != "."; dir = path.Dir(dir) {
+				deletedDirs[dir] = true
+			}
+			continue
+		}
+		entries = append(entries, github.TreeEntry{
+			Path:    github.String(change.Path),
+			Mode:    github.String(change.mode()),
+			Type:    github.String("blob"),
+			Content: github.String(change.Content),
+		})
+	}
+
+	baseTree := treeSHA
+	if len(deleted) > 0 {
+		rebuiltTree, err := rebuildTree(ctx, client, repo, prRepo, "", treeSHA, deleted, deletedDirs)
+		if err != nil {
+			return nil, err
+		}
+		if rebuiltTree == nil {
+			return nil, errors.Errorf("changes would remove every file in %s", *repo.FullName)
+		}
+		if len(entries) == 0 {
+			return rebuiltTree, nil
+		}
+		baseTree = *rebuiltTree.SHA
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	createdTree, err := postTree(ctx, client, prRepo, baseTree, entries)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create tree")
+	}
+	return createdTree, nil
+}
+
+// rebuildTree creates a tree in prRepo that consists of the entries of the tree of repo with the provided SHA (which is
+// at the provided directory) without the deleted files. Subtrees that contain deleted files are rebuilt in the same
+// manner. Returns nil if no entries remain (Git trees cannot be empty).
+func rebuildTree(ctx context.Context, client *github.Client, repo repository.Info, prRepo *github.Repository, dir, treeSHA string, deleted, deletedDirs map[string]bool) (*github.Tree, error) {
+	currEntries, err := repository.GetTree(ctx, client, &repo.Repository, treeSHA, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []github.TreeEntry
+	for _, entry := range currEntries {
+		entryPath := path.Join(dir, *entry.Path)
+		if deleted[entryPath] {
+			continue
+		}
+		sha := entry.SHA
+		if *entry.Type == "tree" && deletedDirs[entryPath] {
+			subtree, err := rebuildTree(ctx, client, repo, prRepo, entryPath, *entry.SHA, deleted, deletedDirs)
+			if err != nil {
+				return nil, err
+			}
+			if subtree == nil {
+				// directory is removed along with its last file
+				continue
+			}
+			sha = subtree.SHA
+		}
+		entries = append(entries, github.TreeEntry{
+			Path: entry.Path,
+			Mode: entry.Mode,
+			Type: entry.Type,
+			SHA:  sha,
+		})
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	createdTree, err := postTree(ctx, client, prRepo, "", entries)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create tree for %s", dirName(dir))
+	}
+	return createdTree, nil
+}
+
+// postTree creates a tree with the provided entries on top of the tree with the provided SHA (if any) in the provided
+// repository.
+func postTree(ctx context.Context, client *github.Client, repo *github.Repository, baseTree string, entries []github.TreeEntry) (*github.Tree, error) {
+	var createdTree github.Tree
+	if _, err := repository.Do(ctx, client, "POST", fmt.Sprintf("repos/%v/%v/git/trees", *repo.Owner.Login, *repo.Name), struct {
+		BaseTree string             `json:"base_tree,omitempty"`
+		Entries  []github.TreeEntry `json:"tree"`
+	}{
+		BaseTree: baseTree,
+		Entries:  entries,
+	}, &createdTree); err != nil {
+		return nil, err
+	}
+	return &createdTree, nil
+}
+
+// dirName returns the name of the provided directory for use in messages.
+func dirName(dir string) string {
+	if dir == "" {
+		return "root directory"
+	}
+	return "directory " + dir
+}
+
+// detachedContext is a context that has the values of the context that it wraps but is never cancelled and has no
+// deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
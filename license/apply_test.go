@@ -0,0 +1,268 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package license_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+func TestApplyFileChanges(t *testing.T) {
+	prParams := license.PRParams{
+		Branch: "cli-update-files",
+		Title:  "Update files",
+		Body:   "Update files to match specification.",
+	}
+	for i, currCase := range []struct {
+		name       string
+		branchTree string // tree of the commit of the existing branch (empty if the branch does not exist)
+		openPull   *github.PullRequest
+		dryRun     bool
+		want       []string
+		wantOutput string
+	}{
+		{
+			name: "branch and PR are created",
+			want: []string{
+				"POST /repos/octocat/Hello-World/git/trees",
+				"POST /repos/octocat/Hello-World/git/commits",
+				"POST /repos/octocat/Hello-World/git/refs",
+				"POST /repos/octocat/Hello-World/pulls",
+			},
+		},
+		{
+			name:       "existing branch and open PR are updated",
+			branchTree: "old-tree-sha",
+			openPull:   &github.PullRequest{Number: github.Int(7), Title: github.String("Old title"), Body: github.String("Old body")},
+			want: []string{
+				"POST /repos/octocat/Hello-World/git/trees",
+				"POST /repos/octocat/Hello-World/git/commits",
+				"PATCH /repos/octocat/Hello-World/git/refs/heads/cli-update-files",
+				"PATCH /repos/octocat/Hello-World/pulls/7",
+			},
+		},
+		{
+			name:       "PR is recreated for existing branch without open PR",
+			branchTree: "old-tree-sha",
+			want: []string{
+				"POST /repos/octocat/Hello-World/git/trees",
+				"POST /repos/octocat/Hello-World/git/commits",
+				"PATCH /repos/octocat/Hello-World/git/refs/heads/cli-update-files",
+				"POST /repos/octocat/Hello-World/pulls",
+			},
+		},
+		{
+			name:       "branch with the same content and up-to-date PR are left as they are",
+			branchTree: "new-tree-sha",
+			openPull:   &github.PullRequest{Number: github.Int(7), Title: github.String("Update files"), Body: github.String("Update files to match specification.")},
+			want: []string{
+				"POST /repos/octocat/Hello-World/git/trees",
+			},
+			wantOutput: "Branch cli-update-files already contains the changes\nPull request #7 is up to date\n",
+		},
+		{
+			name:       "PR with outdated description is updated without updating the branch",
+			branchTree: "new-tree-sha",
+			openPull:   &github.PullRequest{Number: github.Int(7), Title: github.String("Update files"), Body: github.String("Old body")},
+			want: []string{
+				"POST /repos/octocat/Hello-World/git/trees",
+				"PATCH /repos/octocat/Hello-World/pulls/7",
+			},
+		},
+		{
+			name:       "PR is recreated for branch with the same content",
+			branchTree: "new-tree-sha",
+			want: []string{
+				"POST /repos/octocat/Hello-World/git/trees",
+				"POST /repos/octocat/Hello-World/pulls",
+			},
+		},
+		{
+			name:       "dry run only reads",
+			branchTree: "old-tree-sha",
+			openPull:   &github.PullRequest{Number: github.Int(7)},
+			dryRun:     true,
+			wantOutput: "Dry run: the following changes would be made\n" +
+				"Target repository: octocat/Hello-World\n" +
+				"Branch: cli-update-files (exists and would be force-updated)\n" +
+				"Commit message: Update files\n" +
+				"Files:\n" +
+				"\tREADME.md (mode 100644):\n" +
+				"\t\t# Hello-World\n" +
+				"Pull request: update #7 on octocat/Hello-World from cli-update-files into master\n" +
+				"Title: Update files\n" +
+				"Body:\n" +
+				"\tUpdate files to match specification.\n",
+		},
+	} {
+		server := newApplyServer(t, currCase.branchTree, currCase.openPull)
+		client := github.NewClient(nil)
+		if currCase.dryRun {
+			client = repository.NewDryRunClient(nil)
+		}
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+
+		currParams := prParams
+		currParams.DryRun = currCase.dryRun
+		buf := &bytes.Buffer{}
+		err := license.ApplyFileChanges(context.Background(), client, applyRepoInfo(), []license.FileChange{
+			{Path: "README.md", Content: "# Hello-World\n"},
+		}, currParams, buf)
+		require.NoError(t, err, "Case %d (%s)", i, currCase.name)
+		assert.Equal(t, currCase.want, server.modifications, "Case %d (%s)", i, currCase.name)
+		if currCase.wantOutput != "" {
+			assert.True(t, strings.HasSuffix(buf.String(), currCase.wantOutput), "Case %d (%s): unexpected output:\n%s", i, currCase.name, buf.String())
+		}
+		server.Close()
+	}
+}
+
+func TestApplyFileChangesCreatesPullRequestAfterInterruptOncePushed(t *testing.T) {
+	server := newApplyServer(t, "", nil)
+	defer server.Close()
+
+	// the context is cancelled (as it is when the user interrupts the program) once the branch has been pushed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := github.NewClient(&http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if req.Method == "POST" && strings.HasSuffix(req.URL.Path, "/git/refs") {
+				cancel()
+			}
+			return resp, err
+		}),
+	})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	err := license.ApplyFileChanges(ctx, client, applyRepoInfo(), []license.FileChange{
+		{Path: "README.md", Content: "# Hello-World\n"},
+	}, license.PRParams{
+		Branch: "cli-update-files",
+		Title:  "Update files",
+	}, ioutil.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"POST /repos/octocat/Hello-World/git/trees",
+		"POST /repos/octocat/Hello-World/git/commits",
+		"POST /repos/octocat/Hello-World/git/refs",
+		"POST /repos/octocat/Hello-World/pulls",
+	}, server.modifications)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// applyServer is a stand-in for the GitHub API that supports the calls made by ApplyFileChanges and records the
+// requests that modify the repository as "METHOD path".
+type applyServer struct {
+	*httptest.Server
+	mutex         sync.Mutex
+	modifications []string
+}
+
+// newApplyServer returns an applyServer for a repository in which the default branch is "master". If branchTree is
+// non-empty, the repository has a branch whose commit has that tree, and openPull is the open PR from any branch.
+// Trees that are created have the SHA "new-tree-sha".
+func newApplyServer(t *testing.T, branchTree string, openPull *github.PullRequest) *applyServer {
+	s := &applyServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/octocat/Hello-World/branches/master", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Branch{
+			Name:   github.String("master"),
+			Commit: &github.Commit{SHA: github.String("master-sha")},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/commits/master-sha", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Commit{
+			SHA:  github.String("master-sha"),
+			Tree: &github.Tree{SHA: github.String("base-tree-sha")},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/commits/branch-sha", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Commit{
+			SHA:  github.String("branch-sha"),
+			Tree: &github.Tree{SHA: github.String(branchTree)},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/refs/heads/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			s.record(r)
+			testutil.WriteJSON(t, w, github.Reference{Ref: github.String(strings.TrimPrefix(r.URL.Path, "/repos/octocat/Hello-World/git/"))})
+			return
+		}
+		if branchTree == "" {
+			http.NotFound(w, r)
+			return
+		}
+		testutil.WriteJSON(t, w, github.Reference{
+			Ref:    github.String(strings.TrimPrefix(r.URL.Path, "/repos/octocat/Hello-World/git/")),
+			Object: &github.GitObject{SHA: github.String("branch-sha")},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			var pulls []github.PullRequest
+			if openPull != nil {
+				pulls = append(pulls, *openPull)
+			}
+			testutil.WriteJSON(t, w, pulls)
+			return
+		}
+		s.record(r)
+		testutil.WriteJSON(t, w, github.PullRequest{Number: github.Int(1)})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		switch r.URL.Path {
+		case "/repos/octocat/Hello-World/git/trees":
+			testutil.WriteJSON(t, w, github.Tree{SHA: github.String("new-tree-sha")})
+		case "/repos/octocat/Hello-World/git/commits":
+			testutil.WriteJSON(t, w, github.Commit{SHA: github.String("new-commit-sha")})
+		case "/repos/octocat/Hello-World/git/refs":
+			testutil.WriteJSON(t, w, github.Reference{Ref: github.String("refs/heads/cli-update-files")})
+		default:
+			testutil.WriteJSON(t, w, github.PullRequest{Number: github.Int(7)})
+		}
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *applyServer) record(r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.modifications = append(s.modifications, r.Method+" "+r.URL.Path)
+}
+
+func applyRepoInfo() repository.Info {
+	return repository.Info{
+		Repository: github.Repository{
+			Owner:         &github.User{Login: github.String("octocat")},
+			Name:          github.String("Hello-World"),
+			FullName:      github.String("octocat/Hello-World"),
+			DefaultBranch: github.String("master"),
+			Permissions:   &map[string]bool{"push": true},
+		},
+	}
+}
@@ -5,17 +5,23 @@
 package license
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"sync"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/repository"
 )
 
+const mediaTypeLicensesPreview = "application/vnd.github.drax-preview+json"
+
 type Cache interface {
 	// Get returns the content of the license with the provided key (SPDX ID). Uses the GitHub API to get the
-	// content of the license if it is not already cached.
-	Get(licenseKey string) (string, error)
+	// content of the license if it is not already cached. Safe for concurrent use.
+	Get(ctx context.Context, licenseKey string) (string, error)
 }
 
 func NewCache(client *github.Client) Cache {
@@ -27,15 +33,20 @@ func NewCache(client *github.Client) Cache {
 
 type cache struct {
 	client *github.Client
+	mutex  sync.Mutex
 	cache  map[string]string
 }
 
-func (c *cache) Get(licenseKey string) (string, error) {
+func (c *cache) Get(ctx context.Context, licenseKey string) (string, error) {
+	// lock is held while the license is retrieved so that it is only retrieved once
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if l, ok := c.cache[licenseKey]; ok {
 		return l, nil
 	}
-	l, _, err := c.client.Licenses.Get(licenseKey)
-	if err != nil {
+	var l github.License
+	if err := getLicenses(ctx, c.client, "licenses/"+licenseKey, &l); err != nil {
 		return "", errors.Wrapf(err, "failed to get license %s", licenseKey)
 	}
 	if spec, ok := licensesMap[licenseKey]; ok {
@@ -43,9 +54,28 @@ func (c *cache) Get(licenseKey string) (string, error) {
 		sha1bytes := sha1.Sum([]byte(*l.Body))
 		sha1sum := hex.EncodeToString(sha1bytes[:])
 		if spec.SHA1 != sha1sum {
-			return "", errors.Wrapf(err, "SHA-1 sums for license %s does not match: expected %s, was %s", spec.Key, spec.SHA1, sha1sum)
+			return "", errors.Errorf("SHA-1 sums for license %s does not match: expected %s, was %s", spec.Key, spec.SHA1, sha1sum)
 		}
 	}
 	c.cache[licenseKey] = *l.Body
 	return c.cache[licenseKey], nil
 }
+
+// ListLicenses returns all of the licenses known to GitHub.
+func ListLicenses(ctx context.Context, client *github.Client) ([]*github.License, error) {
+	var licenses []*github.License
+	if err := getLicenses(ctx, client, "licenses", &licenses); err != nil {
+		return nil, err
+	}
+	return licenses, nil
+}
+
+func getLicenses(ctx context.Context, client *github.Client, urlStr string, v interface{}) error {
+	req, err := repository.NewRequest(ctx, client, "GET", urlStr, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeLicensesPreview)
+	_, err = client.Do(req, v)
+	return err
+}
@@ -0,0 +1,330 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package license
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// CommentStyle describes how a header comment is written in a type of source file. Line comment styles only set Line,
+// while block comment styles also set Start and End.
+type CommentStyle struct {
+	Name       string   // name of the style used to configure header templates
+	Extensions []string // extensions (including the leading ".") of the files that use the style
+	Start      string   // first line of a block comment
+	Line       string   // prefix for each line of the header
+	End        string   // last line of a block comment
+}
+
+// CommentStyles are the supported comment styles.
+var CommentStyles = []CommentStyle{
+	{Name: "go", Extensions: []string{".go"}, Line: "// "},
+	{Name: "java", Extensions: []string{".java"}, Start: "/*", Line: " * ", End: " */"},
+	{Name: "python", Extensions: []string{".py"}, Line: "# "},
+	{Name: "shell", Extensions: []string{".sh", ".bash"}, Line: "# "},
+	{Name: "yaml", Extensions: []string{".yml", ".yaml"}, Line: "# "},
+}
+
+// defaultHeaderTemplateKey is the key of the template in HeaderTemplates that is used for comment styles that do not
+// have their own template.
+const defaultHeaderTemplateKey = "default"
+
+// HeaderTemplates is a map from the SPDX ID of a license to the header templates for the license. The templates for a
+// license are keyed by the name of a comment style or by "default". Style-specific templates are complete headers that
+// include comment markers, while the default template contains only the text of the header and is commented using the
+// style of each file. Templates are Go templates that are rendered with the fields "Year" and "Author".
+type HeaderTemplates map[string]map[string]string
+
+// DefaultHeaderTemplates returns the built-in header templates.
+func DefaultHeaderTemplates() HeaderTemplates {
+	return HeaderTemplates{
+		"mit": {
+			defaultHeaderTemplateKey: `Copyright {{.Year}} {{.Author}}. All rights reserved.
+Licensed under the MIT License. See LICENSE in the project root
+for license information.`,
+		},
+		"apache-2.0": {
+			defaultHeaderTemplateKey: `Copyright {{.Year}} {{.Author}}
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.`,
+		},
+	}
+}
+
+// yearPlaceholder is used as the year when rendering a header that is matched against existing headers so that
+// headers with any copyright year are considered correct.
+const yearPlaceholder = "\x00year\x00"
+
+var generatedRegexp = regexp.MustCompile(`(?m)^.*Code generated .* DO NOT EDIT\.$`)
+
+// Headers verifies and fixes the license headers of source files.
+type Headers struct {
+	templates map[string]string
+	author    string
+	year      int
+	styles    map[string]styleHeader
+}
+
+// styleHeader is the header for a comment style along with the regular expression that matches the header with any
+// copyright year.
+type styleHeader struct {
+	header    string
+	wantRegex *regexp.Regexp
+}
+
+// NewHeaders returns a Headers for the license with the provided SPDX ID or alias. Templates in the provided templates
+// take precedence over the default templates. Headers added by Fix use the provided author and year. The headers for
+// each comment style are rendered once, so an error is returned if any of the templates cannot be rendered.
+func NewHeaders(licenseKey string, templates HeaderTemplates, author string, year int) (*Headers, error) {
+	licenseKey = strings.ToLower(licenseKey)
+	if v, ok := aliasesMap[licenseKey]; ok {
+		licenseKey = v
+	}
+	licenseTemplates := make(map[string]string)
+	for k, v := range DefaultHeaderTemplates()[licenseKey] {
+		licenseTemplates[k] = v
+	}
+	for k, v := range templates[licenseKey] {
+		licenseTemplates[k] = v
+	}
+	if len(licenseTemplates) == 0 {
+		return nil, errors.Errorf("no header template for license %s", licenseKey)
+	}
+	h := &Headers{
+		templates: licenseTemplates,
+		author:    author,
+		year:      year,
+		styles:    make(map[string]styleHeader),
+	}
+	for _, style := range CommentStyles {
+		wantPattern, err := h.header(style, yearPlaceholder)
+		if err != nil {
+			return nil, err
+		}
+		header, err := h.header(style, fmt.Sprintf("%d", year))
+		if err != nil {
+			return nil, err
+		}
+		h.styles[style.Name] = styleHeader{
+			header:    header,
+			wantRegex: regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(wantPattern), yearPlaceholder, `\d{4}(?:-\d{4})?`, -1) + "(\n|$)"),
+		}
+	}
+	return h, nil
+}
+
+// Supports returns true if the file at the provided path uses a supported comment style.
+func (h *Headers) Supports(filePath string) bool {
+	_, ok := commentStyle(filePath)
+	return ok
+}
+
+// Fix returns the content of the file at the provided path with the correct license header and true if the content was
+// changed. If the file does not use a supported comment style, is generated or already has the correct header (with
+// any copyright year), the content is returned unchanged. An existing header comment that contains a copyright notice
+// is replaced.
+func (h *Headers) Fix(filePath, content string) (string, bool, error) {
+	style, ok := commentStyle(filePath)
+	if !ok || generatedRegexp.MatchString(content) {
+		return content, false, nil
+	}
+
+	var shebang string
+	if strings.HasPrefix(content, "#!") {
+		idx := strings.Index(content, "\n")
+		if idx == -1 {
+			idx = len(content) - 1
+		}
+		shebang, content = content[:idx+1], content[idx+1:]
+	}
+
+	styled := h.styles[style.Name]
+	if styled.wantRegex.MatchString(content) {
+		return shebang + content, false, nil
+	}
+
+	rest := strings.TrimLeft(removeCopyrightComment(style, content), "\n")
+	fixed := shebang + styled.header + "\n"
+	if rest != "" {
+		fixed += "\n" + rest
+	}
+	return fixed, true, nil
+}
+
+// Returns the commented header for the provided style rendered with the provided year.
+func (h *Headers) header(style CommentStyle, year string) (string, error) {
+	tmplContent, styled := h.templates[style.Name]
+	if !styled {
+		tmplContent = h.templates[defaultHeaderTemplateKey]
+	}
+	tmpl, err := template.New(style.Name).Parse(tmplContent)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse header template")
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, struct {
+		Year   string
+		Author string
+	}{
+		Year:   year,
+		Author: h.author,
+	}); err != nil {
+		return "", errors.Wrapf(err, "failed to render header template")
+	}
+	text := strings.TrimRight(buf.String(), "\n")
+	if styled {
+		return text, nil
+	}
+
+	var lines []string
+	if style.Start != "" {
+		lines = append(lines, style.Start)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, strings.TrimRight(style.Line+line, " "))
+	}
+	if style.End != "" {
+		lines = append(lines, style.End)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Returns the provided content with its leading comment removed if the comment contains a copyright notice.
+func removeCopyrightComment(style CommentStyle, content string) string {
+	lines := strings.SplitAfter(content, "\n")
+	n := 0
+	if style.Start != "" {
+		if len(lines) == 0 || !strings.HasPrefix(strings.TrimSpace(lines[0]), strings.TrimSpace(style.Start)) {
+			return content
+		}
+		for n < len(lines) && !strings.Contains(lines[n], "*/") {
+			n++
+		}
+		if n == len(lines) {
+			return content
+		}
+		n++
+	} else {
+		for n < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[n]), strings.TrimSpace(style.Line)) {
+			n++
+		}
+	}
+	if !strings.Contains(strings.ToLower(strings.Join(lines[:n], "")), "copyright") {
+		return content
+	}
+	return strings.Join(lines[n:], "")
+}
+
+func commentStyle(filePath string) (CommentStyle, bool) {
+	ext := path.Ext(filePath)
+	for _, style := range CommentStyles {
+		for _, styleExt := range style.Extensions {
+			if ext == styleExt {
+				return style, true
+			}
+		}
+	}
+	return CommentStyle{}, false
+}
+
+// IsHeaderExcluded returns true if the file or directory at the provided slash-separated path relative to the root of a
+// repository should not be checked for license headers. Hidden files and directories and vendor directories are
+// excluded.
+func IsHeaderExcluded(relPath string) bool {
+	for _, part := range strings.Split(relPath, "/") {
+		if part == "vendor" || (strings.HasPrefix(part, ".") && part != "." && part != "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessDirHeaders verifies the license headers of the supported files in the provided directory and returns the
+// paths (relative to the directory) of the files whose headers are missing or incorrect in sorted order. If fix is true,
+// the headers of those files are also fixed.
+func ProcessDirHeaders(dir string, h *Headers, fix bool) ([]string, error) {
+	var paths []string
+	if err := filepath.Walk(dir, func(currPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, currPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if IsHeaderExcluded(relPath) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() || !h.Supports(relPath) {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(currPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", currPath)
+		}
+		fixed, changed, err := h.Fix(relPath, string(content))
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		paths = append(paths, relPath)
+		if fix {
+			if err := ioutil.WriteFile(currPath, []byte(fixed), fi.Mode()); err != nil {
+				return errors.Wrapf(err, "failed to write %s", currPath)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// LoadHeaderTemplates returns the header templates defined in the YML file at the provided path. Returns nil if path
+// is empty.
+func LoadHeaderTemplates(templatesPath string) (HeaderTemplates, error) {
+	if templatesPath == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(templatesPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read header templates file %s", templatesPath)
+	}
+	var templates HeaderTemplates
+	if err := yaml.Unmarshal(content, &templates); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal header templates file %s", templatesPath)
+	}
+	return templates, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package license_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/license"
+)
+
+const goHeader = `// Copyright 2020 Jane Doe. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+`
+
+func TestHeadersFix(t *testing.T) {
+	headers, err := license.NewHeaders("MIT", license.HeaderTemplates{
+		"mit": {
+			"java": "/* Copyright {{.Year}} {{.Author}}, MIT License */",
+		},
+	}, "Jane Doe", 2020)
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		path    string
+		content string
+		want    string
+		changed bool
+	}{
+		{
+			path:    "main.go",
+			content: "// Copyright 2016-2018 Jane Doe. All rights reserved.\n// Licensed under the MIT License. See LICENSE in the project root\n// for license information.\n\npackage main\n",
+			want:    "// Copyright 2016-2018 Jane Doe. All rights reserved.\n// Licensed under the MIT License. See LICENSE in the project root\n// for license information.\n\npackage main\n",
+		},
+		{
+			path:    "main.go",
+			content: "package main\n",
+			want:    goHeader + "\npackage main\n",
+			changed: true,
+		},
+		{
+			path:    "main.go",
+			content: "// Copyright 2016 Someone Else\n\n// Package main is great.\npackage main\n",
+			want:    goHeader + "\n// Package main is great.\npackage main\n",
+			changed: true,
+		},
+		{
+			path:    "Main.java",
+			content: "class Main {}\n",
+			want:    "/* Copyright 2020 Jane Doe, MIT License */\n\nclass Main {}\n",
+			changed: true,
+		},
+		{
+			path:    "build.sh",
+			content: "#!/bin/sh\necho hello\n",
+			want:    "#!/bin/sh\n# Copyright 2020 Jane Doe. All rights reserved.\n# Licensed under the MIT License. See LICENSE in the project root\n# for license information.\n\necho hello\n",
+			changed: true,
+		},
+		{
+			path:    "config.yml",
+			content: "",
+			want:    "# Copyright 2020 Jane Doe. All rights reserved.\n# Licensed under the MIT License. See LICENSE in the project root\n# for license information.\n",
+			changed: true,
+		},
+		{
+			path:    "generated.go",
+			content: "// Code generated by tool. DO NOT EDIT.\n\npackage main\n",
+			want:    "// Code generated by tool. DO NOT EDIT.\n\npackage main\n",
+		},
+		{
+			path:    "README.md",
+			content: "# README\n",
+			want:    "# README\n",
+		},
+	} {
+		got, changed, err := headers.Fix(currCase.path, currCase.content)
+		require.NoError(t, err, "Case %d", i)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+		assert.Equal(t, currCase.changed, changed, "Case %d", i)
+	}
+}
+
+func TestHeadersUnknownLicense(t *testing.T) {
+	_, err := license.NewHeaders("unlicense", nil, "Jane Doe", 2020)
+	assert.EqualError(t, err, "no header template for license unlicense")
+}
+
+func TestHeadersInvalidTemplate(t *testing.T) {
+	_, err := license.NewHeaders("mit", license.HeaderTemplates{
+		"mit": {
+			"default": "Copyright {{.Year",
+		},
+	}, "Jane Doe", 2020)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse header template")
+}
+
+func TestProcessDirHeaders(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	for path, content := range map[string]string{
+		"main.go":              goHeader + "\npackage main\n",
+		"pkg/pkg.go":           "package pkg\n",
+		"vendor/dep/dep.go":    "package dep\n",
+		".circleci/config.yml": "version: 2\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, path)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, path), []byte(content), 0644))
+	}
+
+	headers, err := license.NewHeaders("mit", nil, "Jane Doe", 2020)
+	require.NoError(t, err)
+
+	paths, err := license.ProcessDirHeaders(tmpDir, headers, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg/pkg.go"}, paths)
+
+	paths, err = license.ProcessDirHeaders(tmpDir, headers, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg/pkg.go"}, paths)
+	content, err := ioutil.ReadFile(filepath.Join(tmpDir, "pkg", "pkg.go"))
+	require.NoError(t, err)
+	assert.Equal(t, goHeader+"\npackage pkg\n", string(content))
+
+	paths, err = license.ProcessDirHeaders(tmpDir, headers, false)
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
@@ -5,6 +5,7 @@
 package license
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -102,18 +103,25 @@ func Aliases(licenseID string) []string {
 	return nil
 }
 
+// IsKnown returns true if the provided value is the SPDX ID or an alias of a known license. The comparison is
+// case-insensitive.
+func IsKnown(licenseID string) bool {
+	_, ok := aliasesMap[strings.ToLower(licenseID)]
+	return ok
+}
+
 // Create returns the content of the requested license as a string. If the license is a templatized one that uses author
 // information, the provided authorInfo is used to get the author information. If the license is not a templatized one,
 // authorInfo can be nil. If the template uses author information and authorInfo is nil, the function returns an error.
 // The provided key is the SPDX ID of the license or an alias for the license.
-func Create(licenseKey string, cache Cache, authorInfo AuthorInfo) (string, error) {
+func Create(ctx context.Context, licenseKey string, cache Cache, authorInfo AuthorInfo) (string, error) {
 	licenseKey = strings.ToLower(licenseKey)
 	if v, ok := aliasesMap[licenseKey]; ok {
 		// if provided key is an alias, look up the SPDX ID and use it
 		licenseKey = v
 	}
 
-	license, err := cache.Get(licenseKey)
+	license, err := cache.Get(ctx, licenseKey)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to get content of license %s", licenseKey)
 	}
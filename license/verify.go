@@ -5,12 +5,15 @@
 package license
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/nmiyake/ghcli/repository"
 )
 
 type licenseErrorType int
@@ -51,8 +54,11 @@ func Diff(err error) string {
 	return ""
 }
 
-func VerifyCorrect(client *github.Client, repo *github.Repository, authorName string, cache Cache) (github.RepositoryLicense, error) {
-	license, _, err := client.Repositories.License(*repo.Owner.Login, *repo.Name)
+func VerifyCorrect(ctx context.Context, client *github.Client, repo *github.Repository, authorName string, cache Cache) (github.RepositoryLicense, error) {
+	if err := ctx.Err(); err != nil {
+		return github.RepositoryLicense{}, err
+	}
+	license, err := repository.GetLicense(ctx, client, repo)
 	if err != nil {
 		msg := "no license detected"
 		if *repo.Fork {
@@ -60,10 +66,10 @@ func VerifyCorrect(client *github.Client, repo *github.Repository, authorName st
 		}
 		return github.RepositoryLicense{}, &repoLicenseError{ErrType: errorMissing, Message: msg}
 	}
-	return VerifyRepositoryLicenseCorrect(license, repo, authorName, cache)
+	return VerifyRepositoryLicenseCorrect(ctx, license, repo, authorName, cache)
 }
 
-func VerifyRepositoryLicenseCorrect(license *github.RepositoryLicense, repo *github.Repository, authorName string, cache Cache) (github.RepositoryLicense, error) {
+func VerifyRepositoryLicenseCorrect(ctx context.Context, license *github.RepositoryLicense, repo *github.Repository, authorName string, cache Cache) (github.RepositoryLicense, error) {
 	// content of license currently in repository
 	actualLicenseBytes, err := base64.StdEncoding.DecodeString(*license.Content)
 	if err != nil {
@@ -71,7 +77,7 @@ func VerifyRepositoryLicenseCorrect(license *github.RepositoryLicense, repo *git
 	}
 	actualLicenseContent := string(actualLicenseBytes)
 
-	expectedLicenseContent, err := Create(*license.License.Key, cache, NewAuthorInfo(authorName, repo.CreatedAt.Time.Year(), repo.UpdatedAt.Time.Year()))
+	expectedLicenseContent, err := Create(ctx, *license.License.Key, cache, NewAuthorInfo(authorName, repo.CreatedAt.Time.Year(), repo.UpdatedAt.Time.Year()))
 	if err != nil {
 		return *license, err
 	}
@@ -5,14 +5,16 @@
 package repository
 
 import (
-	"encoding/base64"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 type DefinitionSlice []Definition
@@ -41,14 +43,99 @@ type Definition struct {
 	// the value should be "custom".
 	License    string `yaml:"license" json:"license"`
 	HasPatents bool   `yaml:"patents" json:"patents"` // true if repository uses patents and should contain a "PATENTS.txt" file
+	// LicenseHeaders is true if the supported source files of the repository must start with the license header for
+	// the license of the repository.
+	LicenseHeaders bool `yaml:"license_headers,omitempty" json:"license_headers,omitempty"`
+	// Settings are the required settings of the repository. Settings that are not specified are not verified.
+	Settings *Settings `yaml:"settings,omitempty" json:"settings,omitempty"`
+	// BranchProtection specifies the required protection for branches of the repository. Each branch is verified
+	// against the first entry that matches it.
+	BranchProtection []BranchProtection `yaml:"branch_protection,omitempty" json:"branch_protection,omitempty"`
+	// Teams is a map from the slug of a team to the permission that the team should have for the repository ("pull",
+	// "push" or "admin"). Only applies to repositories owned by an organization. If nil, teams are not verified.
+	Teams map[string]string `yaml:"teams,omitempty" json:"teams,omitempty"`
+	// LabelSet is the name of a shared set of issue labels that the repository should have. Labels in Labels are
+	// added to the labels in the set and take precedence over labels in the set with the same name.
+	LabelSet string  `yaml:"label_set,omitempty" json:"label_set,omitempty"`
+	Labels   []Label `yaml:"labels,omitempty" json:"labels,omitempty"` // issue labels that the repository should have
+	Files    []File  `yaml:"files,omitempty" json:"files,omitempty"`   // requirements for files in the repository
+	// CodeOwners specifies the requirements for the CODEOWNERS file of the repository. If nil, the CODEOWNERS file
+	// is not verified.
+	CodeOwners *CodeOwners `yaml:"codeowners,omitempty" json:"codeowners,omitempty"`
+	// Hooks are the webhooks that the repository should have. If nil, the webhooks of the repository are not verified.
+	Hooks []Hook `yaml:"hooks,omitempty" json:"hooks,omitempty"`
 }
 
+// CodeOwners specifies the content of a CODEOWNERS file. The default "*" rule of the file must list exactly the owners
+// of the definition. Rules contains additional rules that the file must contain. If Strict is true, the file must not
+// contain any other rules.
+type CodeOwners struct {
+	Rules  []CodeOwnersRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+	Strict bool             `yaml:"strict,omitempty" json:"strict,omitempty"`
+}
+
+// CodeOwnersRule is a rule in a CODEOWNERS file.
+type CodeOwnersRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// Owners are GitHub usernames, teams ("org/team") or email addresses. The leading "@" for usernames and teams is
+	// optional.
+	Owners []string `yaml:"owners" json:"owners"`
+}
+
+// File specifies a requirement for a file in a repository. If Forbidden is true, the file must not exist. Otherwise,
+// the file must exist and, if Template is non-empty, its content must match the rendered template exactly.
+type File struct {
+	Path string `yaml:"path" json:"path"` // path of the file relative to the root of the repository
+	// Template is the path of a Go text/template file that is rendered to produce the required content of the file.
+	// The template is executed with a FileTemplateData for the definition.
+	Template  string `yaml:"template,omitempty" json:"template,omitempty"`
+	Forbidden bool   `yaml:"forbidden,omitempty" json:"forbidden,omitempty"`
+}
+
+// FileTemplateData is the data provided to file templates. It provides all of the fields of the definition along with
+// the owner and name of the repository.
+type FileTemplateData struct {
+	Definition
+	Owner string // owner (user or organization) of the repository, e.g. "octocat"
+	Name  string // name of the repository, e.g. "Hello-World"
+}
+
+// NewFileTemplateData returns the FileTemplateData for the provided definition.
+func NewFileTemplateData(def Definition) FileTemplateData {
+	data := FileTemplateData{
+		Definition: def,
+		Name:       def.FullName,
+	}
+	if idx := strings.Index(def.FullName, "/"); idx != -1 {
+		data.Owner = def.FullName[:idx]
+		data.Name = def.FullName[idx+1:]
+	}
+	return data
+}
+
+// Info is the information about a repository that is compared with its definition. It is serialized as JSON (with
+// the fields of the GitHub repository at the top level) for analyzer plugins.
 type Info struct {
 	github.Repository
-	RepoLicense *github.RepositoryLicense
-	IsEmpty     bool // true if repository is empty
-	Owners      []string
-	HasPatents  bool
+	RepoLicense   *github.RepositoryLicense   `json:"repo_license,omitempty"`
+	IsEmpty       bool                        `json:"is_empty,omitempty"` // true if repository is empty
+	Owners        []string                    `json:"owners,omitempty"`
+	OwnersUnknown bool                        `json:"owners_unknown,omitempty"` // true if the collaborators of the repository could not be listed (for example, due to a 403 or 404)
+	PendingOwners []string                    `json:"pending_owners,omitempty"` // GitHub usernames of users with pending invitations to be admin collaborators
+	Settings      Settings                    `json:"settings,omitempty"`
+	Branches      []string                    `json:"branches,omitempty"`    // names of all branches of the repository
+	Protections   map[string]BranchProtection `json:"protections,omitempty"` // protection settings of protected branches keyed by branch name
+	// ProtectionsUnknown is true if the protection settings of the protected branches could not be read (for
+	// example, because the current user is not an admin of the repository).
+	ProtectionsUnknown bool              `json:"protections_unknown,omitempty"`
+	Teams              map[string]string `json:"teams,omitempty"`         // map from the slug of each team with access to the repository to its permission
+	TeamsUnknown       bool              `json:"teams_unknown,omitempty"` // true if the teams of the repository could not be listed
+	Labels             []Label           `json:"labels,omitempty"`
+	Hooks              []Hook            `json:"hooks,omitempty"`         // webhooks of the repository sorted by URL
+	HooksUnknown       bool              `json:"hooks_unknown,omitempty"` // true if the webhooks of the repository could not be listed
+	HasPatents         bool              `json:"has_patents,omitempty"`
+	PatentsPath        string            `json:"patents_path,omitempty"`    // path of the patents file in the repository (empty if HasPatents is false)
+	PatentsContent     string            `json:"patents_content,omitempty"` // content of the patents file in the repository (empty if HasPatents is false)
 }
 
 func (i *Info) ToDefinition() Definition {
@@ -60,97 +147,393 @@ func (i *Info) ToDefinition() Definition {
 	if i.License != nil && i.License.SPDXID != nil {
 		license = *i.License.SPDXID
 	}
+	settings := i.Settings
+
+	// protection for the default branch is represented using the empty string and is listed first
+	var protections []BranchProtection
+	if i.DefaultBranch != nil {
+		if protection, ok := i.Protections[*i.DefaultBranch]; ok {
+			protection.Branch = ""
+			protections = append(protections, protection)
+		}
+	}
+	for _, branch := range i.Branches {
+		if protection, ok := i.Protections[branch]; ok && (i.DefaultBranch == nil || branch != *i.DefaultBranch) {
+			protections = append(protections, protection)
+		}
+	}
+
 	return Definition{
-		FullName:    *i.FullName,
-		Description: description,
-		Owners:      i.Owners,
-		License:     license,
-		HasPatents:  i.HasPatents,
+		FullName:         *i.FullName,
+		Description:      description,
+		Owners:           i.Owners,
+		License:          license,
+		HasPatents:       i.HasPatents,
+		Settings:         &settings,
+		BranchProtection: protections,
+		Teams:            i.Teams,
+		Labels:           i.Labels,
+		Hooks:            i.Hooks,
 	}
 }
 
-// GetInfo returns the Info for the given repo using the provided client.
-func GetInfo(client *github.Client, repo *github.Repository) (Info, error) {
-	_, resp, err := client.Repositories.ListContributors(*repo.Owner.Login, *repo.Name, nil)
+// GetInfo returns the Info for the given repo with all of its sections using the provided client. The information is
+// retrieved using the REST API (see InfoFetcher for an alternative that retrieves part of it for multiple repositories
+// at once and that can retrieve only some of the sections).
+func GetInfo(ctx context.Context, client *github.Client, repo *github.Repository) (Info, error) {
+	return NewRESTInfoFetcher(client).GetInfo(ctx, repo, AllSections)
+}
+
+// batchInfo is the part of the Info of a repository that GraphQLInfoFetcher retrieves for multiple repositories at once.
+// The remaining information is always retrieved using the REST API.
+type batchInfo struct {
+	isEmpty        bool
+	repoLicense    *github.RepositoryLicense
+	owners         []string // sorted using CaseInsensitiveStrings
+	ownersUnknown  bool
+	patentsPath    string
+	patentsContent string
+}
+
+// getBatchInfo returns the batchInfo of the provided repository using the REST API.
+func getBatchInfo(ctx context.Context, client *github.Client, repo *github.Repository) (batchInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return batchInfo{}, err
+	}
+	resp, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/contributors", *repo.Owner.Login, *repo.Name), nil, nil)
 	if err != nil {
-		return Info{}, errors.Wrapf(err, "failed to get contributors for %s", *repo.FullName)
+		return batchInfo{}, errors.Wrapf(err, "failed to get contributors for %s", *repo.FullName)
 	}
 	if resp.StatusCode == http.StatusNoContent {
 		// if StatusNoContent is returned, repository exists but is empty
-		return Info{
-			Repository: *repo,
-			IsEmpty:    true,
+		return batchInfo{
+			isEmpty: true,
 		}, nil
 	}
 
 	var repoLicense *github.RepositoryLicense
 	if repo.License != nil {
-		repoLicense, _, err = client.Repositories.License(*repo.Owner.Login, *repo.Name)
+		if err := ctx.Err(); err != nil {
+			return batchInfo{}, err
+		}
+		repoLicense, err = GetLicense(ctx, client, repo)
 		if err != nil {
-			return Info{}, errors.Wrapf(err, "failed to get license for %s", *repo.FullName)
+			return batchInfo{}, errors.Wrapf(err, "failed to get license for %s", *repo.FullName)
 		}
 	}
 
 	var owners []string
-	if response, err := ProcessCollaborators(client, repo, func(user *github.User) error {
+	ownersUnknown := false
+	if response, err := ProcessCollaborators(ctx, client, repo, func(user *github.User) error {
 		if (*user.Permissions)["admin"] {
 			owners = append(owners, *user.Login)
 		}
 		return nil
 	}); err != nil {
-		if response == nil || (response.StatusCode != http.StatusForbidden && response.StatusCode != http.StatusNotFound) {
-			return Info{}, errors.Wrapf(err, "failed to get collaborators for %s", *repo.FullName)
+		if !isForbiddenOrNotFound(response) {
+			return batchInfo{}, errors.Wrapf(err, "failed to get collaborators for %s", *repo.FullName)
 		}
-		// if response code is 403 or 404, keep owners as nil
+		// if response code is 403 or 404, keep owners as nil and record that they could not be determined
+		ownersUnknown = true
 	}
 	sort.Sort(CaseInsensitiveStrings(owners))
 
-	hasPatents, err := hasPatentsFile(client, repo)
+	patentsPath, err := patentsFilePath(ctx, client, repo)
 	if err != nil {
-		return Info{}, err
+		return batchInfo{}, err
+	}
+	var patentsContent string
+	if patentsPath != "" {
+		if patentsContent, _, err = GetFileContent(ctx, client, repo, patentsPath); err != nil {
+			return batchInfo{}, err
+		}
+	}
+
+	return batchInfo{
+		repoLicense:    repoLicense,
+		owners:         owners,
+		ownersUnknown:  ownersUnknown,
+		patentsPath:    patentsPath,
+		patentsContent: patentsContent,
+	}, nil
+}
+
+// getInfo returns the Info for the given repo by combining the provided batchInfo with the provided sections, which
+// are retrieved using the REST API.
+func getInfo(ctx context.Context, client *github.Client, repo *github.Repository, batch batchInfo, sections InfoSections) (Info, error) {
+	var settings Settings
+	if sections&SettingsSection != 0 {
+		var err error
+		if settings, err = GetSettings(ctx, client, repo); err != nil {
+			return Info{}, err
+		}
+	}
+
+	var hooks []Hook
+	hooksUnknown := false
+	if sections&HooksSection != 0 {
+		// listing hooks requires admin access to the repository
+		var response *github.Response
+		var err error
+		if hooks, response, err = ListHooks(ctx, client, repo); err != nil {
+			if !isForbiddenOrNotFound(response) {
+				return Info{}, err
+			}
+			hooksUnknown = true
+		}
+	}
+
+	if batch.isEmpty {
+		return Info{
+			Repository:   *repo,
+			IsEmpty:      true,
+			Settings:     settings,
+			Hooks:        hooks,
+			HooksUnknown: hooksUnknown,
+		}, nil
+	}
+
+	var branches []string
+	var protections map[string]BranchProtection
+	protectionsUnknown := false
+	if sections&ProtectionsSection != 0 {
+		var protectedBranches []string
+		var err error
+		if branches, protectedBranches, err = ListBranches(ctx, client, repo); err != nil {
+			return Info{}, err
+		}
+		protections = make(map[string]BranchProtection)
+		for _, branch := range protectedBranches {
+			protection, response, err := GetBranchProtection(ctx, client, repo, branch)
+			if err != nil {
+				if !isForbiddenOrNotFound(response) {
+					return Info{}, err
+				}
+				// if response code is 403 or 404, protection cannot be read by the current user
+				protectionsUnknown = true
+				break
+			}
+			if protection != nil {
+				protections[branch] = *protection
+			}
+		}
+	}
+
+	var teams map[string]string
+	teamsUnknown := false
+	if sections&TeamsSection != 0 && IsOrgRepo(repo) {
+		var response *github.Response
+		var err error
+		if teams, response, err = GetTeamPermissions(ctx, client, repo); err != nil {
+			if !isForbiddenOrNotFound(response) {
+				return Info{}, err
+			}
+			// if response code is 403 or 404, keep teams as nil and record that they could not be determined
+			teamsUnknown = true
+		}
+	}
+
+	var labels []Label
+	if sections&LabelsSection != 0 {
+		var err error
+		if labels, err = ListLabels(ctx, client, repo); err != nil {
+			return Info{}, err
+		}
+	}
+
+	var pendingOwners []string
+	if sections&PendingOwnersSection != 0 && !batch.ownersUnknown {
+		if response, err := ProcessInvitations(ctx, client, repo, func(invitation *github.RepositoryInvitation) error {
+			if invitation.Permissions != nil && *invitation.Permissions == "admin" {
+				pendingOwners = append(pendingOwners, *invitation.Invitee.Login)
+			}
+			return nil
+		}); err != nil && !isForbiddenOrNotFound(response) {
+			return Info{}, errors.Wrapf(err, "failed to get invitations for %s", *repo.FullName)
+		}
+		sort.Sort(CaseInsensitiveStrings(pendingOwners))
 	}
 
 	return Info{
-		Repository:  *repo,
-		RepoLicense: repoLicense,
-		Owners:      owners,
-		HasPatents:  hasPatents,
+		Repository:         *repo,
+		RepoLicense:        batch.repoLicense,
+		Owners:             batch.owners,
+		OwnersUnknown:      batch.ownersUnknown,
+		PendingOwners:      pendingOwners,
+		Settings:           settings,
+		Branches:           branches,
+		Protections:        protections,
+		ProtectionsUnknown: protectionsUnknown,
+		Teams:              teams,
+		TeamsUnknown:       teamsUnknown,
+		Labels:             labels,
+		Hooks:              hooks,
+		HooksUnknown:       hooksUnknown,
+		HasPatents:         batch.patentsPath != "",
+		PatentsPath:        batch.patentsPath,
+		PatentsContent:     batch.patentsContent,
 	}, nil
 }
 
-// Returns true if the provided repository has a "patents" or "patents.txt" file (case-insensitive) at the top level
-// (root directory) of the repository.
-func hasPatentsFile(client *github.Client, repo *github.Repository) (bool, error) {
-	_, dir, _, err := client.Repositories.GetContents(*repo.Owner.Login, *repo.Name, "", nil)
+func isForbiddenOrNotFound(response *github.Response) bool {
+	return response != nil && (response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusNotFound)
+}
+
+// Returns the path of the "patents" or "patents.txt" file (case-insensitive) at the top level (root directory) of the
+// provided repository. Returns the empty string if the repository does not have such a file.
+func patentsFilePath(ctx context.Context, client *github.Client, repo *github.Repository) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	_, dir, _, err := getContents(ctx, client, repo, "", "")
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to list contents of repository %+v", *repo)
+		return "", errors.Wrapf(err, "failed to list contents of repository %+v", *repo)
 	} else if dir == nil {
-		return false, errors.Errorf("failed to list contents of repository %+v", *repo)
+		return "", errors.Errorf("failed to list contents of repository %+v", *repo)
 	}
 	for _, currFile := range dir {
 		switch strings.ToLower(*currFile.Name) {
 		case "patents", "patents.txt":
-			return true, nil
+			return *currFile.Path, nil
 		}
 	}
-	return false, nil
+	return "", nil
 }
 
-func GetDefinitionsFromFile(client *github.Client, repo *github.Repository, path string) ([]Definition, error) {
-	encodedDefContents, _, _, err := client.Repositories.GetContents(*repo.Owner.Name, *repo.Name, path, nil)
+// GetFileContent returns the content of the file at the provided path on the default branch of the provided repository.
+// Returns false if no file exists at the path.
+func GetFileContent(ctx context.Context, client *github.Client, repo *github.Repository, path string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	file, _, response, err := getContents(ctx, client, repo, path, "")
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return "", false, nil
+		}
+		return "", false, errors.Wrapf(err, "failed to get content of %s in repository %s", path, *repo.FullName)
+	} else if file == nil {
+		return "", false, errors.Errorf("%s in repository %s is not a file", path, *repo.FullName)
+	}
+	content, err := file.GetContent()
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get repo definition file")
+		return "", false, errors.Wrapf(err, "failed to decode content of %s in repository %s", path, *repo.FullName)
 	}
-	if encodedDefContents == nil {
-		return nil, errors.Errorf("GetContents for path %s for user %s in repository %s returned nil", path, *repo.Owner.Name, *repo.Name)
+	return content, true, nil
+}
+
+// GetLicense returns the license of the provided repository as detected by GitHub.
+func GetLicense(ctx context.Context, client *github.Client, repo *github.Repository) (*github.RepositoryLicense, error) {
+	var repoLicense github.RepositoryLicense
+	if _, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/license", *repo.Owner.Login, *repo.Name), nil, &repoLicense); err != nil {
+		return nil, err
+	}
+	return &repoLicense, nil
+}
+
+// getContents returns the contents at the provided path in the provided repository at the provided ref (or the default
+// branch if ref is empty). If the path is a file, the file is returned. Otherwise, the entries of the directory are
+// returned.
+func getContents(ctx context.Context, client *github.Client, repo *github.Repository, path, ref string) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	urlStr := fmt.Sprintf("repos/%v/%v/contents/%v", *repo.Owner.Login, *repo.Name, (&url.URL{Path: path}).String())
+	if ref != "" {
+		urlStr += "?ref=" + url.QueryEscape(ref)
 	}
-	defContents, err := base64.StdEncoding.DecodeString(*encodedDefContents.Content)
+	var rawJSON json.RawMessage
+	resp, err := Do(ctx, client, "GET", urlStr, nil, &rawJSON)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to decode %s from Base64 encoding", encodedDefContents)
+		return nil, nil, resp, err
+	}
+	var file *github.RepositoryContent
+	fileErr := json.Unmarshal(rawJSON, &file)
+	if fileErr == nil {
+		return file, nil, resp, nil
+	}
+	var dir []*github.RepositoryContent
+	dirErr := json.Unmarshal(rawJSON, &dir)
+	if dirErr == nil {
+		return nil, dir, resp, nil
+	}
+	return nil, nil, resp, errors.Errorf("failed to unmarshal content as file or directory: %v and %v", fileErr, dirErr)
+}
+
+// tree is a Git tree as returned by the GitHub API. github.Tree does not include whether the entries were truncated.
+type tree struct {
+	Entries   []github.TreeEntry `json:"tree"`
+	Truncated bool               `json:"truncated"`
+}
+
+// GetTree returns the entries of the Git tree with the provided SHA in the provided repository. If recursive is true,
+// the entries of all of its subtrees are included as well (with paths relative to the tree). The API limits the number
+// of entries that it returns, so an error is returned if the entries were truncated rather than returning a partial
+// tree.
+func GetTree(ctx context.Context, client *github.Client, repo *github.Repository, sha string, recursive bool) ([]github.TreeEntry, error) {
+	urlStr := fmt.Sprintf("repos/%v/%v/git/trees/%v", *repo.Owner.Login, *repo.Name, sha)
+	if recursive {
+		urlStr += "?recursive=1"
 	}
-	var output []Definition
-	if err := yaml.Unmarshal(defContents, &output); err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal %s as YML", string(defContents))
+	req, err := NewRequest(ctx, client, "GET", urlStr, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request")
+	}
+	var t tree
+	if _, err := client.Do(req, &t); err != nil {
+		return nil, errors.Wrapf(err, "failed to get tree %s of %s", sha, *repo.FullName)
+	}
+	if t.Truncated {
+		return nil, errors.Errorf("tree %s of %s has more entries than the GitHub API returns", sha, *repo.FullName)
+	}
+	return t.Entries, nil
+}
+
+// GetSpecFromFile returns the specification defined by the YML or JSON file at the provided path in the provided
+// repository at the provided ref (a branch, tag or commit SHA). If ref is empty, the file on the default branch of the
+// repository is used. The specification cannot include other files.
+func GetSpecFromFile(ctx context.Context, client *github.Client, repo *github.Repository, path, ref string) (*Spec, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fullName := *repo.Owner.Login + "/" + *repo.Name
+	location := "the default branch"
+	if ref != "" {
+		location = "ref " + ref
+	}
+
+	file, dir, response, err := getContents(ctx, client, repo, path, ref)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil, errors.Errorf("specification file %s does not exist in repository %s at %s (or the repository is not accessible)", path, fullName, location)
+		}
+		return nil, errors.Wrapf(err, "failed to get specification file %s in repository %s at %s", path, fullName, location)
+	}
+	if file == nil || dir != nil {
+		return nil, errors.Errorf("specification %s in repository %s at %s is not a file", path, fullName, location)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode specification file %s in repository %s", path, fullName)
+	}
+	spec, err := ParseSpec([]byte(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid specification file %s in repository %s at %s", path, fullName, location)
+	}
+	return spec, nil
+}
+
+// GetDefinitionsFromFile returns the effective definitions of the repositories named in the specification file at the
+// provided path in the provided repository at the provided ref (see GetSpecFromFile).
+func GetDefinitionsFromFile(ctx context.Context, client *github.Client, repo *github.Repository, path, ref string) ([]Definition, error) {
+	spec, err := GetSpecFromFile(ctx, client, repo, path, ref)
+	if err != nil {
+		return nil, err
+	}
+	var defs []Definition
+	for _, name := range spec.Names() {
+		def, _, err := spec.Definition(name)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
 	}
-	return output, nil
+	return defs, nil
 }
@@ -5,6 +5,7 @@
 package repository_test
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
@@ -28,11 +29,12 @@ const testYML = `
 
 func TestGetDefinitionsFromFile(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(t, "/repos/octocat/Hello-World/contents/definitions.yml", r.URL.String())
+		assert.Equal(t, "/repos/octocat/Hello-World/contents/definitions.yml?ref=v1", r.URL.String())
 
 		content := base64.StdEncoding.EncodeToString([]byte(testYML))
 		resp := github.RepositoryContent{
-			Content: &content,
+			Encoding: github.String("base64"),
+			Content:  &content,
 		}
 		json, err := json.Marshal(resp)
 		require.NoError(t, err)
@@ -47,12 +49,12 @@ func TestGetDefinitionsFromFile(t *testing.T) {
 
 	repo := &github.Repository{
 		Owner: &github.User{
-			Name: github.String("octocat"),
+			Login: github.String("octocat"),
 		},
 		Name: github.String("Hello-World"),
 	}
 
-	got, err := repository.GetDefinitionsFromFile(client, repo, "definitions.yml")
+	got, err := repository.GetDefinitionsFromFile(context.Background(), client, repo, "definitions.yml", "v1")
 	require.NoError(t, err)
 
 	want := []repository.Definition{
@@ -66,3 +68,50 @@ func TestGetDefinitionsFromFile(t *testing.T) {
 	}
 	assert.Equal(t, want, got)
 }
+
+func TestGetSpecFromFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var content string
+		switch r.URL.Path {
+		case "/repos/octocat/specs/contents/repositories.json":
+			content = "{\n\t\"defaults\": {\"license\": \"mit\"},\n\t\"repositories\": [\n\t\t{\"name\": \"octocat/Hello-World\"}\n\t]\n}\n"
+		case "/repos/octocat/specs/contents/dir":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"type": "file", "name": "repositories.yml"}]`))
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		resp, err := json.Marshal(github.RepositoryContent{
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+		})
+		require.NoError(t, err)
+		_, err = w.Write(resp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+	repo := &github.Repository{
+		Owner: &github.User{
+			Login: github.String("octocat"),
+		},
+		Name: github.String("specs"),
+	}
+
+	spec, err := repository.GetSpecFromFile(context.Background(), client, repo, "repositories.json", "")
+	require.NoError(t, err)
+	def, ok, err := spec.Definition("octocat/Hello-World")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, repository.Definition{FullName: "octocat/Hello-World", License: "mit"}, def)
+
+	_, err = repository.GetSpecFromFile(context.Background(), client, repo, "missing.yml", "develop")
+	assert.EqualError(t, err, "specification file missing.yml does not exist in repository octocat/specs at ref develop (or the repository is not accessible)")
+
+	_, err = repository.GetSpecFromFile(context.Background(), client, repo, "dir", "")
+	assert.EqualError(t, err, "specification dir in repository octocat/specs at the default branch is not a file")
+}
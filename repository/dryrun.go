@@ -0,0 +1,106 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// DryRunTransport is an http.RoundTripper that only sends requests that cannot modify state (requests that use the GET,
+// HEAD or OPTIONS method and GraphQL queries) and rejects all other requests with a *DryRunError.
+type DryRunTransport struct {
+	// Transport sends the requests that are not rejected. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+func (t *DryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// IsReadOnly may replace the body of the request, so it is called with a copy of the request that is sent instead
+	reqCopy := *req
+	req = &reqCopy
+	if !IsReadOnly(req) {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, &DryRunError{
+			Method: req.Method,
+			URL:    req.URL.String(),
+		}
+	}
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+// IsReadOnly returns true if the provided request cannot modify state. GraphQL queries are sent using POST, so a POST
+// request to the GraphQL API is read-only if its document consists of a query (and does not contain a mutation). The
+// body of such a request is read to determine this and is replaced with a reader of the same content.
+func IsReadOnly(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	case "POST":
+		if !strings.HasSuffix(req.URL.Path, "/graphql") || req.Body == nil {
+			return false
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return false
+		}
+		var graphQLReq graphQLRequest
+		if err := json.Unmarshal(body, &graphQLReq); err != nil {
+			return false
+		}
+		query := strings.TrimSpace(graphQLReq.Query)
+		return (strings.HasPrefix(query, "query") || strings.HasPrefix(query, "{")) && !strings.Contains(query, "mutation")
+	}
+	return false
+}
+
+// DryRunError is the error returned by DryRunTransport for a request that it rejected.
+type DryRunError struct {
+	Method string
+	URL    string
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("dry run: %s %s was not sent", e.Method, e.URL)
+}
+
+// NewDryRunClient returns a GitHub client that sends requests using the provided HTTP client with its transport wrapped
+// in a DryRunTransport. The client only guards against modifications: operations that describe the changes that they
+// would make in a dry run must be told that they are part of a dry run explicitly.
+func NewDryRunClient(httpClient *http.Client) *github.Client {
+	guardedClient := &http.Client{}
+	if httpClient != nil {
+		*guardedClient = *httpClient
+	}
+	guardedClient.Transport = &DryRunTransport{
+		Transport: guardedClient.Transport,
+	}
+	return github.NewClient(guardedClient)
+}
+
+// IsDryRunError returns true if the cause of the provided error is a request that was rejected by DryRunTransport.
+func IsDryRunError(err error) bool {
+	err = errors.Cause(err)
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	_, ok := err.(*DryRunError)
+	return ok
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+func TestDryRunClient(t *testing.T) {
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		_, _ = w.Write([]byte(`{"name": "Hello-World"}`))
+	}))
+	defer ts.Close()
+
+	client := repository.NewDryRunClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	repo, _, err := client.Repositories.Get("octocat", "Hello-World")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello-World", *repo.Name)
+
+	_, _, err = client.Repositories.Edit("octocat", "Hello-World", &github.Repository{Description: github.String("Hello")})
+	require.Error(t, err)
+	assert.True(t, repository.IsDryRunError(err))
+	assert.Contains(t, err.Error(), "dry run: PATCH "+ts.URL+"/repos/octocat/Hello-World was not sent")
+
+	_, err = client.Repositories.Delete("octocat", "Hello-World")
+	assert.True(t, repository.IsDryRunError(err))
+
+	_, _, err = client.Issues.CreateLabel("octocat", "Hello-World", &github.Label{Name: github.String("bug")})
+	assert.True(t, repository.IsDryRunError(err))
+
+	// GraphQL queries are sent, but mutations are not
+	for i, currCase := range []struct {
+		query string
+		sent  bool
+	}{
+		{query: "query { viewer { login } }", sent: true},
+		{query: `mutation { addStar(input: {starrableId: "1"}) { clientMutationId } }`, sent: false},
+	} {
+		req, err := client.NewRequest("POST", "graphql", map[string]string{"query": currCase.query})
+		require.NoError(t, err)
+		_, err = client.Do(req, nil)
+		assert.Equal(t, !currCase.sent, repository.IsDryRunError(err), "Case %d", i)
+	}
+
+	assert.Equal(t, []string{"GET", "POST"}, methods)
+	assert.False(t, repository.IsDryRunError(nil))
+}
+
+func TestDryRunTransportSendsBodyOfGraphQLQuery(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(content)
+	}))
+	defer ts.Close()
+
+	query := `{"query": "query { viewer { login } }"}`
+	// the body is wrapped so that the request does not record how to recreate it
+	req, err := http.NewRequest("POST", ts.URL+"/graphql", struct{ io.Reader }{strings.NewReader(query)})
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: &repository.DryRunTransport{}}).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, query, body)
+}
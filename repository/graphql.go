@@ -0,0 +1,520 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// graphQLBatchSize is the maximum number of repositories whose information is requested in a single GraphQL query. It
+// matches the default page size of the repository listing APIs, so the information for a page is requested at once.
+const graphQLBatchSize = 30
+
+const (
+	// graphQLRepositoryFragment contains the fields of a repository that are requested for every repository of a batch.
+	graphQLRepositoryFragment = `fragment repositoryInfo on Repository {
+  isEmpty
+  licenseInfo { key name spdxId }
+  collaborators(first: 100) { ...collaborators }
+  object(expression: "HEAD:") { ... on Tree { entries { name path type } } }
+}`
+	graphQLCollaboratorsFragment = `fragment collaborators on RepositoryCollaboratorConnection {
+  edges { permission node { login } }
+  pageInfo { hasNextPage endCursor }
+}`
+	graphQLBlobFragment = `fragment blob on Blob { oid byteSize text }`
+)
+
+// NewGraphQLInfoFetcher returns an InfoFetcher that uses the GraphQL API to retrieve whether a repository is empty, its
+// license, its collaborators and the entries of its root directory for a page of repositories in a single query. The
+// content of the license and patents files of the page is retrieved using a second query, and the remaining
+// information is retrieved for each repository using the REST API. The GraphQL API cannot be used without a token or
+// with GitHub Enterprise versions that do not provide it, in which case NewRESTInfoFetcher should be used instead.
+func NewGraphQLInfoFetcher(client *github.Client) InfoFetcher {
+	return &graphQLInfoFetcher{
+		client: client,
+	}
+}
+
+type graphQLInfoFetcher struct {
+	client *github.Client
+}
+
+type batchResult struct {
+	batch batchInfo
+	err   error
+}
+
+// prefetchedKey is the context key of the *prefetched information of the repository that the context is processing.
+type prefetchedKey struct{}
+
+// prefetched is the information that was retrieved for a repository along with the other repositories of its page. It
+// is stored in the context that the repository is processed with rather than in the fetcher so that it is released
+// once the repository has been processed, even if GetInfo is never called for it.
+type prefetched struct {
+	key    string // repositoryKey of the repository
+	mutex  sync.Mutex
+	used   bool
+	result batchResult
+}
+
+// take returns the prefetched information if it is for the provided repository and has not already been returned
+// (the information of a repository may have changed since it was first used).
+func (p *prefetched) take(repo *github.Repository) (batchResult, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.used || p.key != repositoryKey(repo) {
+		return batchResult{}, false
+	}
+	p.used = true
+	result := p.result
+	p.result = batchResult{}
+	return result, true
+}
+
+func (f *graphQLInfoFetcher) ProcessFunc(process ProcessFunc) ProcessFunc {
+	var repos []*github.Repository
+	var progresses []Progress
+	return func(ctx context.Context, repo *github.Repository, progress Progress) error {
+		repos = append(repos, repo)
+		progresses = append(progresses, progress)
+		if progress.CurrPageRepo < progress.CurrPageNumRepos-1 {
+			// information is retrieved once all of the repositories of the page are known
+			return nil
+		}
+		pageRepos, pageProgresses := repos, progresses
+		repos, progresses = nil, nil
+
+		results := f.prefetch(ctx, pageRepos)
+		for i, repo := range pageRepos {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			repoCtx := ctx
+			if results[i] != nil {
+				repoCtx = context.WithValue(ctx, prefetchedKey{}, results[i])
+			}
+			if err := process(repoCtx, repo, pageProgresses[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (f *graphQLInfoFetcher) GetInfo(ctx context.Context, repo *github.Repository, sections InfoSections) (Info, error) {
+	var result batchResult
+	ok := false
+	if p, _ := ctx.Value(prefetchedKey{}).(*prefetched); p != nil {
+		result, ok = p.take(repo)
+	}
+	if !ok {
+		// repository was not prefetched
+		result = f.fetch(ctx, []*github.Repository{repo})[0]
+	}
+	if result.err != nil {
+		return Info{}, result.err
+	}
+	return getInfo(ctx, f.client, repo, result.batch, sections)
+}
+
+// prefetch retrieves the information for the provided repositories. The returned information is in the same order as
+// the repositories and is nil for the repositories that were not prefetched.
+func (f *graphQLInfoFetcher) prefetch(ctx context.Context, repos []*github.Repository) []*prefetched {
+	prefetchedRepos := make([]*prefetched, len(repos))
+	for start := 0; start < len(repos); start += graphQLBatchSize {
+		if ctx.Err() != nil {
+			// repositories that were not prefetched are processed only if the context is not done
+			break
+		}
+		end := start + graphQLBatchSize
+		if end > len(repos) {
+			end = len(repos)
+		}
+		results := f.fetch(ctx, repos[start:end])
+		for i, repo := range repos[start:end] {
+			prefetchedRepos[start+i] = &prefetched{
+				key:    repositoryKey(repo),
+				result: results[i],
+			}
+		}
+	}
+	return prefetchedRepos
+}
+
+func repositoryKey(repo *github.Repository) string {
+	return strings.ToLower(*repo.Owner.Login + "/" + *repo.Name)
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+type graphQLError struct {
+	Type    string        `json:"type"`
+	Path    []interface{} `json:"path"`
+	Message string        `json:"message"`
+}
+
+type graphQLRepository struct {
+	IsEmpty     bool `json:"isEmpty"`
+	LicenseInfo *struct {
+		Key    string `json:"key"`
+		Name   string `json:"name"`
+		SpdxID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+	Collaborators *graphQLCollaborators `json:"collaborators"`
+	Object        *struct {
+		Entries []graphQLTreeEntry `json:"entries"`
+	} `json:"object"`
+}
+
+type graphQLCollaborators struct {
+	Edges []struct {
+		Permission string `json:"permission"`
+		Node       struct {
+			Login string `json:"login"`
+		} `json:"node"`
+	} `json:"edges"`
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+}
+
+type graphQLTreeEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+type graphQLBlob struct {
+	OID      string  `json:"oid"`
+	ByteSize int     `json:"byteSize"`
+	Text     *string `json:"text"` // nil if the blob is binary
+}
+
+// pendingFiles are the paths of the files of a repository whose content is retrieved after the batch query.
+type pendingFiles struct {
+	licensePath string
+	patentsPath string
+}
+
+// fetch retrieves the information for the provided repositories, which are requested in a single query. The returned
+// results are in the same order as the repositories.
+func (f *graphQLInfoFetcher) fetch(ctx context.Context, repos []*github.Repository) []batchResult {
+	results := make([]batchResult, len(repos))
+
+	var params, fields []string
+	variables := make(map[string]interface{})
+	for i, repo := range repos {
+		params = append(params, fmt.Sprintf("$owner%d: String!, $name%d: String!", i, i))
+		fields = append(fields, fmt.Sprintf("r%d: repository(owner: $owner%d, name: $name%d) { ...repositoryInfo }", i, i, i))
+		variables[fmt.Sprintf("owner%d", i)] = *repo.Owner.Login
+		variables[fmt.Sprintf("name%d", i)] = *repo.Name
+	}
+	query := fmt.Sprintf("query(%s) {\n%s\n}\n%s\n%s", strings.Join(params, ", "), strings.Join(fields, "\n"), graphQLRepositoryFragment, graphQLCollaboratorsFragment)
+
+	var data map[string]*graphQLRepository
+	queryErrs, err := f.query(ctx, query, variables, &data)
+	if err != nil {
+		for i, repo := range repos {
+			results[i].err = errors.Wrapf(err, "failed to get information for %s", *repo.FullName)
+		}
+		return results
+	}
+
+	files := make([]pendingFiles, len(repos))
+	for i, repo := range repos {
+		alias := fmt.Sprintf("r%d", i)
+		results[i].batch, files[i], results[i].err = f.toBatchInfo(ctx, repo, data[alias], queryErrs, alias)
+	}
+	f.fetchFiles(ctx, repos, files, results)
+	return results
+}
+
+// toBatchInfo returns the batchInfo for the provided repository based on the provided result of the batch query along
+// with the files whose content must still be retrieved.
+func (f *graphQLInfoFetcher) toBatchInfo(ctx context.Context, repo *github.Repository, result *graphQLRepository, queryErrs []graphQLError, alias string) (batchInfo, pendingFiles, error) {
+	collaboratorsUnknown, err := aliasError(queryErrs, alias)
+	if err != nil {
+		return batchInfo{}, pendingFiles{}, errors.Wrapf(err, "failed to get information for %s", *repo.FullName)
+	} else if result == nil {
+		return batchInfo{}, pendingFiles{}, errors.Errorf("failed to get information for %s: repository was not returned", *repo.FullName)
+	}
+	if result.IsEmpty {
+		return batchInfo{
+			isEmpty: true,
+		}, pendingFiles{}, nil
+	}
+
+	var batch batchInfo
+	var files pendingFiles
+	var entries []graphQLTreeEntry
+	if result.Object != nil {
+		entries = result.Object.Entries
+	}
+
+	if result.LicenseInfo != nil {
+		batch.repoLicense = &github.RepositoryLicense{
+			Type: github.String("file"),
+			License: &github.License{
+				Key:    github.String(result.LicenseInfo.Key),
+				Name:   github.String(result.LicenseInfo.Name),
+				SPDXID: github.String(result.LicenseInfo.SpdxID),
+			},
+		}
+		files.licensePath = licenseFilePath(entries)
+	}
+
+	if collaboratorsUnknown || result.Collaborators == nil {
+		batch.ownersUnknown = true
+	} else {
+		collaborators := result.Collaborators
+		for {
+			for _, edge := range collaborators.Edges {
+				if edge.Permission == "ADMIN" {
+					batch.owners = append(batch.owners, edge.Node.Login)
+				}
+			}
+			if !collaborators.PageInfo.HasNextPage {
+				break
+			}
+			if collaborators, err = f.collaborators(ctx, repo, collaborators.PageInfo.EndCursor); err != nil {
+				return batchInfo{}, pendingFiles{}, err
+			}
+		}
+		sort.Sort(CaseInsensitiveStrings(batch.owners))
+	}
+
+	for _, entry := range entries {
+		if name := strings.ToLower(entry.Name); name == "patents" || name == "patents.txt" {
+			batch.patentsPath = entry.Path
+			break
+		}
+	}
+	files.patentsPath = batch.patentsPath
+	return batch, files, nil
+}
+
+// collaborators returns the page of the collaborators of the provided repository that follows the provided cursor.
+func (f *graphQLInfoFetcher) collaborators(ctx context.Context, repo *github.Repository, cursor string) (*graphQLCollaborators, error) {
+	query := fmt.Sprintf(`query($owner: String!, $name: String!, $cursor: String!) {
+repository(owner: $owner, name: $name) { collaborators(first: 100, after: $cursor) { ...collaborators } }
+}
+%s`, graphQLCollaboratorsFragment)
+	var data struct {
+		Repository *struct {
+			Collaborators *graphQLCollaborators `json:"collaborators"`
+		} `json:"repository"`
+	}
+	queryErrs, err := f.query(ctx, query, map[string]interface{}{
+		"owner":  *repo.Owner.Login,
+		"name":   *repo.Name,
+		"cursor": cursor,
+	}, &data)
+	if err == nil && len(queryErrs) > 0 {
+		err = errors.New(queryErrs[0].Message)
+	}
+	if err == nil && (data.Repository == nil || data.Repository.Collaborators == nil) {
+		err = errors.New("collaborators were not returned")
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get collaborators for %s", *repo.FullName)
+	}
+	return data.Repository.Collaborators, nil
+}
+
+// fetchFiles retrieves the content of the provided files of the provided repositories in a single query and adds it to
+// the provided results. Content that cannot be retrieved using the query is retrieved using the REST API.
+func (f *graphQLInfoFetcher) fetchFiles(ctx context.Context, repos []*github.Repository, files []pendingFiles, results []batchResult) {
+	var params, fields []string
+	variables := make(map[string]interface{})
+	for i, repo := range repos {
+		if results[i].err != nil || (files[i].licensePath == "" && files[i].patentsPath == "") {
+			continue
+		}
+		var objects []string
+		params = append(params, fmt.Sprintf("$owner%d: String!, $name%d: String!", i, i))
+		variables[fmt.Sprintf("owner%d", i)] = *repo.Owner.Login
+		variables[fmt.Sprintf("name%d", i)] = *repo.Name
+		if files[i].licensePath != "" {
+			params = append(params, fmt.Sprintf("$license%d: String!", i))
+			objects = append(objects, fmt.Sprintf("license: object(expression: $license%d) { ...blob }", i))
+			variables[fmt.Sprintf("license%d", i)] = "HEAD:" + files[i].licensePath
+		}
+		if files[i].patentsPath != "" {
+			params = append(params, fmt.Sprintf("$patents%d: String!", i))
+			objects = append(objects, fmt.Sprintf("patents: object(expression: $patents%d) { ...blob }", i))
+			variables[fmt.Sprintf("patents%d", i)] = "HEAD:" + files[i].patentsPath
+		}
+		fields = append(fields, fmt.Sprintf("r%d: repository(owner: $owner%d, name: $name%d) { %s }", i, i, i, strings.Join(objects, " ")))
+	}
+
+	data := make(map[string]*struct {
+		License *graphQLBlob `json:"license"`
+		Patents *graphQLBlob `json:"patents"`
+	})
+	var queryErrs []graphQLError
+	var err error
+	if len(fields) > 0 {
+		query := fmt.Sprintf("query(%s) {\n%s\n}\n%s", strings.Join(params, ", "), strings.Join(fields, "\n"), graphQLBlobFragment)
+		queryErrs, err = f.query(ctx, query, variables, &data)
+	}
+
+	for i, repo := range repos {
+		if results[i].err != nil {
+			continue
+		}
+		alias := fmt.Sprintf("r%d", i)
+		if err != nil {
+			results[i].err = errors.Wrapf(err, "failed to get files of %s", *repo.FullName)
+			continue
+		}
+		if _, aliasErr := aliasError(queryErrs, alias); aliasErr != nil {
+			results[i].err = errors.Wrapf(aliasErr, "failed to get files of %s", *repo.FullName)
+			continue
+		}
+		var license, patents *graphQLBlob
+		if result := data[alias]; result != nil {
+			license, patents = result.License, result.Patents
+		}
+
+		batch := &results[i].batch
+		if batch.repoLicense != nil {
+			if license != nil && license.Text != nil {
+				content := base64.StdEncoding.EncodeToString([]byte(*license.Text))
+				batch.repoLicense.Name = github.String(path.Base(files[i].licensePath))
+				batch.repoLicense.Path = github.String(files[i].licensePath)
+				batch.repoLicense.SHA = github.String(license.OID)
+				batch.repoLicense.Size = github.Int(license.ByteSize)
+				batch.repoLicense.Content = &content
+				batch.repoLicense.Encoding = github.String("base64")
+			} else {
+				// license file could not be determined from the root directory, so use the one detected by GitHub
+				if err := ctx.Err(); err != nil {
+					results[i].err = err
+					continue
+				}
+				repoLicense, err := GetLicense(ctx, f.client, repo)
+				if err != nil {
+					results[i].err = errors.Wrapf(err, "failed to get license for %s", *repo.FullName)
+					continue
+				}
+				batch.repoLicense = repoLicense
+			}
+		}
+		if batch.patentsPath != "" {
+			if patents != nil && patents.Text != nil {
+				batch.patentsContent = *patents.Text
+			} else {
+				content, _, err := GetFileContent(ctx, f.client, repo, batch.patentsPath)
+				if err != nil {
+					results[i].err = err
+					continue
+				}
+				batch.patentsContent = content
+			}
+		}
+	}
+}
+
+// query runs the provided GraphQL query and unmarshals the data of the response into data. Returns the errors in the
+// response that refer to a specific field (which are returned along with the data of the other fields) and an error if
+// the request or the query as a whole failed.
+func (f *graphQLInfoFetcher) query(ctx context.Context, query string, variables map[string]interface{}, data interface{}) ([]graphQLError, error) {
+	req, err := NewRequest(ctx, f.client, "POST", graphQLURL(f.client.BaseURL), graphQLRequest{
+		Query:     query,
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request")
+	}
+	var resp graphQLResponse
+	if _, err := f.client.Do(req, &resp); err != nil {
+		return nil, errors.Wrapf(err, "GraphQL request failed")
+	}
+	for _, queryErr := range resp.Errors {
+		if len(queryErr.Path) == 0 {
+			return nil, errors.Errorf("GraphQL query failed: %s", queryErr.Message)
+		}
+	}
+	if len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal GraphQL response")
+		}
+	}
+	return resp.Errors, nil
+}
+
+// aliasError returns an error for the first of the provided errors that refers to the field with the provided alias.
+// Errors that indicate that the collaborators of the field cannot be read (which is the case if the current user does
+// not have push access) are not returned: instead, the returned boolean is true.
+func aliasError(queryErrs []graphQLError, alias string) (bool, error) {
+	collaboratorsUnknown := false
+	for _, queryErr := range queryErrs {
+		if queryErr.Path[0] != alias {
+			continue
+		}
+		if len(queryErr.Path) > 1 && queryErr.Path[1] == "collaborators" && (queryErr.Type == "FORBIDDEN" || queryErr.Type == "NOT_FOUND") {
+			collaboratorsUnknown = true
+			continue
+		}
+		return false, errors.New(queryErr.Message)
+	}
+	return collaboratorsUnknown, nil
+}
+
+// licenseFilePath returns the path of the file in the provided root directory entries that is most likely to be the
+// license file detected by GitHub. Returns the empty string if there is no such file.
+func licenseFilePath(entries []graphQLTreeEntry) string {
+	bestPath, bestRank := "", -1
+	for _, entry := range entries {
+		if entry.Type != "blob" {
+			continue
+		}
+		name := strings.ToLower(entry.Name)
+		switch path.Ext(name) {
+		case "", ".md", ".markdown", ".txt", ".rst":
+			name = strings.TrimSuffix(name, path.Ext(name))
+		default:
+			continue
+		}
+		for rank, candidate := range []string{"unlicense", "copying", "licence", "license"} {
+			if name == candidate && rank > bestRank {
+				bestPath, bestRank = entry.Path, rank
+			}
+		}
+	}
+	return bestPath
+}
+
+// graphQLURL returns the URL of the GraphQL API for the REST API with the provided base URL. The GraphQL API of GitHub
+// Enterprise is at "/api/graphql" while its REST API is at "/api/v3/".
+func graphQLURL(baseURL *url.URL) string {
+	u := *baseURL
+	if strings.HasSuffix(u.Path, "/api/v3/") {
+		u.Path = strings.TrimSuffix(u.Path, "v3/")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/graphql"
+	return u.String()
+}
@@ -0,0 +1,410 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+const (
+	testLicense = "MIT License\n\nCopyright (c) 2016 Octocat\n"
+	testPatents = "Additional grant of patent rights\n"
+)
+
+func TestGraphQLInfoFetcher(t *testing.T) {
+	for i, currCase := range []struct {
+		basePath    string
+		graphQLPath string
+	}{
+		{basePath: "/", graphQLPath: "/graphql"},
+		// GitHub Enterprise
+		{basePath: "/api/v3/", graphQLPath: "/api/graphql"},
+	} {
+		server := newGraphQLServer(t)
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(server.URL + currCase.basePath)
+
+		repos := []*github.Repository{testRepo(1, "alpha"), testRepo(2, "beta"), testRepo(3, "gamma")}
+		repos[0].License = &github.License{Key: github.String("mit")}
+		var want []repository.Info
+		for _, repo := range repos {
+			info, err := repository.GetInfo(context.Background(), client, repo)
+			require.NoError(t, err, "Case %d", i)
+			want = append(want, info)
+		}
+		assert.Equal(t, []string{"alice", "Bob"}, want[0].Owners, "Case %d", i)
+		assert.True(t, want[0].HasPatents, "Case %d", i)
+		assert.True(t, want[1].IsEmpty, "Case %d", i)
+		assert.True(t, want[2].OwnersUnknown, "Case %d", i)
+		server.takeRequests()
+
+		fetcher := repository.NewGraphQLInfoFetcher(client)
+		var got []repository.Info
+		process := fetcher.ProcessFunc(func(ctx context.Context, repo *github.Repository, progress repository.Progress) error {
+			info, err := fetcher.GetInfo(ctx, repo, repository.AllSections)
+			if err != nil {
+				return err
+			}
+			got = append(got, info)
+			return nil
+		})
+		for j, repo := range repos {
+			require.NoError(t, process(context.Background(), repo, repository.Progress{
+				CurrPageRepo:     j,
+				CurrPageNumRepos: len(repos),
+			}), "Case %d", i)
+			if j < len(repos)-1 {
+				assert.Empty(t, server.takeRequests(), "Case %d: repositories should not be processed before the page is complete", i)
+			}
+		}
+		assert.Equal(t, want, got, "Case %d", i)
+
+		// batch query, second page of collaborators of alpha and contents of license and patents files
+		var graphQLRequests int
+		for _, req := range server.takeRequests() {
+			if req == "POST "+currCase.graphQLPath {
+				graphQLRequests++
+				continue
+			}
+			for _, batched := range []string{"/contributors", "/license", "/collaborators", "/contents/"} {
+				assert.NotContains(t, req, batched, "Case %d", i)
+			}
+		}
+		assert.Equal(t, 3, graphQLRequests, "Case %d", i)
+
+		server.Close()
+	}
+}
+
+func TestGraphQLInfoFetcherDoesNotKeepUnusedInformation(t *testing.T) {
+	server := newGraphQLServer(t)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	fetcher := repository.NewGraphQLInfoFetcher(client)
+	repos := []*github.Repository{testRepo(1, "alpha"), testRepo(2, "beta")}
+	process := fetcher.ProcessFunc(func(ctx context.Context, repo *github.Repository, progress repository.Progress) error {
+		// information of beta is not used (as is the case for a repository without a definition)
+		if *repo.Name == "beta" {
+			return nil
+		}
+		_, err := fetcher.GetInfo(ctx, repo, repository.AllSections)
+		return err
+	})
+	for i, repo := range repos {
+		require.NoError(t, process(context.Background(), repo, repository.Progress{
+			CurrPageRepo:     i,
+			CurrPageNumRepos: len(repos),
+		}))
+	}
+	server.takeRequests()
+
+	// information that was prefetched for the page is no longer available once the page has been processed
+	info, err := fetcher.GetInfo(context.Background(), repos[1], repository.AllSections)
+	require.NoError(t, err)
+	assert.True(t, info.IsEmpty)
+	assert.Contains(t, server.takeRequests(), "POST /graphql")
+}
+
+func TestGraphQLInfoFetcherRepositoryError(t *testing.T) {
+	server := newGraphQLServer(t)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	// repository that was not prefetched is requested on its own
+	fetcher := repository.NewGraphQLInfoFetcher(client)
+	_, err := fetcher.GetInfo(context.Background(), testRepo(4, "missing"), repository.AllSections)
+	assert.EqualError(t, err, "failed to get information for octocat/missing: Could not resolve to a Repository with the name 'missing'.")
+	assert.Equal(t, []string{"POST /graphql"}, server.takeRequests())
+}
+
+func TestInfoFetcherRetrievesOnlyRequestedSections(t *testing.T) {
+	server := newGraphQLServer(t)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	for i, currCase := range []struct {
+		sections repository.InfoSections
+		want     []string
+	}{
+		{
+			sections: 0,
+		},
+		{
+			sections: repository.HooksSection | repository.LabelsSection,
+			want:     []string{"/hooks", "/labels"},
+		},
+		{
+			sections: repository.AllSections,
+			want:     []string{"/hooks", "/branches", "/labels", "/invitations"},
+		},
+	} {
+		for _, fetcher := range []repository.InfoFetcher{repository.NewRESTInfoFetcher(client), repository.NewGraphQLInfoFetcher(client)} {
+			_, err := fetcher.GetInfo(context.Background(), testRepo(1, "alpha"), currCase.sections)
+			require.NoError(t, err, "Case %d", i)
+
+			var got []string
+			for _, req := range server.takeRequests() {
+				for _, section := range []string{"/hooks", "/branches", "/labels", "/invitations"} {
+					if strings.HasSuffix(req, section) {
+						got = append(got, section)
+					}
+				}
+			}
+			assert.Equal(t, currCase.want, got, "Case %d", i)
+		}
+	}
+}
+
+func testRepo(id int, name string) *github.Repository {
+	return &github.Repository{
+		ID:       github.Int(id),
+		Owner:    &github.User{Login: github.String("octocat")},
+		Name:     github.String(name),
+		FullName: github.String("octocat/" + name),
+	}
+}
+
+// graphQLServer is a stand-in for the GitHub API that provides the same repositories using the REST and the GraphQL
+// API:
+//
+//   - alpha has an MIT license, a PATENTS file, two admin collaborators and one other collaborator
+//   - beta is empty
+//   - gamma has no license and its collaborators cannot be listed
+type graphQLServer struct {
+	*httptest.Server
+	t        *testing.T
+	mutex    sync.Mutex
+	requests []string // method and path of the requests received since takeRequests was last called
+}
+
+func newGraphQLServer(t *testing.T) *graphQLServer {
+	s := &graphQLServer{
+		t: t,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.mutex.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/graphql") {
+			s.serveGraphQL(w, r)
+			return
+		}
+		s.serveREST(w, strings.TrimPrefix(r.URL.Path, "/api/v3"))
+	}))
+	return s
+}
+
+func (s *graphQLServer) takeRequests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func (s *graphQLServer) serveREST(w http.ResponseWriter, path string) {
+	var resp interface{}
+	switch path {
+	case "/repos/octocat/alpha", "/repos/octocat/beta", "/repos/octocat/gamma":
+		resp = map[string]interface{}{}
+	case "/repos/octocat/beta/contributors":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "/repos/octocat/alpha/contributors", "/repos/octocat/gamma/contributors":
+		resp = []map[string]string{{"login": "alice"}}
+	case "/repos/octocat/alpha/branches", "/repos/octocat/gamma/branches":
+		resp = []map[string]string{{"name": "master"}}
+	case "/repos/octocat/alpha/license":
+		resp = github.RepositoryLicense{
+			Name:     github.String("LICENSE"),
+			Path:     github.String("LICENSE"),
+			SHA:      github.String("license-sha"),
+			Size:     github.Int(len(testLicense)),
+			Type:     github.String("file"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(testLicense))),
+			Encoding: github.String("base64"),
+			License: &github.License{
+				Key:    github.String("mit"),
+				Name:   github.String("MIT License"),
+				SPDXID: github.String("MIT"),
+			},
+		}
+	case "/repos/octocat/alpha/collaborators":
+		resp = []map[string]interface{}{
+			{"login": "alice", "permissions": map[string]bool{"admin": true}},
+			{"login": "carol", "permissions": map[string]bool{"admin": false}},
+			{"login": "Bob", "permissions": map[string]bool{"admin": true}},
+		}
+	case "/repos/octocat/gamma/collaborators":
+		w.WriteHeader(http.StatusForbidden)
+		resp = map[string]string{"message": "Must have push access to view repository collaborators."}
+	case "/repos/octocat/alpha/contents/":
+		resp = []map[string]string{
+			{"name": "LICENSE", "path": "LICENSE", "type": "file"},
+			{"name": "PATENTS", "path": "PATENTS", "type": "file"},
+			{"name": "README.md", "path": "README.md", "type": "file"},
+		}
+	case "/repos/octocat/gamma/contents/":
+		resp = []map[string]string{
+			{"name": "README.md", "path": "README.md", "type": "file"},
+		}
+	case "/repos/octocat/alpha/contents/PATENTS":
+		resp = map[string]string{
+			"name":     "PATENTS",
+			"path":     "PATENTS",
+			"type":     "file",
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte(testPatents)),
+		}
+	case "/repos/octocat/alpha/hooks", "/repos/octocat/beta/hooks", "/repos/octocat/gamma/hooks",
+		"/repos/octocat/alpha/labels", "/repos/octocat/gamma/labels", "/repositories/1/invitations":
+		resp = []interface{}{}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		resp = map[string]string{"message": "Not Found"}
+	}
+	s.write(w, resp)
+}
+
+func (s *graphQLServer) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&req))
+
+	data := make(map[string]interface{})
+	var errs []map[string]interface{}
+	if strings.Contains(req.Query, "$cursor") {
+		// second page of collaborators
+		require.Equal(s.t, map[string]string{"owner": "octocat", "name": "alpha", "cursor": "alpha-cursor"}, req.Variables)
+		data["repository"] = map[string]interface{}{
+			"collaborators": collaborators(nil, "Bob", "ADMIN"),
+		}
+		s.write(w, map[string]interface{}{"data": data})
+		return
+	}
+
+	for i := 0; i < 30; i++ {
+		alias := fmt.Sprintf("r%d", i)
+		owner, name := req.Variables[fmt.Sprintf("owner%d", i)], req.Variables[fmt.Sprintf("name%d", i)]
+		if owner == "" {
+			continue
+		}
+		require.Equal(s.t, "octocat", owner)
+
+		if strings.Contains(req.Query, "...blob") {
+			files := make(map[string]interface{})
+			for field, content := range map[string]string{"license": testLicense, "patents": testPatents} {
+				expression, ok := req.Variables[fmt.Sprintf("%s%d", field, i)]
+				if !ok {
+					continue
+				}
+				require.Equal(s.t, "HEAD:"+strings.ToUpper(field), expression)
+				files[field] = map[string]interface{}{
+					"oid":      field + "-sha",
+					"byteSize": len(content),
+					"text":     content,
+				}
+			}
+			data[alias] = files
+			continue
+		}
+
+		switch name {
+		case "alpha":
+			data[alias] = map[string]interface{}{
+				"isEmpty":       false,
+				"licenseInfo":   map[string]string{"key": "mit", "name": "MIT License", "spdxId": "MIT"},
+				"collaborators": collaborators(github.String("alpha-cursor"), "alice", "ADMIN", "carol", "WRITE"),
+				"object":        tree("LICENSE", "PATENTS", "README.md"),
+			}
+		case "beta":
+			data[alias] = map[string]interface{}{
+				"isEmpty":       true,
+				"licenseInfo":   nil,
+				"collaborators": collaborators(nil),
+				"object":        nil,
+			}
+		case "gamma":
+			data[alias] = map[string]interface{}{
+				"isEmpty":       false,
+				"licenseInfo":   nil,
+				"collaborators": nil,
+				"object":        tree("README.md"),
+			}
+			errs = append(errs, map[string]interface{}{
+				"type":    "FORBIDDEN",
+				"path":    []string{alias, "collaborators"},
+				"message": "Must have push access to view repository collaborators.",
+			})
+		default:
+			data[alias] = nil
+			errs = append(errs, map[string]interface{}{
+				"type":    "NOT_FOUND",
+				"path":    []string{alias},
+				"message": fmt.Sprintf("Could not resolve to a Repository with the name '%s'.", name),
+			})
+		}
+	}
+	s.write(w, map[string]interface{}{"data": data, "errors": errs})
+}
+
+func (s *graphQLServer) write(w http.ResponseWriter, resp interface{}) {
+	bytes, err := json.Marshal(resp)
+	require.NoError(s.t, err)
+	_, err = w.Write(bytes)
+	require.NoError(s.t, err)
+}
+
+// collaborators returns a page of collaborators with the provided logins and permissions (provided as pairs). The page
+// is followed by another page if cursor is non-nil.
+func collaborators(cursor *string, loginsAndPermissions ...string) map[string]interface{} {
+	var edges []map[string]interface{}
+	for i := 0; i < len(loginsAndPermissions); i += 2 {
+		edges = append(edges, map[string]interface{}{
+			"permission": loginsAndPermissions[i+1],
+			"node":       map[string]string{"login": loginsAndPermissions[i]},
+		})
+	}
+	pageInfo := map[string]interface{}{"hasNextPage": false, "endCursor": nil}
+	if cursor != nil {
+		pageInfo = map[string]interface{}{"hasNextPage": true, "endCursor": *cursor}
+	}
+	return map[string]interface{}{
+		"edges":    edges,
+		"pageInfo": pageInfo,
+	}
+}
+
+func tree(names ...string) map[string]interface{} {
+	var entries []map[string]string
+	for _, name := range names {
+		entries = append(entries, map[string]string{"name": name, "path": name, "type": "blob"})
+	}
+	return map[string]interface{}{
+		"entries": entries,
+	}
+}
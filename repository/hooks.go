@@ -0,0 +1,150 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// Hook represents a webhook of a repository. Hooks are identified by their URL.
+type Hook struct {
+	URL         string   `yaml:"url" json:"url"`
+	Events      []string `yaml:"events,omitempty" json:"events,omitempty"`             // events that trigger the hook (GitHub defaults to "push")
+	ContentType string   `yaml:"content_type,omitempty" json:"content_type,omitempty"` // "json" or "form" (GitHub defaults to "form")
+	Active      *bool    `yaml:"active,omitempty" json:"active,omitempty"`             // GitHub defaults to true
+	// SecretEnv is the name of the environment variable that contains the secret of the hook. If it is non-empty, the
+	// hook must have a secret. Secrets are never stored in definitions.
+	SecretEnv string `yaml:"secret_env,omitempty" json:"secret_env,omitempty"`
+
+	ID        int  `yaml:"-" json:"-"` // ID of an existing hook
+	HasSecret bool `yaml:"-" json:"-"` // true if an existing hook has a secret
+}
+
+// RedactedURL returns the URL of the hook for use in messages. The user information and the query of a hook URL often
+// contain credentials, so they are replaced with "REDACTED" in the same manner that secrets are never printed.
+func (h Hook) RedactedURL() string {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		// the parts of the URL that may contain credentials are not known, so none of it is shown
+		return "REDACTED"
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.String()
+}
+
+// ListHooks returns the webhooks of the provided repository sorted by URL. Service hooks are not included. If an error
+// occurs due to the GitHub API call failing, the HTTP response is returned as well.
+func ListHooks(ctx context.Context, client *github.Client, repo *github.Repository) ([]Hook, *github.Response, error) {
+	var hooks []Hook
+	for page := 1; page != 0; {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		var currHooks []*github.Hook
+		resp, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/hooks?page=%d", *repo.Owner.Login, *repo.Name, page), nil, &currHooks)
+		if err != nil {
+			return nil, resp, errors.Wrapf(err, "failed to list hooks for %s", *repo.FullName)
+		}
+		for _, currHook := range currHooks {
+			if currHook.Name == nil || *currHook.Name != "web" {
+				continue
+			}
+			hook := Hook{
+				URL:         configString(currHook.Config, "url"),
+				Events:      currHook.Events,
+				ContentType: configString(currHook.Config, "content_type"),
+				Active:      currHook.Active,
+				HasSecret:   configString(currHook.Config, "secret") != "",
+			}
+			if currHook.ID != nil {
+				hook.ID = *currHook.ID
+			}
+			hooks = append(hooks, hook)
+		}
+		page = resp.NextPage
+	}
+	sort.Sort(hooksByURL(hooks))
+	return hooks, nil, nil
+}
+
+// CreateHook creates the provided webhook in the provided repository. If secret is non-empty, it is used as the secret
+// of the hook.
+func CreateHook(ctx context.Context, client *github.Client, repo *github.Repository, hook Hook, secret string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := Do(ctx, client, "POST", fmt.Sprintf("repos/%v/%v/hooks", *repo.Owner.Login, *repo.Name), &github.Hook{
+		Name:   github.String("web"),
+		Events: hook.Events,
+		Active: hook.Active,
+		Config: hookConfig(hook, secret),
+	}, nil); err != nil {
+		return errors.Wrapf(err, "failed to create hook %s for %s", hook.RedactedURL(), *repo.FullName)
+	}
+	return nil
+}
+
+// EditHook updates the events, active flag and content type of the webhook with the provided ID to match the provided
+// hook. If secret is non-empty, it is set as the secret of the hook. Otherwise, the existing secret of the hook (if any)
+// is preserved.
+func EditHook(ctx context.Context, client *github.Client, repo *github.Repository, id int, hook Hook, secret string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := Do(ctx, client, "PATCH", fmt.Sprintf("repos/%v/%v/hooks/%d", *repo.Owner.Login, *repo.Name, id), &github.Hook{
+		Events: hook.Events,
+		Active: hook.Active,
+	}, nil); err != nil {
+		return errors.Wrapf(err, "failed to edit hook %s for %s", hook.RedactedURL(), *repo.FullName)
+	}
+
+	// the configuration is updated using the config endpoint because it only updates the provided fields, while
+	// editing the hook replaces its entire configuration (which would remove an existing secret)
+	req, err := NewRequest(ctx, client, "PATCH", fmt.Sprintf("repos/%v/%v/hooks/%d/config", *repo.Owner.Login, *repo.Name, id), hookConfig(hook, secret))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	if _, err := client.Do(req, nil); err != nil {
+		return errors.Wrapf(err, "failed to edit configuration of hook %s for %s", hook.RedactedURL(), *repo.FullName)
+	}
+	return nil
+}
+
+func hookConfig(hook Hook, secret string) map[string]interface{} {
+	config := map[string]interface{}{
+		"url": hook.URL,
+	}
+	if hook.ContentType != "" {
+		config["content_type"] = hook.ContentType
+	}
+	if secret != "" {
+		config["secret"] = secret
+	}
+	return config
+}
+
+func configString(config map[string]interface{}, key string) string {
+	if s, ok := config[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+type hooksByURL []Hook
+
+func (p hooksByURL) Len() int           { return len(p) }
+func (p hooksByURL) Less(i, j int) bool { return p[i].URL < p[j].URL }
+func (p hooksByURL) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
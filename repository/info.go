@@ -0,0 +1,65 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+
+	"github.com/google/go-github/github"
+)
+
+// InfoFetcher retrieves the Info of repositories. Implementations may retrieve part of the information for multiple
+// repositories at once, in which case they use ProcessFunc to determine the repositories that will be requested.
+type InfoFetcher interface {
+	// ProcessFunc returns a ProcessFunc that runs the provided function for the repositories passed to it. GetInfo
+	// should be called for the repositories from within the provided function with the context that it is passed (or
+	// one derived from it). The provided function may not be run for a repository until the last repository of its page
+	// has been passed to the returned function, and it is not run for the remaining repositories of the page once the
+	// context is done.
+	ProcessFunc(f ProcessFunc) ProcessFunc
+	// GetInfo returns the Info for the provided repository. Only the provided optional sections of the Info are
+	// retrieved.
+	GetInfo(ctx context.Context, repo *github.Repository, sections InfoSections) (Info, error)
+}
+
+// InfoSections is a set of the optional sections of an Info. Each section requires its own API calls, so only the
+// sections that are needed should be retrieved. The fields of the sections that are not retrieved are left empty.
+type InfoSections uint
+
+const (
+	SettingsSection      InfoSections = 1 << iota // Settings
+	HooksSection                                  // Hooks and HooksUnknown
+	ProtectionsSection                            // Branches, Protections and ProtectionsUnknown
+	TeamsSection                                  // Teams and TeamsUnknown
+	LabelsSection                                 // Labels
+	PendingOwnersSection                          // PendingOwners
+
+	// AllSections contains all of the optional sections of an Info.
+	AllSections = SettingsSection | HooksSection | ProtectionsSection | TeamsSection | LabelsSection | PendingOwnersSection
+)
+
+// NewRESTInfoFetcher returns an InfoFetcher that retrieves the Info of every repository on its own using the REST API
+// (see GetInfo).
+func NewRESTInfoFetcher(client *github.Client) InfoFetcher {
+	return &restInfoFetcher{
+		client: client,
+	}
+}
+
+type restInfoFetcher struct {
+	client *github.Client
+}
+
+func (f *restInfoFetcher) ProcessFunc(process ProcessFunc) ProcessFunc {
+	return process
+}
+
+func (f *restInfoFetcher) GetInfo(ctx context.Context, repo *github.Repository, sections InfoSections) (Info, error) {
+	batch, err := getBatchInfo(ctx, f.client, repo)
+	if err != nil {
+		return Info{}, err
+	}
+	return getInfo(ctx, f.client, repo, batch, sections)
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// media type required to read and write the descriptions of labels
+const mediaTypeLabelDescriptionPreview = "application/vnd.github.symmetra-preview+json"
+
+// Label represents an issue label of a repository.
+type Label struct {
+	Name        string `yaml:"name" json:"name"`
+	Color       string `yaml:"color" json:"color"` // hexadecimal color code without the leading "#", e.g. "d73a4a"
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// ListLabels returns all of the issue labels of the provided repository.
+func ListLabels(ctx context.Context, client *github.Client, repo *github.Repository) ([]Label, error) {
+	var labels []Label
+	for page := 1; page != 0; {
+		req, err := NewRequest(ctx, client, "GET", fmt.Sprintf("%s?page=%d", labelsURL(repo), page), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create request")
+		}
+		req.Header.Set("Accept", mediaTypeLabelDescriptionPreview)
+
+		var currLabels []Label
+		resp, err := client.Do(req, &currLabels)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list labels for %s", *repo.FullName)
+		}
+		labels = append(labels, currLabels...)
+		page = resp.NextPage
+	}
+	return labels, nil
+}
+
+// CreateLabel creates the provided label in the provided repository.
+func CreateLabel(ctx context.Context, client *github.Client, repo *github.Repository, label Label) error {
+	return doLabelRequest(ctx, client, "POST", labelsURL(repo), label, "failed to create label %s for %s", label.Name, *repo.FullName)
+}
+
+// EditLabel updates the color and description of the label with the provided name in the provided repository to match
+// the provided label.
+func EditLabel(ctx context.Context, client *github.Client, repo *github.Repository, name string, label Label) error {
+	return doLabelRequest(ctx, client, "PATCH", labelsURL(repo)+"/"+pathEscape(name), label, "failed to edit label %s for %s", name, *repo.FullName)
+}
+
+// DeleteLabel deletes the label with the provided name from the provided repository. Deleting a label removes it from
+// all of the issues and pull requests to which it is applied.
+func DeleteLabel(ctx context.Context, client *github.Client, repo *github.Repository, name string) error {
+	return doLabelRequest(ctx, client, "DELETE", labelsURL(repo)+"/"+pathEscape(name), nil, "failed to delete label %s for %s", name, *repo.FullName)
+}
+
+func doLabelRequest(ctx context.Context, client *github.Client, method, urlStr string, body interface{}, errFormat string, errArgs ...interface{}) error {
+	req, err := NewRequest(ctx, client, method, urlStr, body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeLabelDescriptionPreview)
+	if _, err := client.Do(req, nil); err != nil {
+		return errors.Wrapf(err, errFormat, errArgs...)
+	}
+	return nil
+}
+
+func labelsURL(repo *github.Repository) string {
+	return fmt.Sprintf("repos/%v/%v/labels", *repo.Owner.Login, *repo.Name)
+}
@@ -0,0 +1,133 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// OutputProcessFunc is a function that processes a repository and writes its output to the provided writer.
+type OutputProcessFunc func(ctx context.Context, repo *github.Repository, progress Progress, stdout io.Writer) error
+
+// Pool processes repositories concurrently using a bounded number of goroutines. The output of the function for each
+// repository is buffered and written in the order in which the repositories were submitted, so the output is the same as
+// if the repositories were processed one at a time. If processing a repository fails, no further repositories are
+// processed and the error is returned by Wait and by the ProcessFunc for all further repositories. Repositories that are
+// already being processed when the failure occurs are finished and their output is still written (in submission order
+// after the output of the failed repository) because it may describe changes that were made.
+type Pool struct {
+	stdout  io.Writer
+	tokens  chan struct{}
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	results []*poolResult // results in submission order
+	flushed int           // number of results whose output has been written
+	err     error         // first error in submission order
+}
+
+type poolResult struct {
+	output bytes.Buffer
+	done   bool
+	err    error
+}
+
+// NewPool returns a pool that processes up to parallelism repositories at a time and writes the output to stdout. If
+// parallelism is less than 2, the repositories are processed one at a time by the goroutine that submits them and the
+// output is written to stdout directly (which allows the processing function to prompt for input).
+func NewPool(parallelism int, stdout io.Writer) *Pool {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &Pool{
+		stdout: stdout,
+		tokens: make(chan struct{}, parallelism),
+	}
+}
+
+// ProcessFunc returns a ProcessFunc that submits repositories to the pool for processing by the provided function. The
+// returned function blocks while the maximum number of repositories are being processed (or until the context is
+// done, in which case the repository is not submitted and the error of the context is returned). Wait must be called
+// after all of the repositories have been submitted.
+func (p *Pool) ProcessFunc(f OutputProcessFunc) ProcessFunc {
+	return func(ctx context.Context, repo *github.Repository, progress Progress) error {
+		if cap(p.tokens) == 1 {
+			err := f(ctx, repo, progress, p.stdout)
+			if err != nil {
+				p.mutex.Lock()
+				p.err = err
+				p.mutex.Unlock()
+			}
+			return err
+		}
+
+		select {
+		case p.tokens <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		p.mutex.Lock()
+		if p.err != nil {
+			p.mutex.Unlock()
+			<-p.tokens
+			return p.err
+		}
+		result := &poolResult{}
+		p.results = append(p.results, result)
+		p.mutex.Unlock()
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer func() {
+				<-p.tokens
+			}()
+
+			var err error
+			if !p.failed() {
+				err = f(ctx, repo, progress, &result.output)
+			}
+
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			result.done = true
+			result.err = err
+			p.flush()
+		}()
+		return nil
+	}
+}
+
+// Wait waits for all of the submitted repositories to be processed and returns the first error (in submission order)
+// returned by the processing function, if any.
+func (p *Pool) Wait() error {
+	p.wg.Wait()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+func (p *Pool) failed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err != nil
+}
+
+// flush writes the output of the completed results that follow the results that have already been written. Must be
+// called while holding the mutex.
+func (p *Pool) flush() {
+	for p.flushed < len(p.results) && p.results[p.flushed].done {
+		result := p.results[p.flushed]
+		p.flushed++
+		_, _ = p.stdout.Write(result.output.Bytes())
+		if p.err == nil {
+			p.err = result.err
+		}
+	}
+}
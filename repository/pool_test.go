@@ -0,0 +1,143 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+func TestPoolOrderedOutput(t *testing.T) {
+	for i, parallelism := range []int{0, 1, 2, 4, 10} {
+		buf := &bytes.Buffer{}
+		pool := repository.NewPool(parallelism, buf)
+
+		var mutex sync.Mutex
+		running, maxRunning := 0, 0
+		f := pool.ProcessFunc(func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			fmt.Fprintf(stdout, "Processing %s (%v)...", *repo.Name, progress)
+			// later repositories finish first
+			time.Sleep(time.Duration(10-progress.CurrPageRepo) * time.Millisecond)
+			fmt.Fprintln(stdout, "OK")
+
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+			return nil
+		})
+
+		var want string
+		for j := 0; j < 10; j++ {
+			progress := repository.Progress{CurrPageRepo: j, CurrPageNumRepos: 10}
+			require.NoError(t, f(context.Background(), &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", j))}, progress), "Case %d", i)
+			want += fmt.Sprintf("Processing repo-%d (%v)...OK\n", j, progress)
+		}
+		require.NoError(t, pool.Wait(), "Case %d", i)
+
+		assert.Equal(t, want, buf.String(), "Case %d", i)
+		wantMax := parallelism
+		if wantMax < 1 {
+			wantMax = 1
+		}
+		assert.True(t, maxRunning <= wantMax, "Case %d: %d repositories were processed concurrently", i, maxRunning)
+	}
+}
+
+func TestPoolStopsOnFirstError(t *testing.T) {
+	for i, parallelism := range []int{1, 3} {
+		buf := &bytes.Buffer{}
+		pool := repository.NewPool(parallelism, buf)
+		f := pool.ProcessFunc(func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
+			fmt.Fprintf(stdout, "%s\n", *repo.Name)
+			if *repo.Name == "repo-2" {
+				return errors.Errorf("failed to process %s", *repo.Name)
+			}
+			return nil
+		})
+
+		var submitErr error
+		for j := 0; j < 20 && submitErr == nil; j++ {
+			submitErr = f(context.Background(), &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", j))}, repository.Progress{CurrPageRepo: j})
+		}
+		assert.EqualError(t, pool.Wait(), "failed to process repo-2", "Case %d", i)
+		if submitErr != nil || parallelism == 1 {
+			// when repositories are processed concurrently, all of them may be submitted before the error occurs
+			assert.EqualError(t, submitErr, "failed to process repo-2", "Case %d", i)
+		}
+		// repositories that were already being processed when repo-2 failed are part of the output as well
+		assert.True(t, strings.HasPrefix(buf.String(), "repo-0\nrepo-1\nrepo-2\n"), "Case %d: unexpected output:\n%s", i, buf.String())
+	}
+}
+
+func TestPoolWritesOutputOfRepositoriesInProgressAfterError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	pool := repository.NewPool(3, buf)
+	var started sync.WaitGroup
+	started.Add(2)
+	release := make(chan struct{})
+	f := pool.ProcessFunc(func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
+		if *repo.Name == "repo-0" {
+			started.Wait()
+			return errors.Errorf("failed to process %s", *repo.Name)
+		}
+		started.Done()
+		<-release
+		fmt.Fprintf(stdout, "%s: opened pull request\n", *repo.Name)
+		return nil
+	})
+
+	for j := 0; j < 3; j++ {
+		require.NoError(t, f(context.Background(), &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", j))}, repository.Progress{CurrPageRepo: j}))
+	}
+	// a token is only available once repo-0 has failed, so no further repositories are processed
+	assert.EqualError(t, f(context.Background(), &github.Repository{Name: github.String("repo-3")}, repository.Progress{CurrPageRepo: 3}), "failed to process repo-0")
+
+	close(release)
+	assert.EqualError(t, pool.Wait(), "failed to process repo-0")
+	assert.Equal(t, "repo-1: opened pull request\nrepo-2: opened pull request\n", buf.String())
+}
+
+func TestPoolStopsSubmittingWhenContextDone(t *testing.T) {
+	buf := &bytes.Buffer{}
+	pool := repository.NewPool(2, buf)
+	release := make(chan struct{})
+	f := pool.ProcessFunc(func(ctx context.Context, repo *github.Repository, progress repository.Progress, stdout io.Writer) error {
+		<-release
+		fmt.Fprintf(stdout, "%s\n", *repo.Name)
+		return nil
+	})
+
+	for j := 0; j < 2; j++ {
+		require.NoError(t, f(context.Background(), &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", j))}, repository.Progress{CurrPageRepo: j}))
+	}
+	// pool is full, so submitting another repository blocks until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, f(ctx, &github.Repository{Name: github.String("repo-2")}, repository.Progress{CurrPageRepo: 2}))
+
+	close(release)
+	require.NoError(t, pool.Wait())
+	assert.Equal(t, "repo-0\nrepo-1\n", buf.String())
+}
@@ -0,0 +1,222 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// media types required to read and write review counts, code owner reviews and required signatures
+const mediaTypeBranchProtectionPreview = "application/vnd.github.luke-cage-preview+json, application/vnd.github.zzzax-preview+json"
+
+// BranchProtection represents the protection settings for a branch.
+type BranchProtection struct {
+	// Branch is the name of the branch or a glob pattern (as supported by path.Match) that matches the names of
+	// branches. The empty string represents the default branch of the repository.
+	Branch                  string   `yaml:"branch,omitempty" json:"branch,omitempty"`
+	RequiredReviews         bool     `yaml:"required_reviews,omitempty" json:"required_reviews,omitempty"`                     // true if pull request reviews are required before merging
+	RequiredReviewCount     int      `yaml:"required_review_count,omitempty" json:"required_review_count,omitempty"`           // number of approving reviews required
+	RequireCodeOwnerReviews bool     `yaml:"require_code_owner_reviews,omitempty" json:"require_code_owner_reviews,omitempty"` // true if code owners must review changes to the files they own
+	RequiredStatusChecks    []string `yaml:"required_status_checks,omitempty" json:"required_status_checks,omitempty"`         // contexts of status checks that must pass before merging
+	StrictStatusChecks      bool     `yaml:"strict_status_checks,omitempty" json:"strict_status_checks,omitempty"`             // true if branches must be up to date before merging
+	EnforceAdmins           bool     `yaml:"enforce_admins,omitempty" json:"enforce_admins,omitempty"`
+	RequireLinearHistory    bool     `yaml:"require_linear_history,omitempty" json:"require_linear_history,omitempty"`
+	RequireSignedCommits    bool     `yaml:"require_signed_commits,omitempty" json:"require_signed_commits,omitempty"`
+}
+
+type enabledSetting struct {
+	Enabled bool `json:"enabled"`
+}
+
+type statusChecksSetting struct {
+	Strict   bool     `json:"strict"`
+	Contexts []string `json:"contexts"`
+}
+
+type reviewsSetting struct {
+	RequireCodeOwnerReviews      bool `json:"require_code_owner_reviews"`
+	RequiredApprovingReviewCount int  `json:"required_approving_review_count"`
+}
+
+type restrictionsSetting struct {
+	Users []string `json:"users"`
+	Teams []string `json:"teams"`
+}
+
+// protectionResponse is the representation of branch protection returned by the GitHub API.
+type protectionResponse struct {
+	RequiredStatusChecks       *statusChecksSetting `json:"required_status_checks"`
+	RequiredPullRequestReviews *reviewsSetting      `json:"required_pull_request_reviews"`
+	EnforceAdmins              *enabledSetting      `json:"enforce_admins"`
+	RequiredLinearHistory      *enabledSetting      `json:"required_linear_history"`
+	RequiredSignatures         *enabledSetting      `json:"required_signatures"`
+	Restrictions               *struct {
+		Users []github.User `json:"users"`
+		Teams []github.Team `json:"teams"`
+	} `json:"restrictions"`
+}
+
+// protectionRequest is the representation of branch protection accepted by the GitHub API.
+type protectionRequest struct {
+	RequiredStatusChecks       *statusChecksSetting `json:"required_status_checks"`
+	EnforceAdmins              bool                 `json:"enforce_admins"`
+	RequiredPullRequestReviews *reviewsSetting      `json:"required_pull_request_reviews"`
+	Restrictions               *restrictionsSetting `json:"restrictions"`
+	RequiredLinearHistory      bool                 `json:"required_linear_history"`
+}
+
+// ListBranches returns the names of all of the branches of the provided repository and the names of the branches that
+// are protected.
+func ListBranches(ctx context.Context, client *github.Client, repo *github.Repository) (branches []string, protected []string, err error) {
+	for page := 1; page != 0; {
+		req, err := NewRequest(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/branches?page=%d", *repo.Owner.Login, *repo.Name, page), nil)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to create request")
+		}
+		var currBranches []struct {
+			Name      string `json:"name"`
+			Protected bool   `json:"protected"`
+		}
+		resp, err := client.Do(req, &currBranches)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to list branches for %s", *repo.FullName)
+		}
+		for _, currBranch := range currBranches {
+			branches = append(branches, currBranch.Name)
+			if currBranch.Protected {
+				protected = append(protected, currBranch.Name)
+			}
+		}
+		page = resp.NextPage
+	}
+	sort.Strings(branches)
+	sort.Strings(protected)
+	return branches, protected, nil
+}
+
+// GetBranchProtection returns the protection settings for the specified branch. Returns nil if the branch is not
+// protected. If an error occurs due to the GitHub API call failing, the HTTP response is returned as well.
+func GetBranchProtection(ctx context.Context, client *github.Client, repo *github.Repository, branch string) (*BranchProtection, *github.Response, error) {
+	protection, resp, err := getProtection(ctx, client, repo, branch)
+	if err != nil || protection == nil {
+		return nil, resp, err
+	}
+	output := &BranchProtection{
+		Branch: branch,
+	}
+	if checks := protection.RequiredStatusChecks; checks != nil {
+		output.RequiredStatusChecks = checks.Contexts
+		output.StrictStatusChecks = checks.Strict
+	}
+	if reviews := protection.RequiredPullRequestReviews; reviews != nil {
+		output.RequiredReviews = true
+		output.RequiredReviewCount = reviews.RequiredApprovingReviewCount
+		output.RequireCodeOwnerReviews = reviews.RequireCodeOwnerReviews
+	}
+	output.EnforceAdmins = protection.EnforceAdmins != nil && protection.EnforceAdmins.Enabled
+	output.RequireLinearHistory = protection.RequiredLinearHistory != nil && protection.RequiredLinearHistory.Enabled
+	output.RequireSignedCommits = protection.RequiredSignatures != nil && protection.RequiredSignatures.Enabled
+	return output, resp, nil
+}
+
+// UpdateBranchProtection sets the protection settings of the specified branch to match the provided protection. The
+// Branch field of the provided protection is ignored. Any existing push restrictions for the branch are preserved.
+func UpdateBranchProtection(ctx context.Context, client *github.Client, repo *github.Repository, branch string, protection BranchProtection) error {
+	current, _, err := getProtection(ctx, client, repo, branch)
+	if err != nil {
+		return err
+	}
+
+	body := protectionRequest{
+		EnforceAdmins:         protection.EnforceAdmins,
+		RequiredLinearHistory: protection.RequireLinearHistory,
+	}
+	if len(protection.RequiredStatusChecks) > 0 || protection.StrictStatusChecks {
+		contexts := protection.RequiredStatusChecks
+		if contexts == nil {
+			contexts = []string{}
+		}
+		body.RequiredStatusChecks = &statusChecksSetting{
+			Strict:   protection.StrictStatusChecks,
+			Contexts: contexts,
+		}
+	}
+	if protection.RequiredReviews {
+		body.RequiredPullRequestReviews = &reviewsSetting{
+			RequireCodeOwnerReviews:      protection.RequireCodeOwnerReviews,
+			RequiredApprovingReviewCount: protection.RequiredReviewCount,
+		}
+	}
+	if current != nil && current.Restrictions != nil {
+		restrictions := &restrictionsSetting{
+			Users: []string{},
+			Teams: []string{},
+		}
+		for _, user := range current.Restrictions.Users {
+			restrictions.Users = append(restrictions.Users, *user.Login)
+		}
+		for _, team := range current.Restrictions.Teams {
+			restrictions.Teams = append(restrictions.Teams, *team.Slug)
+		}
+		body.Restrictions = restrictions
+	}
+
+	req, err := NewRequest(ctx, client, "PUT", protectionURL(repo, branch), body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeBranchProtectionPreview)
+	if _, err := client.Do(req, nil); err != nil {
+		return errors.Wrapf(err, "failed to update protection for branch %s of %s", branch, *repo.FullName)
+	}
+
+	method := "DELETE"
+	if protection.RequireSignedCommits {
+		method = "POST"
+	}
+	req, err = NewRequest(ctx, client, method, protectionURL(repo, branch)+"/required_signatures", nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeBranchProtectionPreview)
+	if resp, err := client.Do(req, nil); err != nil && (method == "POST" || resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return errors.Wrapf(err, "failed to update required signatures for branch %s of %s", branch, *repo.FullName)
+	}
+	return nil
+}
+
+// Returns the protection of the specified branch as returned by the GitHub API. Returns nil if the branch is not
+// protected.
+func getProtection(ctx context.Context, client *github.Client, repo *github.Repository, branch string) (*protectionResponse, *github.Response, error) {
+	req, err := NewRequest(ctx, client, "GET", protectionURL(repo, branch), nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeBranchProtectionPreview)
+
+	var protection protectionResponse
+	resp, err := client.Do(req, &protection)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Message == "Branch not protected" {
+				return nil, resp, nil
+			}
+		}
+		return nil, resp, errors.Wrapf(err, "failed to get protection for branch %s of %s", branch, *repo.FullName)
+	}
+	return &protection, resp, nil
+}
+
+// protectionURL returns the URL of the protection of the provided branch. The branch name is escaped because it may
+// contain characters such as "#", "?" or "%".
+func protectionURL(repo *github.Repository, branch string) string {
+	return fmt.Sprintf("repos/%v/%v/branches/%v/protection", *repo.Owner.Login, *repo.Name, pathEscape(branch))
+}
@@ -5,14 +5,25 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 )
 
-type ProcessFunc func(repo *github.Repository, progress Progress) error
+const (
+	mediaTypeLicensesPreview              = "application/vnd.github.drax-preview+json"
+	mediaTypeRepositoryInvitationsPreview = "application/vnd.github.swamp-thing-preview+json"
+)
+
+// ProcessFunc processes a repository. The provided context is cancelled when processing should stop, for example
+// because the user interrupted the program.
+type ProcessFunc func(ctx context.Context, repo *github.Repository, progress Progress) error
 
 type Progress struct {
 	CurrPageRepo     int
@@ -36,34 +47,55 @@ func (p Progress) String() string {
 // ProcessOrgRepos runs the provided function for every listed repository for the provided organization. If the
 // processing function returns an error, the error is returned immediately and all further processing is stopped. If
 // batch error processing is desired, the provided function should record and manage the errors itself but return nil as
-// an error.
-func ProcessOrgRepos(client *github.Client, org string, f ProcessFunc) error {
-	return processRepos(func(options github.ListOptions) ([]*github.Repository, *github.Response, error) {
-		return client.Repositories.ListByOrg(org, &github.RepositoryListByOrgOptions{ListOptions: options})
+// an error. If the provided context is cancelled, no further repositories are processed and the error of the context is
+// returned.
+func ProcessOrgRepos(ctx context.Context, client *github.Client, org string, f ProcessFunc) error {
+	return processRepos(ctx, func(page int) ([]*github.Repository, *github.Response, error) {
+		return listRepos(ctx, client, fmt.Sprintf("orgs/%v/repos?page=%d", org, page))
 	}, f)
 }
 
 // ProcessUserRepos runs the provided function for every listed repository for the provided user. If the processing
 // function returns an error, the error is returned immediately and all further processing is stopped. If batch error
 // processing is desired, the provided function should record and manage the errors itself but return nil as an error.
-func ProcessUserRepos(client *github.Client, user string, f ProcessFunc) error {
-	return processRepos(func(options github.ListOptions) ([]*github.Repository, *github.Response, error) {
-		return client.Repositories.List(user, &github.RepositoryListOptions{ListOptions: options})
+func ProcessUserRepos(ctx context.Context, client *github.Client, user string, f ProcessFunc) error {
+	return processRepos(ctx, func(page int) ([]*github.Repository, *github.Response, error) {
+		return listRepos(ctx, client, fmt.Sprintf("users/%v/repos?page=%d", user, page))
 	}, f)
 }
 
-func processRepos(listFunc func(options github.ListOptions) ([]*github.Repository, *github.Response, error), f ProcessFunc) error {
+// ProcessOwnerRepos runs the provided function for every listed repository for the provided owner, which is either a user
+// or an organization. Error handling is the same as for ProcessOrgRepos and ProcessUserRepos.
+func ProcessOwnerRepos(ctx context.Context, client *github.Client, owner string, f ProcessFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var user github.User
+	if _, err := Do(ctx, client, "GET", fmt.Sprintf("users/%v", owner), nil, &user); err != nil {
+		return errors.Wrapf(err, "failed to get user or organization %s", owner)
+	}
+	if user.Type != nil && *user.Type == "Organization" {
+		return ProcessOrgRepos(ctx, client, owner, f)
+	}
+	return ProcessUserRepos(ctx, client, owner, f)
+}
+
+func processRepos(ctx context.Context, listFunc func(page int) ([]*github.Repository, *github.Response, error), f ProcessFunc) error {
 	hasNext := true
 	page := 1
 	for hasNext {
-		repos, response, err := listFunc(github.ListOptions{
-			Page: page,
-		})
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		repos, response, err := listFunc(page)
 		if err != nil {
 			return errors.Wrapf(err, "failed to retrieve repositories")
 		}
 		for i, repo := range repos {
-			if err := f(repo, Progress{
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := f(ctx, repo, Progress{
 				CurrPageRepo:     i,
 				CurrPageNumRepos: len(repos),
 				CurrPage:         page,
@@ -82,13 +114,15 @@ func processRepos(listFunc func(options github.ListOptions) ([]*github.Repositor
 // function returns an error, the error is returned immediately and all further processing is stopped. If batch error
 // processing is desired, the provided function should record and manage the errors itself but return nil as an error.
 // If an error occurs due to the GitHub API call failing, the HTTP response is returned as well.
-func ProcessCollaborators(client *github.Client, repo *github.Repository, f func(user *github.User) error) (*github.Response, error) {
+func ProcessCollaborators(ctx context.Context, client *github.Client, repo *github.Repository, f func(user *github.User) error) (*github.Response, error) {
 	hasNext := true
 	page := 1
 	for hasNext {
-		users, response, err := client.Repositories.ListCollaborators(*repo.Owner.Login, *repo.Name, &github.ListOptions{
-			Page: page,
-		})
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var users []*github.User
+		response, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/collaborators?page=%d", *repo.Owner.Login, *repo.Name, page), nil, &users)
 		if err != nil {
 			return response, errors.Wrapf(err, "failed to retrieve collaborators")
 		}
@@ -103,23 +137,73 @@ func ProcessCollaborators(client *github.Client, repo *github.Repository, f func
 	return nil, nil
 }
 
+// ProcessInvitations runs the provided function for every open invitation to collaborate on the specified repository.
+// If the processing function returns an error, the error is returned immediately and all further processing is stopped.
+// If an error occurs due to the GitHub API call failing, the HTTP response is returned as well.
+func ProcessInvitations(ctx context.Context, client *github.Client, repo *github.Repository, f func(invitation *github.RepositoryInvitation) error) (*github.Response, error) {
+	hasNext := true
+	page := 1
+	for hasNext {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		req, err := NewRequest(ctx, client, "GET", fmt.Sprintf("repositories/%v/invitations?page=%d", *repo.ID, page), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create request")
+		}
+		req.Header.Set("Accept", mediaTypeRepositoryInvitationsPreview)
+
+		var invitations []*github.RepositoryInvitation
+		response, err := client.Do(req, &invitations)
+		if err != nil {
+			return response, errors.Wrapf(err, "failed to retrieve invitations")
+		}
+		for _, invitation := range invitations {
+			if err := f(invitation); err != nil {
+				return nil, err
+			}
+		}
+		hasNext = response.NextPage != 0
+		page = response.NextPage
+	}
+	return nil, nil
+}
+
+// AddCollaborator adds the provided user as a collaborator of the provided repository with the provided permission
+// ("pull", "push" or "admin"). If the user is already a collaborator, the permission of the user is updated.
+func AddCollaborator(ctx context.Context, client *github.Client, repo *github.Repository, user, permission string) error {
+	req, err := NewRequest(ctx, client, "PUT", fmt.Sprintf("repos/%v/%v/collaborators/%v", *repo.Owner.Login, *repo.Name, user), &github.RepositoryAddCollaboratorOptions{
+		Permission: permission,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeRepositoryInvitationsPreview)
+	_, err = client.Do(req, nil)
+	return err
+}
+
+// RemoveCollaborator removes the provided user as a collaborator of the provided repository.
+func RemoveCollaborator(ctx context.Context, client *github.Client, repo *github.Repository, user string) error {
+	_, err := Do(ctx, client, "DELETE", fmt.Sprintf("repos/%v/%v/collaborators/%v", *repo.Owner.Login, *repo.Name, user), nil, nil)
+	return err
+}
+
 // GetUserFork returns the a repository owned by the currently authenticated user that is a fork of the provided
 // repository. Returns nil if no such repository exists.
-func GetUserFork(client *github.Client, repo *github.Repository) (*github.Repository, error) {
+func GetUserFork(ctx context.Context, client *github.Client, repo *github.Repository) (*github.Repository, error) {
 	for currRepoPage := 1; currRepoPage != 0; {
-		currUserRepos, reposResp, err := client.Repositories.List("", &github.RepositoryListOptions{
-			Affiliation: "owner",
-			ListOptions: github.ListOptions{
-				Page: currRepoPage,
-			},
-		})
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		currUserRepos, reposResp, err := listRepos(ctx, client, fmt.Sprintf("user/repos?affiliation=owner&page=%d", currRepoPage))
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to get repositories for authenticated user")
 		}
 
 		for _, currUserRepo := range currUserRepos {
 			if *currUserRepo.Fork {
-				currRepo, _, err := client.Repositories.GetByID(*currUserRepo.ID)
+				currRepo, err := getRepo(ctx, client, fmt.Sprintf("repositories/%d", *currUserRepo.ID))
 				if err != nil {
 					return nil, errors.Wrapf(err, "failed to get repository with ID %d", *currUserRepo.ID)
 				}
@@ -134,10 +218,14 @@ func GetUserFork(client *github.Client, repo *github.Repository) (*github.Reposi
 }
 
 // CreateFork creates a fork of the given GitHub repository and blocks until the new forked repository is available.
-// Waits for a maximum of timeout seconds. If timeout is not positive, it defaults to 60.
-func CreateFork(client *github.Client, sourceRepo *github.Repository, timeout int) (*github.Repository, error) {
-	fork, _, err := client.Repositories.CreateFork(*sourceRepo.Owner.Login, *sourceRepo.Name, nil)
-	if err != nil {
+// Waits for a maximum of timeout seconds. If timeout is not positive, it defaults to 60. Stops waiting and returns the
+// error of the provided context if it is cancelled.
+func CreateFork(ctx context.Context, client *github.Client, sourceRepo *github.Repository, timeout int) (*github.Repository, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var fork github.Repository
+	if _, err := Do(ctx, client, "POST", fmt.Sprintf("repos/%v/%v/forks", *sourceRepo.Owner.Login, *sourceRepo.Name), nil, &fork); err != nil {
 		return nil, errors.Wrapf(err, "failed to create fork")
 	}
 
@@ -146,36 +234,96 @@ func CreateFork(client *github.Client, sourceRepo *github.Repository, timeout in
 		// if total timeout is not specified, default to 1 minute
 		timeout = 60
 	}
-	success := make(chan bool, 1)
-	go func() {
-		totalTimeWaited := 0
-		currWaitLen := 1
-		for totalTimeWaited < timeout {
-			if defaultBranch, _, err := client.Repositories.GetBranch(*fork.Owner.Login, *fork.Name, *fork.DefaultBranch); err == nil {
-				if _, _, err := client.Git.GetCommit(*fork.Owner.Login, *fork.Name, *defaultBranch.Commit.SHA); err == nil {
-					// if commit can be retrieved, fork is ready
-					success <- true
-					return
-				}
-			}
-
-			if currWaitLen+totalTimeWaited > timeout {
-				// if currWaitLength would cause wait time to exceed total timeout, adjust it
-				currWaitLen = timeout - totalTimeWaited
+	totalTimeWaited := 0
+	currWaitLen := 1
+	for totalTimeWaited < timeout {
+		var defaultBranch github.Branch
+		if _, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/branches/%v", *fork.Owner.Login, *fork.Name, *fork.DefaultBranch), nil, &defaultBranch); err == nil {
+			if _, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/git/commits/%v", *fork.Owner.Login, *fork.Name, *defaultBranch.Commit.SHA), nil, nil); err == nil {
+				// if commit can be retrieved, fork is ready
+				return &fork, nil
 			}
+		}
 
-			time.Sleep(time.Duration(currWaitLen) * time.Second)
+		if currWaitLen+totalTimeWaited > timeout {
+			// if currWaitLength would cause wait time to exceed total timeout, adjust it
+			currWaitLen = timeout - totalTimeWaited
+		}
 
-			// exponential back-off
-			totalTimeWaited += currWaitLen
-			currWaitLen *= 2
+		timer := time.NewTimer(time.Duration(currWaitLen) * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
 		}
-		// if repo fork was not completed
-		success <- false
-	}()
-	if !<-success {
-		return nil, errors.Errorf("timed out after waiting %d seconds for fork to be created", timeout)
+
+		// exponential back-off
+		totalTimeWaited += currWaitLen
+		currWaitLen *= 2
+	}
+	return nil, errors.Errorf("timed out after waiting %d seconds for fork to be created", timeout)
+}
+
+// pathEscape escapes the provided string so that it can be used as a single segment of a URL path (any "/" in it is
+// escaped as well).
+func pathEscape(s string) string {
+	return strings.Replace((&url.URL{Path: s}).EscapedPath(), "/", "%2F", -1)
+}
+
+// NewRequest returns a request created using client.NewRequest that is cancelled when the provided context is done.
+// The methods of the services of a client do not accept a context, so requests that should stop when the program is
+// interrupted or the timeout for a repository elapses (including any waits of RateLimitTransport) are created using
+// this function instead.
+func NewRequest(ctx context.Context, client *github.Client, method, urlStr string, body interface{}) (*http.Request, error) {
+	req, err := client.NewRequest(method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+// Do sends a request with the provided method, URL and body created using NewRequest and stores the decoded response in
+// v. It is used instead of the equivalent method of the services of the client for requests that only need the default
+// headers.
+func Do(ctx context.Context, client *github.Client, method, urlStr string, body, v interface{}) (*github.Response, error) {
+	req, err := NewRequest(ctx, client, method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req, v)
+}
+
+// GetRepository returns the repository with the provided owner and name.
+func GetRepository(ctx context.Context, client *github.Client, owner, name string) (*github.Repository, error) {
+	return getRepo(ctx, client, fmt.Sprintf("repos/%v/%v", owner, name))
+}
+
+func getRepo(ctx context.Context, client *github.Client, urlStr string) (*github.Repository, error) {
+	req, err := NewRequest(ctx, client, "GET", urlStr, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Accept", mediaTypeLicensesPreview)
 
-	return fork, nil
+	var repo github.Repository
+	if _, err := client.Do(req, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func listRepos(ctx context.Context, client *github.Client, urlStr string) ([]*github.Repository, *github.Response, error) {
+	req, err := NewRequest(ctx, client, "GET", urlStr, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", mediaTypeLicensesPreview)
+
+	var repos []*github.Repository
+	resp, err := client.Do(req, &repos)
+	if err != nil {
+		return nil, resp, err
+	}
+	return repos, resp, nil
 }
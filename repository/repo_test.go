@@ -0,0 +1,70 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+func TestProcessUserReposStopsWhenContextDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/users/octocat/repos", r.URL.Path)
+		require.NoError(t, json.NewEncoder(w).Encode([]*github.Repository{testRepo(1, "alpha"), testRepo(2, "beta"), testRepo(3, "gamma")}))
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var processed []string
+	err := repository.ProcessUserRepos(ctx, client, "octocat", func(ctx context.Context, repo *github.Repository, progress repository.Progress) error {
+		processed = append(processed, *repo.Name)
+		// interrupted while processing the first repository
+		cancel()
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"alpha"}, processed)
+}
+
+func TestCreateForkStopsWaitingWhenContextDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/octocat/alpha/forks":
+			w.WriteHeader(http.StatusAccepted)
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+				"owner":          map[string]string{"login": "hubot"},
+				"name":           "alpha",
+				"default_branch": "master",
+			}))
+		default:
+			// fork is never ready
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := repository.CreateFork(ctx, client, testRepo(1, "alpha"), 60)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second, "CreateFork waited %v after the context was done", time.Since(start))
+}
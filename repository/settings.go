@@ -0,0 +1,69 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// media type required to read and write the "is_template" field of a repository
+const mediaTypeTemplatePreview = "application/vnd.github.baptiste-preview+json"
+
+// Settings represents the configurable settings of a repository. The JSON field names match those used by the GitHub
+// repository API. A nil field is unspecified: it is not verified when used in a definition and not modified when used to
+// edit a repository.
+type Settings struct {
+	Private             *bool `yaml:"private,omitempty" json:"private,omitempty"`
+	HasIssues           *bool `yaml:"has_issues,omitempty" json:"has_issues,omitempty"`
+	HasWiki             *bool `yaml:"has_wiki,omitempty" json:"has_wiki,omitempty"`
+	HasProjects         *bool `yaml:"has_projects,omitempty" json:"has_projects,omitempty"`
+	AllowMergeCommit    *bool `yaml:"allow_merge_commit,omitempty" json:"allow_merge_commit,omitempty"`
+	AllowSquashMerge    *bool `yaml:"allow_squash_merge,omitempty" json:"allow_squash_merge,omitempty"`
+	AllowRebaseMerge    *bool `yaml:"allow_rebase_merge,omitempty" json:"allow_rebase_merge,omitempty"`
+	DeleteBranchOnMerge *bool `yaml:"delete_branch_on_merge,omitempty" json:"delete_branch_on_merge,omitempty"`
+	IsTemplate          *bool `yaml:"is_template,omitempty" json:"is_template,omitempty"`
+}
+
+// GetSettings returns the settings of the provided repository. The settings are retrieved using a separate API call
+// because the repository listing APIs do not return all of the settings fields.
+func GetSettings(ctx context.Context, client *github.Client, repo *github.Repository) (Settings, error) {
+	req, err := NewRequest(ctx, client, "GET", fmt.Sprintf("repos/%v/%v", *repo.Owner.Login, *repo.Name), nil)
+	if err != nil {
+		return Settings{}, errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeTemplatePreview)
+
+	var settings Settings
+	if _, err := client.Do(req, &settings); err != nil {
+		return Settings{}, errors.Wrapf(err, "failed to get settings for %s", *repo.FullName)
+	}
+	return settings, nil
+}
+
+// EditSettings updates the settings of the provided repository. Only the non-nil fields of the provided settings are
+// modified.
+func EditSettings(ctx context.Context, client *github.Client, repo *github.Repository, settings Settings) error {
+	body := struct {
+		Name *string `json:"name"`
+		Settings
+	}{
+		Name:     repo.Name,
+		Settings: settings,
+	}
+	req, err := NewRequest(ctx, client, "PATCH", fmt.Sprintf("repos/%v/%v", *repo.Owner.Login, *repo.Name), body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Accept", mediaTypeTemplatePreview)
+
+	if _, err := client.Do(req, nil); err != nil {
+		return errors.Wrapf(err, "failed to edit settings for %s", *repo.FullName)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Spec is a specification of repositories that consists of definitions and defaults for the fields of definitions. The
+// name of a definition is either the full name of a repository or a glob pattern (as supported by path.Match) that
+// matches the full names of repositories, such as "myorg/service-*".
+//
+// A specification is either a list of definitions or a map with the keys "include", "defaults" and "repositories":
+//
+//	include:
+//	- teams/
+//	defaults:
+//	  license: mit
+//	repositories:
+//	- name: myorg/service-*
+//	  owners: [alice]
+//	- name: myorg/service-auth
+//	  owners: [bob]
+//
+// The effective definition of a repository is determined by the top-level fields of the definition with the exact name
+// of the repository, then the fields of the first pattern definition that matches the repository, then the defaults.
+// A field that is specified in a more specific definition replaces the field entirely.
+type Spec struct {
+	defaults       map[string]interface{}
+	defaultsSource string      // path of the file that specifies the defaults
+	definitions    []specEntry // in the order in which they were loaded
+}
+
+type specEntry struct {
+	name   string
+	source string // path of the file that contains the definition (empty if it was not loaded from a file)
+	index  int    // 1-based index of the definition in its file
+	fields map[string]interface{}
+}
+
+func (e specEntry) location() string {
+	if e.source == "" {
+		return fmt.Sprintf("definition %d", e.index)
+	}
+	return fmt.Sprintf("%s (definition %d)", e.source, e.index)
+}
+
+type specFile struct {
+	Include      []string                 `yaml:"include"`
+	Defaults     map[string]interface{}   `yaml:"defaults"`
+	Repositories []map[string]interface{} `yaml:"repositories"`
+}
+
+// LoadSpec returns the specification defined by the file or directory at the provided path. If the path is a
+// directory, all of the YML and JSON files in the directory and its subdirectories are loaded in lexical order. A
+// specification file can load other files or directories using "include", where relative paths are resolved against
+// the directory that contains the file. Every file is loaded at most once. Returns an error if multiple definitions
+// have the same name or if more than one file specifies defaults.
+func LoadSpec(specPath string) (*Spec, error) {
+	files, err := resolveSpecFiles(specPath, make(map[string]struct{}))
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	for _, file := range files {
+		if err := spec.add(file.file, file.path); err != nil {
+			return nil, errors.Wrapf(err, "invalid specification %s", file.path)
+		}
+	}
+	if err := spec.checkDuplicates(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ResolveSpecFiles returns the paths of the files that are loaded by LoadSpec for the provided path in the order in
+// which they are loaded.
+func ResolveSpecFiles(specPath string) ([]string, error) {
+	files, err := resolveSpecFiles(specPath, make(map[string]struct{}))
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+	return paths, nil
+}
+
+// ParseSpec returns the specification defined by the provided YML or JSON content. The content cannot include other
+// files.
+func ParseSpec(content []byte) (*Spec, error) {
+	file, err := parseSpecFile(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Include) > 0 {
+		return nil, errors.Errorf("includes are only supported for specifications loaded from files")
+	}
+	spec := &Spec{}
+	if err := spec.add(file, ""); err != nil {
+		return nil, err
+	}
+	if err := spec.checkDuplicates(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func parseSpecFile(content []byte) (specFile, error) {
+	// JSON is converted to YML before parsing because not all valid JSON is valid YML (for example, JSON indented
+	// with tabs)
+	var jsonContent interface{}
+	if err := json.Unmarshal(content, &jsonContent); err == nil {
+		if yamlContent, err := yaml.Marshal(jsonContent); err == nil {
+			content = yamlContent
+		}
+	}
+
+	var file specFile
+	if err := yaml.Unmarshal(content, &file.Repositories); err != nil {
+		// content is not a list of definitions, so it must be a map with includes, defaults and repositories
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return specFile{}, errors.Wrapf(err, "failed to unmarshal specification")
+		}
+	}
+	return file, nil
+}
+
+type loadedSpecFile struct {
+	path string
+	file specFile
+}
+
+// Returns the files loaded for the provided path, where the files included by a file precede the file itself. Files
+// whose absolute paths are in loaded are skipped.
+func resolveSpecFiles(specPath string, loaded map[string]struct{}) ([]loadedSpecFile, error) {
+	fi, err := os.Stat(specPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", specPath)
+	}
+	if fi.IsDir() {
+		var files []loadedSpecFile
+		if err := filepath.Walk(specPath, func(currPath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(currPath)) {
+			case ".yml", ".yaml", ".json":
+				if !fi.IsDir() {
+					currFiles, err := resolveSpecFiles(currPath, loaded)
+					if err != nil {
+						return err
+					}
+					files = append(files, currFiles...)
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+
+	absPath, err := filepath.Abs(specPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine absolute path of %s", specPath)
+	}
+	if _, ok := loaded[absPath]; ok {
+		return nil, nil
+	}
+	loaded[absPath] = struct{}{}
+
+	content, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", specPath)
+	}
+	file, err := parseSpecFile(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", specPath)
+	}
+	var files []loadedSpecFile
+	for _, include := range file.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(specPath), include)
+		}
+		includedFiles, err := resolveSpecFiles(include, loaded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load include of %s", specPath)
+		}
+		files = append(files, includedFiles...)
+	}
+	return append(files, loadedSpecFile{
+		path: specPath,
+		file: file,
+	}), nil
+}
+
+// Adds the defaults and definitions of the provided file, which was loaded from the provided source, to the
+// specification.
+func (s *Spec) add(file specFile, source string) error {
+	if file.Defaults != nil {
+		if s.defaults != nil {
+			return errors.Errorf("defaults are already specified in %s", s.defaultsSource)
+		}
+		if _, ok := file.Defaults["name"]; ok {
+			return errors.Errorf("defaults cannot specify a name")
+		}
+		s.defaults = file.Defaults
+		s.defaultsSource = source
+	}
+	for i, fields := range file.Repositories {
+		name, ok := fields["name"].(string)
+		if !ok || name == "" {
+			return errors.Errorf("definition %d does not have a name", i+1)
+		}
+		if IsNamePattern(name) {
+			if _, err := path.Match(name, ""); err != nil {
+				return errors.Wrapf(err, "invalid pattern %s", name)
+			}
+		}
+		s.definitions = append(s.definitions, specEntry{
+			name:   name,
+			source: source,
+			index:  i + 1,
+			fields: fields,
+		})
+	}
+	return nil
+}
+
+// Returns an error that lists the locations of all of the definitions that have the same name (compared
+// case-insensitively) as another definition.
+func (s *Spec) checkDuplicates() error {
+	locations := make(map[string][]string)
+	var names []string
+	for _, def := range s.definitions {
+		key := strings.ToLower(def.name)
+		if _, ok := locations[key]; !ok {
+			names = append(names, def.name)
+		}
+		locations[key] = append(locations[key], def.location())
+	}
+	var duplicates []string
+	for _, name := range names {
+		if locs := locations[strings.ToLower(name)]; len(locs) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("%s: %s", name, strings.Join(locs, ", ")))
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	return errors.Errorf("duplicate definitions:\n\t%s", strings.Join(duplicates, "\n\t"))
+}
+
+// IsNamePattern returns true if the provided definition name is a glob pattern rather than the full name of a
+// repository.
+func IsNamePattern(name string) bool {
+	return strings.ContainsAny(name, `*?[\`)
+}
+
+// Names returns the names of the definitions in the specification that are not patterns in the order in which they are
+// specified.
+func (s *Spec) Names() []string {
+	var names []string
+	for _, def := range s.definitions {
+		if !IsNamePattern(def.name) {
+			names = append(names, def.name)
+		}
+	}
+	return names
+}
+
+// Owners returns the owners of the repositories named by the definitions in the specification in the order in which
+// they are first specified. The owners of pattern definitions are included if the owner part of the pattern is not a
+// pattern. Owners are compared case-insensitively.
+func (s *Spec) Owners() []string {
+	var owners []string
+	seen := make(map[string]struct{})
+	for _, def := range s.definitions {
+		idx := strings.Index(def.name, "/")
+		if idx == -1 || IsNamePattern(def.name[:idx]) {
+			continue
+		}
+		owner := def.name[:idx]
+		if _, ok := seen[strings.ToLower(owner)]; ok {
+			continue
+		}
+		seen[strings.ToLower(owner)] = struct{}{}
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// Definition returns the effective definition for the repository with the provided full name. Returns false if neither
+// a definition with the name of the repository nor a pattern definition that matches it exists. Names are compared
+// case-insensitively, like GitHub compares them.
+func (s *Spec) Definition(fullName string) (Definition, bool, error) {
+	var exact, pattern map[string]interface{}
+	for _, def := range s.definitions {
+		if IsNamePattern(def.name) {
+			if ok, _ := path.Match(strings.ToLower(def.name), strings.ToLower(fullName)); ok && pattern == nil {
+				pattern = def.fields
+			}
+		} else if strings.EqualFold(def.name, fullName) && exact == nil {
+			exact = def.fields
+		}
+	}
+	if exact == nil && pattern == nil {
+		return Definition{}, false, nil
+	}
+
+	merged := make(map[string]interface{})
+	for _, fields := range []map[string]interface{}{s.defaults, pattern, exact} {
+		for k, v := range fields {
+			merged[k] = v
+		}
+	}
+	merged["name"] = fullName
+
+	content, err := yaml.Marshal(merged)
+	if err != nil {
+		return Definition{}, false, errors.Wrapf(err, "failed to marshal definition for %s", fullName)
+	}
+	var def Definition
+	if err := yaml.Unmarshal(content, &def); err != nil {
+		return Definition{}, false, errors.Wrapf(err, "invalid definition for %s", fullName)
+	}
+	return def, true, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+const specWithDefaults = `defaults:
+  license: mit
+  patents: true
+  owners: [alice]
+  settings:
+    has_wiki: false
+repositories:
+- name: myorg/service-*
+  owners: [bob]
+  description: A service
+- name: myorg/*
+  description: Catch-all
+- name: myorg/service-auth
+  description: Authentication service
+  patents: false
+`
+
+func TestSpecDefinition(t *testing.T) {
+	repoSpec, err := repository.ParseSpec([]byte(specWithDefaults))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"myorg/service-auth"}, repoSpec.Names())
+
+	for i, currCase := range []struct {
+		name   string
+		want   repository.Definition
+		wantOK bool
+	}{
+		{
+			name: "myorg/service-auth",
+			want: repository.Definition{
+				FullName:    "myorg/service-auth",
+				Description: "Authentication service",
+				Owners:      []string{"bob"},
+				License:     "mit",
+				HasPatents:  false,
+				Settings:    &repository.Settings{HasWiki: boolPtr(false)},
+			},
+			wantOK: true,
+		},
+		{
+			name: "myorg/service-billing",
+			want: repository.Definition{
+				FullName:    "myorg/service-billing",
+				Description: "A service",
+				Owners:      []string{"bob"},
+				License:     "mit",
+				HasPatents:  true,
+				Settings:    &repository.Settings{HasWiki: boolPtr(false)},
+			},
+			wantOK: true,
+		},
+		{
+			name: "myorg/website",
+			want: repository.Definition{
+				FullName:    "myorg/website",
+				Description: "Catch-all",
+				Owners:      []string{"alice"},
+				License:     "mit",
+				HasPatents:  true,
+				Settings:    &repository.Settings{HasWiki: boolPtr(false)},
+			},
+			wantOK: true,
+		},
+		{
+			// names are compared case-insensitively and the provided name is used
+			name: "MyOrg/Service-Auth",
+			want: repository.Definition{
+				FullName:    "MyOrg/Service-Auth",
+				Description: "Authentication service",
+				Owners:      []string{"bob"},
+				License:     "mit",
+				HasPatents:  false,
+				Settings:    &repository.Settings{HasWiki: boolPtr(false)},
+			},
+			wantOK: true,
+		},
+		{
+			name: "MyOrg/Service-Billing",
+			want: repository.Definition{
+				FullName:    "MyOrg/Service-Billing",
+				Description: "A service",
+				Owners:      []string{"bob"},
+				License:     "mit",
+				HasPatents:  true,
+				Settings:    &repository.Settings{HasWiki: boolPtr(false)},
+			},
+			wantOK: true,
+		},
+		{
+			name: "otherorg/website",
+		},
+	} {
+		got, ok, err := repoSpec.Definition(currCase.name)
+		require.NoError(t, err, "Case %d", i)
+		assert.Equal(t, currCase.wantOK, ok, "Case %d", i)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestSpecOwners(t *testing.T) {
+	repoSpec, err := repository.ParseSpec([]byte(`- name: myorg/foo
+- name: otherorg/service-*
+- name: "*/website"
+- name: MyOrg/bar
+- name: nmiyake/baz
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"myorg", "otherorg", "nmiyake"}, repoSpec.Owners())
+}
+
+func TestParseSpecList(t *testing.T) {
+	repoSpec, err := repository.ParseSpec([]byte(`- name: nmiyake/foo
+  description: Foo
+- name: nmiyake/bar
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nmiyake/foo", "nmiyake/bar"}, repoSpec.Names())
+
+	got, ok, err := repoSpec.Definition("nmiyake/foo")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, repository.Definition{FullName: "nmiyake/foo", Description: "Foo"}, got)
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	for i, currCase := range []struct {
+		content string
+		want    string
+	}{
+		{
+			content: "- description: Foo\n",
+			want:    "definition 1 does not have a name",
+		},
+		{
+			content: "defaults:\n  name: foo\n",
+			want:    "defaults cannot specify a name",
+		},
+		{
+			content: "- name: myorg/[\n",
+			want:    "invalid pattern myorg/[: syntax error in pattern",
+		},
+	} {
+		_, err := repository.ParseSpec([]byte(currCase.content))
+		assert.EqualError(t, err, currCase.want, "Case %d", i)
+	}
+}
+
+func TestLoadSpecIncludes(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	for path, content := range map[string]string{
+		"spec.yml":           "include: [teams, shared.yml]\ndefaults:\n  license: mit\nrepositories:\n- name: myorg/root\n",
+		"shared.yml":         "include: [spec.yml]\nrepositories:\n- name: myorg/shared\n",
+		"teams/core.yml":     "- name: myorg/core\n  description: Core\n",
+		"teams/web/web.yaml": "repositories:\n- name: myorg/web\n",
+		"teams/README.md":    "not a specification",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, path)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, path), []byte(content), 0644))
+	}
+
+	repoSpec, err := repository.LoadSpec(filepath.Join(tmpDir, "spec.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"myorg/core", "myorg/web", "myorg/shared", "myorg/root"}, repoSpec.Names())
+	def, ok, err := repoSpec.Definition("myorg/core")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, repository.Definition{FullName: "myorg/core", Description: "Core", License: "mit"}, def)
+
+	repoSpec, err = repository.LoadSpec(filepath.Join(tmpDir, "teams"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"myorg/core", "myorg/web"}, repoSpec.Names())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "teams", "other.yml"), []byte("- name: MyOrg/Web\n- name: myorg/core\n"), 0644))
+	_, err = repository.LoadSpec(filepath.Join(tmpDir, "spec.yml"))
+	assert.EqualError(t, err, fmt.Sprintf("duplicate definitions:\n"+
+		"\tmyorg/core: %s (definition 1), %s (definition 2)\n"+
+		"\tMyOrg/Web: %s (definition 1), %s (definition 1)",
+		filepath.Join(tmpDir, "teams", "core.yml"), filepath.Join(tmpDir, "teams", "other.yml"),
+		filepath.Join(tmpDir, "teams", "other.yml"), filepath.Join(tmpDir, "teams", "web", "web.yaml")))
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
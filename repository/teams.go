@@ -0,0 +1,64 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// IsOrgRepo returns true if the provided repository is owned by an organization.
+func IsOrgRepo(repo *github.Repository) bool {
+	return repo.Owner != nil && repo.Owner.Type != nil && *repo.Owner.Type == "Organization"
+}
+
+// GetTeamPermissions returns a map from the slug of each team that has been granted access to the provided repository
+// to the permission of the team ("pull", "push" or "admin"). If an error occurs due to the GitHub API call failing, the
+// HTTP response is returned as well.
+func GetTeamPermissions(ctx context.Context, client *github.Client, repo *github.Repository) (map[string]string, *github.Response, error) {
+	permissions := make(map[string]string)
+	for page := 1; page != 0; {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		var teams []*github.Team
+		response, err := Do(ctx, client, "GET", fmt.Sprintf("repos/%v/%v/teams?page=%d", *repo.Owner.Login, *repo.Name, page), nil, &teams)
+		if err != nil {
+			return nil, response, errors.Wrapf(err, "failed to retrieve teams for %s", *repo.FullName)
+		}
+		for _, team := range teams {
+			var permission string
+			if team.Permission != nil {
+				permission = *team.Permission
+			}
+			permissions[*team.Slug] = permission
+		}
+		page = response.NextPage
+	}
+	return permissions, nil, nil
+}
+
+// GetTeamIDs returns a map from the slug of each team in the provided organization to the ID of the team.
+func GetTeamIDs(ctx context.Context, client *github.Client, org string) (map[string]int, error) {
+	ids := make(map[string]int)
+	for page := 1; page != 0; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var teams []*github.Team
+		response, err := Do(ctx, client, "GET", fmt.Sprintf("orgs/%v/teams?page=%d", org, page), nil, &teams)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to retrieve teams for organization %s", org)
+		}
+		for _, team := range teams {
+			ids[*team.Slug] = *team.ID
+		}
+		page = response.NextPage
+	}
+	return ids, nil
+}
@@ -5,18 +5,47 @@
 package spec
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
+
 	"github.com/nmiyake/ghcli/repository"
 )
 
+// Analyzer compares an aspect of a repository with its definition. Diff and Fix may make GitHub API calls, which
+// should stop as soon as possible once the provided context is done.
 type Analyzer interface {
 	Name() string
-	Diff(def repository.Definition, info repository.Info) string
+	Diff(ctx context.Context, def repository.Definition, info repository.Info) string
 	CanFix() bool
-	Fix(def repository.Definition, info repository.Info, stdout io.Writer) error
+	Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error
+}
+
+// InfoSectionsAnalyzer is an Analyzer that only uses some of the optional sections of the Info of a repository. All of
+// the sections are retrieved for analyzers that do not implement it (such as plugins, which receive the whole Info).
+type InfoSectionsAnalyzer interface {
+	Analyzer
+
+	// InfoSections returns the optional sections of the Info that Diff and Fix use for a repository with the provided
+	// definition.
+	InfoSections(def repository.Definition) repository.InfoSections
+}
+
+// InfoSections returns the optional sections of the Info of a repository with the provided definition that are used by
+// the provided analyzers.
+func InfoSections(def repository.Definition, analyzers []Analyzer) repository.InfoSections {
+	var sections repository.InfoSections
+	for _, analyzer := range analyzers {
+		sectionsAnalyzer, ok := analyzer.(InfoSectionsAnalyzer)
+		if !ok {
+			return repository.AllSections
+		}
+		sections |= sectionsAnalyzer.InfoSections(def)
+	}
+	return sections
 }
 
 func stringDiff(name, want, got string) string {
@@ -38,6 +67,21 @@ func joinDiff(name string, content ...string) string {
 	return strings.Join(parts, "\n")
 }
 
+// Returns the unified diff between the expected and actual content with trailing newlines removed.
+func contentDiff(want, got string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(want),
+		B:        difflib.SplitLines(got),
+		FromFile: "Expected",
+		ToFile:   "Actual",
+		Context:  0,
+	})
+	if err != nil {
+		return fmt.Sprintf("failed to compute diff: %v", err)
+	}
+	return strings.TrimSuffix(diff, "\n")
+}
+
 func orEmpty(in *string) string {
 	if in == nil {
 		return ""
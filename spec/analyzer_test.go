@@ -0,0 +1,72 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestInfoSections(t *testing.T) {
+	builtIn := []spec.Analyzer{
+		spec.NewDescriptionAnalyzer(nil),
+		spec.NewOwnersAnalyzer(nil, spec.IgnoreExtraOwners),
+		spec.NewSettingsAnalyzer(nil),
+		spec.NewBranchProtectionAnalyzer(nil),
+		spec.NewTeamsAnalyzer(nil),
+		spec.NewLabelsAnalyzer(nil, map[string][]repository.Label{"default": {{Name: "bug"}}}, false),
+		spec.NewHooksAnalyzer(nil),
+	}
+	withPlugin, err := spec.AddPluginAnalyzers(builtIn, nil, []spec.Plugin{
+		{Name: "badges", Command: "badges"},
+	})
+	require.NoError(t, err)
+
+	for i, currCase := range []struct {
+		def       repository.Definition
+		analyzers []spec.Analyzer
+		want      repository.InfoSections
+	}{
+		{
+			def:       repository.Definition{FullName: "octocat/Hello-World"},
+			analyzers: builtIn,
+			want:      0,
+		},
+		{
+			def: repository.Definition{
+				FullName: "octocat/Hello-World",
+				Owners:   []string{"octocat"},
+				Settings: &repository.Settings{HasWiki: github.Bool(false)},
+				LabelSet: "default",
+			},
+			analyzers: builtIn,
+			want:      repository.PendingOwnersSection | repository.SettingsSection | repository.LabelsSection,
+		},
+		{
+			def: repository.Definition{
+				FullName:         "octocat/Hello-World",
+				BranchProtection: []repository.BranchProtection{{}},
+				Teams:            map[string]string{},
+				Hooks:            []repository.Hook{},
+			},
+			analyzers: builtIn,
+			want:      repository.ProtectionsSection | repository.TeamsSection | repository.HooksSection,
+		},
+		// plugins receive the whole Info
+		{
+			def:       repository.Definition{FullName: "octocat/Hello-World"},
+			analyzers: withPlugin,
+			want:      repository.AllSections,
+		},
+	} {
+		assert.Equal(t, currCase.want, spec.InfoSections(currCase.def, currCase.analyzers), "Case %d", i)
+	}
+}
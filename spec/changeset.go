@@ -0,0 +1,123 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+// FileChangeAnalyzer is an Analyzer whose fix consists only of changes to the files of a repository. When a
+// specification is applied, the file changes of all of the analyzers of this type are combined into a single PR for
+// each repository using a ChangeSet instead of each analyzer opening its own PR using Fix.
+type FileChangeAnalyzer interface {
+	Analyzer
+
+	// FileChanges returns the file changes that fix the differences between the repository and the definition along
+	// with the parameters of the PR that Fix opens for them. The returned changes are empty if there is nothing to fix.
+	// If only some of the differences can be fixed, the changes for those differences are returned along with an error.
+	FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error)
+}
+
+// fileChangeDiffer is implemented by FileChangeAnalyzers that determine their differences and their file changes from
+// the same API calls. NewRepositoryPlan uses it to make those calls once per repository instead of once for Diff and
+// again for FileChanges.
+type fileChangeDiffer interface {
+	// diffAndFileChanges returns the result of Diff along with the result of FileChanges.
+	diffAndFileChanges(ctx context.Context, def repository.Definition, info repository.Info) (string, FileChanges, error)
+}
+
+// FileChanges are the file changes that an analyzer makes to a repository.
+type FileChanges struct {
+	Changes []license.FileChange
+	// PRParams describes the changes and is used to open the PR when the changes are applied on their own.
+	PRParams license.PRParams
+}
+
+// applyFileChanges opens a PR that makes the provided changes (if there are any).
+func applyFileChanges(ctx context.Context, client *github.Client, info repository.Info, changes FileChanges, stdout io.Writer) error {
+	if len(changes.Changes) == 0 {
+		return nil
+	}
+	return license.ApplyFileChanges(ctx, client, info, changes.Changes, changes.PRParams, stdout)
+}
+
+// ChangeSet is the set of file changes that multiple analyzers make to a single repository. The changes are applied
+// in a single commit on a single branch, and the body of the PR lists the changes made by each analyzer.
+type ChangeSet struct {
+	analyzers []string
+	changes   []FileChanges
+	paths     map[string]string // path of changed file -> analyzer that changes it
+}
+
+// Add adds the changes of the analyzer with the provided name to the change set. Returns an error if another analyzer
+// in the change set changes any of the same files.
+func (c *ChangeSet) Add(analyzer string, changes FileChanges) error {
+	if c.paths == nil {
+		c.paths = make(map[string]string)
+	}
+	for _, change := range changes.Changes {
+		if other, ok := c.paths[change.Path]; ok && other != analyzer {
+			return errors.Errorf("%s and %s both change %s", other, analyzer, change.Path)
+		}
+	}
+	for _, change := range changes.Changes {
+		c.paths[change.Path] = analyzer
+	}
+	c.analyzers = append(c.analyzers, analyzer)
+	c.changes = append(c.changes, changes)
+	return nil
+}
+
+// Analyzers returns the names of the analyzers that added changes to the change set in the order in which they were
+// added.
+func (c *ChangeSet) Analyzers() []string {
+	return c.analyzers
+}
+
+// Empty returns true if the change set does not change any files.
+func (c *ChangeSet) Empty() bool {
+	return len(c.paths) == 0
+}
+
+// PRParams returns the parameters of the PR that applies the change set. The same branch is used regardless of which
+// analyzers added changes, so a repository never has more than one such PR, and the body lists the title and body of
+// the changes of each analyzer.
+func (c *ChangeSet) PRParams() license.PRParams {
+	bodyParts := []string{"Update repository to match specification."}
+	for i, changes := range c.changes {
+		bodyParts = append(bodyParts, fmt.Sprintf("%s: %s\n%s", c.analyzers[i], changes.PRParams.Title, changes.PRParams.Body))
+	}
+	return license.PRParams{
+		Branch:        "cli-apply-spec",
+		Title:         "Update repository to match specification",
+		Body:          strings.Join(bodyParts, "\n\n"),
+		CommitMessage: "Update repository to match specification",
+	}
+}
+
+// Apply opens a PR on the provided repository that makes all of the changes in the change set in a single commit. If
+// dryRun is true, the changes are printed instead. See license.ApplyFileChanges for how the PR is opened and how the
+// provided context is handled.
+func (c *ChangeSet) Apply(ctx context.Context, client *github.Client, info repository.Info, dryRun bool, stdout io.Writer) error {
+	var changes []license.FileChange
+	for _, curr := range c.changes {
+		changes = append(changes, curr.Changes...)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	prParams := c.PRParams()
+	prParams.DryRun = dryRun
+	return license.ApplyFileChanges(ctx, client, info, changes, prParams, stdout)
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestChangeSetApply(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+
+	def := repository.Definition{HasPatents: true}
+	info := testInfo("octocat")
+	patentsChanges, err := spec.NewHasPatentsAnalyzer(server.client(), patentsTemplate).(spec.FileChangeAnalyzer).FileChanges(context.Background(), def, info)
+	require.NoError(t, err)
+
+	var changeSet spec.ChangeSet
+	assert.True(t, changeSet.Empty())
+	require.NoError(t, changeSet.Add("patents", patentsChanges))
+	// a single analyzer uses the same branch as multiple analyzers and its changes are listed in the body
+	assert.Equal(t, license.PRParams{
+		Branch:        "cli-apply-spec",
+		Title:         "Update repository to match specification",
+		Body:          "Update repository to match specification.\n\npatents: Add PATENTS\nUpdate patents file for repository to match specification.",
+		CommitMessage: "Update repository to match specification",
+	}, changeSet.PRParams())
+
+	require.NoError(t, changeSet.Add("files", spec.FileChanges{
+		Changes: []license.FileChange{
+			{Path: "README.md", Content: "Hello\n"},
+		},
+		PRParams: license.PRParams{
+			Branch: "cli-update-files",
+			Title:  "Update required files",
+			Body:   "Update files in repository to match specification:\n* Add README.md",
+		},
+	}))
+	err = changeSet.Add("plugin", spec.FileChanges{
+		Changes: []license.FileChange{
+			{Path: "README.md", Content: "Goodbye\n"},
+		},
+	})
+	assert.EqualError(t, err, "files and plugin both change README.md")
+	assert.Equal(t, []string{"patents", "files"}, changeSet.Analyzers())
+
+	require.NoError(t, changeSet.Apply(context.Background(), server.client(), info, false, &bytes.Buffer{}))
+
+	require.Len(t, server.trees, 1)
+	assert.Equal(t, []github.TreeEntry{
+		{
+			Path:    github.String("PATENTS"),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(patentsTemplate),
+		},
+		{
+			Path:    github.String("README.md"),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String("Hello\n"),
+		},
+	}, server.trees[0].Entries)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "cli-apply-spec", *server.pulls[0].Head)
+	assert.Equal(t, "Update repository to match specification", *server.pulls[0].Title)
+	assert.Equal(t, `Update repository to match specification.
+
+patents: Add PATENTS
+Update patents file for repository to match specification.
+
+files: Update required files
+Update files in repository to match specification:
+* Add README.md`, *server.pulls[0].Body)
+}
@@ -0,0 +1,290 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+// codeOwnersPaths are the locations of CODEOWNERS files supported by GitHub in order of precedence. The first path is
+// used when creating a new file.
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type codeOwnersAnalyzer struct {
+	client *github.Client
+}
+
+// NewCodeOwnersAnalyzer returns an analyzer that verifies that the CODEOWNERS file of a repository matches the owners
+// and the CODEOWNERS requirements of its definition. Fix opens a PR that regenerates the file.
+func NewCodeOwnersAnalyzer(client *github.Client) Analyzer {
+	return &codeOwnersAnalyzer{
+		client: client,
+	}
+}
+
+func (d *codeOwnersAnalyzer) Name() string {
+	return "codeowners"
+}
+
+func (d *codeOwnersAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	return 0
+}
+
+func (d *codeOwnersAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	diff, _, _ := d.diffAndFileChanges(ctx, def, info)
+	return diff
+}
+
+func (d *codeOwnersAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix opens a PR that regenerates the CODEOWNERS file. If the repository does not have a CODEOWNERS file, one is created
+// in the ".github" directory.
+func (d *codeOwnersAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, err := d.FileChanges(ctx, def, info)
+	if err != nil {
+		return err
+	}
+	if err := applyFileChanges(ctx, d.client, info, changes, stdout); err != nil {
+		return errors.Wrapf(err, "failed to fix CODEOWNERS")
+	}
+	return nil
+}
+
+// FileChanges returns the change that regenerates the CODEOWNERS file (or creates it in the ".github" directory).
+func (d *codeOwnersAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error) {
+	_, changes, err := d.diffAndFileChanges(ctx, def, info)
+	return changes, err
+}
+
+// diffAndFileChanges returns the differences between the CODEOWNERS file of the repository and the definition along
+// with the change that regenerates the file. The file is retrieved once for both.
+func (d *codeOwnersAnalyzer) diffAndFileChanges(ctx context.Context, def repository.Definition, info repository.Info) (string, FileChanges, error) {
+	if def.CodeOwners == nil || info.IsEmpty {
+		return "", FileChanges{}, nil
+	}
+	if d.client == nil {
+		return joinDiff(d.Name(), "unable to determine CODEOWNERS file: repository contents cannot be read without a GitHub client"), FileChanges{}, errors.Errorf("cannot determine CODEOWNERS file without a GitHub client")
+	}
+	path, content, err := d.codeOwnersFile(ctx, info)
+	if err != nil {
+		return joinDiff(d.Name(), err.Error()), FileChanges{}, err
+	}
+	diff := d.diff(def, path, content)
+	if diff == "" {
+		return "", FileChanges{}, nil
+	}
+	if path == "" {
+		path = codeOwnersPaths[0]
+	}
+	return diff, FileChanges{
+		Changes: []license.FileChange{
+			{
+				Path:    path,
+				Content: renderCodeOwners(def, parseCodeOwners(content)),
+			},
+		},
+		PRParams: license.PRParams{
+			Branch: "cli-update-codeowners",
+			Title:  "Update " + path,
+			Body:   "Update CODEOWNERS file for repository to match specification.",
+		},
+	}, nil
+}
+
+// Returns the differences between the CODEOWNERS file with the provided path and content and the definition. path is
+// empty if the repository does not have a CODEOWNERS file.
+func (d *codeOwnersAnalyzer) diff(def repository.Definition, path, content string) string {
+	if path == "" {
+		return joinDiff(d.Name(), "CODEOWNERS file missing")
+	}
+
+	lines := parseCodeOwners(content)
+	var parts []string
+	for _, rule := range wantCodeOwnersRules(def) {
+		got, ok := lastRule(lines, rule.Pattern)
+		switch {
+		case !ok:
+			parts = append(parts, fmt.Sprintf("%s: missing rule %s", path, formatRule(rule)))
+		case !sameOwners(rule.Owners, got.Owners):
+			parts = append(parts, fmt.Sprintf("%s: rule for %s: want %v, got %v", path, rule.Pattern, rule.Owners, got.Owners))
+		}
+	}
+	if def.CodeOwners.Strict {
+		managed := managedPatterns(def)
+		for _, line := range lines {
+			if line.rule != nil {
+				if _, ok := managed[line.rule.Pattern]; !ok {
+					parts = append(parts, fmt.Sprintf("%s: unmanaged rule %s", path, formatRule(*line.rule)))
+				}
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+// Returns the path and content of the CODEOWNERS file used by GitHub for the repository. Returns an empty path if the
+// repository does not have a CODEOWNERS file.
+func (d *codeOwnersAnalyzer) codeOwnersFile(ctx context.Context, info repository.Info) (string, string, error) {
+	for _, path := range codeOwnersPaths {
+		content, ok, err := repository.GetFileContent(ctx, d.client, &info.Repository, path)
+		if err != nil {
+			return "", "", err
+		}
+		if ok {
+			return path, content, nil
+		}
+	}
+	return "", "", nil
+}
+
+// codeOwnersLine is a line of a CODEOWNERS file. rule is nil for blank lines and comments.
+type codeOwnersLine struct {
+	text string
+	rule *repository.CodeOwnersRule
+}
+
+// inlineCommentRegexp matches the comment at the end of a rule: a "#" that follows whitespace starts a comment that
+// extends to the end of the line.
+var inlineCommentRegexp = regexp.MustCompile(`\s#.*$`)
+
+func parseCodeOwners(content string) []codeOwnersLine {
+	if content == "" {
+		return nil
+	}
+	var lines []codeOwnersLine
+	for _, text := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		line := codeOwnersLine{text: text}
+		if trimmed := strings.TrimSpace(text); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			fields := strings.Fields(inlineCommentRegexp.ReplaceAllString(trimmed, ""))
+			line.rule = &repository.CodeOwnersRule{
+				Pattern: fields[0],
+				Owners:  fields[1:],
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Returns the content of the CODEOWNERS file for the definition. Unless the CODEOWNERS requirements are strict, the
+// comments and unmanaged rules of the existing file are preserved in their original order. The default rule replaces
+// the existing default rule (or is placed first if there is no existing default rule) and the other rules of the
+// definition are placed last so that they take precedence.
+func renderCodeOwners(def repository.Definition, existing []codeOwnersLine) string {
+	rules := wantCodeOwnersRules(def)
+	defaultLine := formatRule(rules[0])
+
+	var output []string
+	if !def.CodeOwners.Strict {
+		managed := managedPatterns(def)
+		wroteDefault := false
+		for _, line := range existing {
+			if line.rule == nil {
+				output = append(output, line.text)
+				continue
+			}
+			if line.rule.Pattern == "*" {
+				if !wroteDefault {
+					output = append(output, defaultLine)
+					wroteDefault = true
+				}
+				continue
+			}
+			if _, ok := managed[line.rule.Pattern]; !ok {
+				output = append(output, line.text)
+			}
+		}
+		if !wroteDefault {
+			output = append([]string{defaultLine}, output...)
+		}
+	} else {
+		output = []string{defaultLine}
+	}
+	for _, rule := range rules[1:] {
+		output = append(output, formatRule(rule))
+	}
+	return strings.Join(output, "\n") + "\n"
+}
+
+// Returns the rules required by the definition with normalized owners. The first rule is always the default rule.
+func wantCodeOwnersRules(def repository.Definition) []repository.CodeOwnersRule {
+	rules := []repository.CodeOwnersRule{
+		{
+			Pattern: "*",
+			Owners:  normalizeCodeOwners(def.Owners),
+		},
+	}
+	for _, rule := range def.CodeOwners.Rules {
+		rules = append(rules, repository.CodeOwnersRule{
+			Pattern: rule.Pattern,
+			Owners:  normalizeCodeOwners(rule.Owners),
+		})
+	}
+	return rules
+}
+
+func managedPatterns(def repository.Definition) map[string]struct{} {
+	managed := map[string]struct{}{"*": {}}
+	for _, rule := range def.CodeOwners.Rules {
+		managed[rule.Pattern] = struct{}{}
+	}
+	return managed
+}
+
+// Returns the last rule in the provided lines with the provided pattern (the last matching rule takes precedence).
+func lastRule(lines []codeOwnersLine, pattern string) (repository.CodeOwnersRule, bool) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].rule != nil && lines[i].rule.Pattern == pattern {
+			return *lines[i].rule, true
+		}
+	}
+	return repository.CodeOwnersRule{}, false
+}
+
+// Returns the provided owners with a leading "@" added to usernames and teams. Email addresses are not modified.
+func normalizeCodeOwners(owners []string) []string {
+	normalized := make([]string, len(owners))
+	for i, owner := range owners {
+		if !strings.Contains(owner, "@") {
+			owner = "@" + owner
+		}
+		normalized[i] = owner
+	}
+	return normalized
+}
+
+// Returns true if want and got contain the same owners. Owners are compared case-insensitively and order is ignored.
+func sameOwners(want, got []string) bool {
+	return strings.Join(lowerSorted(want), " ") == strings.Join(lowerSorted(got), " ")
+}
+
+func lowerSorted(input []string) []string {
+	output := make([]string, len(input))
+	for i, s := range input {
+		output[i] = strings.ToLower(s)
+	}
+	sort.Strings(output)
+	return output
+}
+
+func formatRule(rule repository.CodeOwnersRule) string {
+	return strings.Join(append([]string{rule.Pattern}, rule.Owners...), " ")
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+const existingCodeOwners = `# Owners of the repository
+* @alice
+
+/docs/ @docs-team
+/src/ @carol
+`
+
+func TestCodeOwnersAnalyzer(t *testing.T) {
+	for i, currCase := range []struct {
+		files      map[string]string
+		codeOwners repository.CodeOwners
+		wantDiff   string
+		wantPath   string
+		wantFile   string
+	}{
+		{
+			files:    map[string]string{},
+			wantDiff: "codeowners:\n\tCODEOWNERS file missing",
+			wantPath: ".github/CODEOWNERS",
+			wantFile: "* @alice @bob\n",
+		},
+		{
+			files: map[string]string{
+				"docs/CODEOWNERS": existingCodeOwners,
+			},
+			codeOwners: repository.CodeOwners{
+				Rules: []repository.CodeOwnersRule{
+					{Pattern: "/src/", Owners: []string{"@dave"}},
+					{Pattern: "*.md", Owners: []string{"octocat/docs", "docs@example.com"}},
+				},
+			},
+			wantDiff: "codeowners:\n" +
+				"\tdocs/CODEOWNERS: rule for *: want [@alice @bob], got [@alice]\n" +
+				"\tdocs/CODEOWNERS: rule for /src/: want [@dave], got [@carol]\n" +
+				"\tdocs/CODEOWNERS: missing rule *.md @octocat/docs docs@example.com",
+			wantPath: "docs/CODEOWNERS",
+			wantFile: "# Owners of the repository\n" +
+				"* @alice @bob\n" +
+				"\n" +
+				"/docs/ @docs-team\n" +
+				"/src/ @dave\n" +
+				"*.md @octocat/docs docs@example.com\n",
+		},
+		{
+			files: map[string]string{
+				"CODEOWNERS": existingCodeOwners,
+			},
+			codeOwners: repository.CodeOwners{
+				Strict: true,
+			},
+			wantDiff: "codeowners:\n" +
+				"\tCODEOWNERS: rule for *: want [@alice @bob], got [@alice]\n" +
+				"\tCODEOWNERS: unmanaged rule /docs/ @docs-team\n" +
+				"\tCODEOWNERS: unmanaged rule /src/ @carol",
+			wantPath: "CODEOWNERS",
+			wantFile: "* @alice @bob\n",
+		},
+		{
+			files: map[string]string{
+				"CODEOWNERS": "* @alice @bob # default owners\n/docs/ @docs-team\t# documentation\n",
+			},
+			codeOwners: repository.CodeOwners{
+				Strict: true,
+			},
+			wantDiff: "codeowners:\n" +
+				"\tCODEOWNERS: unmanaged rule /docs/ @docs-team",
+			wantPath: "CODEOWNERS",
+			wantFile: "* @alice @bob\n",
+		},
+		{
+			files: map[string]string{
+				".github/CODEOWNERS": "* @Bob @alice\n",
+				"CODEOWNERS":         existingCodeOwners,
+			},
+		},
+	} {
+		server := newPRServer(t)
+		server.mux.HandleFunc("/repos/octocat/Hello-World/contents/", func(w http.ResponseWriter, r *http.Request) {
+			content, ok := currCase.files[strings.TrimPrefix(r.URL.Path, "/repos/octocat/Hello-World/contents/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			testutil.WriteJSON(t, w, github.RepositoryContent{
+				Type:     github.String("file"),
+				Encoding: github.String("base64"),
+				Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+			})
+		})
+
+		codeOwners := currCase.codeOwners
+		def := repository.Definition{
+			FullName:   "octocat/Hello-World",
+			Owners:     []string{"alice", "bob"},
+			CodeOwners: &codeOwners,
+		}
+		analyzer := spec.NewCodeOwnersAnalyzer(server.client())
+		assert.Equal(t, currCase.wantDiff, analyzer.Diff(context.Background(), def, testInfo("octocat")), "Case %d", i)
+
+		if currCase.wantDiff != "" {
+			err := analyzer.Fix(context.Background(), def, testInfo("octocat"), &bytes.Buffer{})
+			require.NoError(t, err, "Case %d", i)
+			require.Len(t, server.trees, 1, "Case %d", i)
+			assert.Equal(t, []github.TreeEntry{
+				{Path: github.String(currCase.wantPath), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String(currCase.wantFile)},
+			}, server.trees[0].Entries, "Case %d", i)
+			require.Len(t, server.pulls, 1, "Case %d", i)
+			assert.Equal(t, fmt.Sprintf("Update %s", currCase.wantPath), *server.pulls[0].Title, "Case %d", i)
+		}
+		server.Close()
+	}
+}
+
+func TestCodeOwnersAnalyzerReadsFileOnce(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	requests := make(map[string]int)
+	server.mux.HandleFunc("/repos/octocat/Hello-World/contents/", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	def := repository.Definition{
+		FullName:   "octocat/Hello-World",
+		Owners:     []string{"alice"},
+		CodeOwners: &repository.CodeOwners{},
+	}
+	analyzer := spec.NewCodeOwnersAnalyzer(server.client())
+	repoPlan := spec.NewRepositoryPlan(context.Background(), def, testInfo("octocat"), []spec.Analyzer{analyzer})
+	require.Len(t, repoPlan.Changes, 1)
+	changes, err := repoPlan.Changes[0].FileChanges(context.Background(), analyzer.(spec.FileChangeAnalyzer), def, testInfo("octocat"))
+	require.NoError(t, err)
+	assert.Equal(t, []license.FileChange{{Path: ".github/CODEOWNERS", Content: "* @alice\n"}}, changes.Changes)
+
+	// the change was determined along with the differences, so each location was read once
+	assert.Equal(t, map[string]int{
+		"/repos/octocat/Hello-World/contents/.github/CODEOWNERS": 1,
+		"/repos/octocat/Hello-World/contents/CODEOWNERS":         1,
+		"/repos/octocat/Hello-World/contents/docs/CODEOWNERS":    1,
+	}, requests)
+}
+
+func TestCodeOwnersAnalyzerWithoutClient(t *testing.T) {
+	def := repository.Definition{
+		Owners:     []string{"alice"},
+		CodeOwners: &repository.CodeOwners{},
+	}
+	analyzer := spec.NewCodeOwnersAnalyzer(nil)
+	assert.Equal(t, "codeowners:\n\tunable to determine CODEOWNERS file: repository contents cannot be read without a GitHub client", analyzer.Diff(context.Background(), def, testInfo("octocat")))
+	assert.False(t, analyzer.CanFix())
+}
@@ -5,32 +5,53 @@
 package spec
 
 import (
+	"context"
+	"fmt"
 	"io"
 
+	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 
 	"github.com/nmiyake/ghcli/repository"
 )
 
-type descriptionAnalyzer struct{}
+type descriptionAnalyzer struct {
+	client *github.Client
+}
 
-func NewDescriptionAnalyzer() Analyzer {
-	return &descriptionAnalyzer{}
+func NewDescriptionAnalyzer(client *github.Client) Analyzer {
+	return &descriptionAnalyzer{
+		client: client,
+	}
 }
 
 func (d *descriptionAnalyzer) Name() string {
 	return "description"
 }
 
-func (d *descriptionAnalyzer) Diff(def repository.Definition, info repository.Info) string {
+func (d *descriptionAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	return 0
+}
+
+func (d *descriptionAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
 	return stringDiff(d.Name(), def.Description, orEmpty(info.Description))
 }
 
 func (d *descriptionAnalyzer) CanFix() bool {
-	return false
+	return d.client != nil
 }
 
-// TODO(nmiyake): fix by using API to change description
-func (d *descriptionAnalyzer) Fix(def repository.Definition, info repository.Info, stdout io.Writer) error {
-	return errors.Errorf("not implemented")
+// Fix sets the description of the repository to the description in the definition. The description is updated directly
+// using the repository edit API rather than by opening a PR.
+func (d *descriptionAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := repository.Do(ctx, d.client, "PATCH", fmt.Sprintf("repos/%v/%v", *info.Owner.Login, *info.Name), &github.Repository{
+		Name:        info.Name,
+		Description: github.String(def.Description),
+	}, nil); err != nil {
+		return errors.Wrapf(err, "failed to update description for %s", *info.FullName)
+	}
+	return nil
 }
@@ -0,0 +1,65 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestDescriptionAnalyzerFix(t *testing.T) {
+	called := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Equal(t, "PATCH", r.Method)
+		assert.Equal(t, "/repos/octocat/Hello-World", r.URL.String())
+
+		var got map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		assert.Equal(t, "Hello-World", got["name"])
+		assert.Equal(t, "New description", got["description"])
+
+		json, err := json.Marshal(github.Repository{
+			Name:        github.String("Hello-World"),
+			Description: github.String("New description"),
+		})
+		require.NoError(t, err)
+		_, err = w.Write(json)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	def := repository.Definition{
+		FullName:    "octocat/Hello-World",
+		Description: "New description",
+	}
+	info := testInfo("octocat")
+	info.Description = github.String("Old description")
+
+	analyzer := spec.NewDescriptionAnalyzer(client)
+	assert.Equal(t, "description:\n\twant: New description\n\tgot:  Old description", analyzer.Diff(context.Background(), def, info))
+	require.True(t, analyzer.CanFix())
+	require.NoError(t, analyzer.Fix(context.Background(), def, info, &bytes.Buffer{}))
+	assert.True(t, called)
+}
+
+func TestDescriptionAnalyzerCannotFixWithoutClient(t *testing.T) {
+	assert.False(t, spec.NewDescriptionAnalyzer(nil).CanFix())
+}
@@ -0,0 +1,228 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+type filesAnalyzer struct {
+	client         *github.Client
+	templatesDir   string
+	templatesMutex sync.Mutex
+	templates      map[string]*template.Template
+}
+
+// NewFilesAnalyzer returns an analyzer that verifies that the files in a repository satisfy the file requirements of
+// its definition. Relative template paths are resolved against templatesDir. Fix opens a single PR that creates,
+// updates and removes all of the non-conforming files.
+func NewFilesAnalyzer(client *github.Client, templatesDir string) Analyzer {
+	return &filesAnalyzer{
+		client:       client,
+		templatesDir: templatesDir,
+		templates:    make(map[string]*template.Template),
+	}
+}
+
+func (d *filesAnalyzer) Name() string {
+	return "files"
+}
+
+func (d *filesAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	return 0
+}
+
+// fileState is the state of a required file in a repository.
+type fileState struct {
+	file    repository.File
+	exists  bool
+	content string // current content of the file
+	want    string // rendered content of the template (empty if file has no template)
+	err     error  // error encountered while determining the state
+}
+
+// conforms returns true if the file satisfies its requirement.
+func (s fileState) conforms() bool {
+	if s.file.Forbidden {
+		return !s.exists
+	}
+	return s.exists && (s.file.Template == "" || s.content == s.want)
+}
+
+func (d *filesAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	diff, _, _ := d.diffAndFileChanges(ctx, def, info)
+	return diff
+}
+
+func (d *filesAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix opens a single PR that creates missing files and updates files that do not match their templates using the
+// rendered templates and that removes forbidden files. Missing files without a template cannot be created: if any
+// exist, the PR is still opened for the other files, but an error is returned.
+func (d *filesAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, fileErr := d.FileChanges(ctx, def, info)
+	if err := applyFileChanges(ctx, d.client, info, changes, stdout); err != nil {
+		return errors.Wrapf(err, "failed to fix files")
+	}
+	return fileErr
+}
+
+// FileChanges returns the changes that create, update and remove the files that do not conform to the definition. If
+// some of the required files cannot be created because they do not have a template, the changes for the other files
+// are returned along with an error.
+func (d *filesAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error) {
+	_, changes, err := d.diffAndFileChanges(ctx, def, info)
+	return changes, err
+}
+
+// diffAndFileChanges returns the differences between the files of the repository and the definition along with the
+// changes that fix them. The content of each file is retrieved once for both.
+func (d *filesAnalyzer) diffAndFileChanges(ctx context.Context, def repository.Definition, info repository.Info) (string, FileChanges, error) {
+	if len(def.Files) == 0 || info.IsEmpty {
+		return "", FileChanges{}, nil
+	}
+	if d.client == nil {
+		return joinDiff(d.Name(), "unable to determine files: repository contents cannot be read without a GitHub client"), FileChanges{}, errors.Errorf("cannot determine files without a GitHub client")
+	}
+	states := d.states(ctx, def, info)
+	changes, err := d.fileChanges(states)
+	return d.diff(states), changes, err
+}
+
+// Returns the differences described by the provided states.
+func (d *filesAnalyzer) diff(states []fileState) string {
+	var parts []string
+	for _, state := range states {
+		switch {
+		case state.err != nil:
+			parts = append(parts, fmt.Sprintf("%s: %v", state.file.Path, state.err))
+		case state.conforms():
+			continue
+		case state.file.Forbidden:
+			parts = append(parts, fmt.Sprintf("%s: forbidden file exists", state.file.Path))
+		case !state.exists:
+			parts = append(parts, fmt.Sprintf("%s: missing", state.file.Path))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: content does not match template %s:", state.file.Path, state.file.Template))
+			for _, line := range strings.Split(contentDiff(state.want, state.content), "\n") {
+				parts = append(parts, "\t"+line)
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+// Returns the changes that make the files with the provided states conform to their requirements.
+func (d *filesAnalyzer) fileChanges(states []fileState) (FileChanges, error) {
+	var changes []license.FileChange
+	var summary []string
+	var unfixable []string
+	for _, state := range states {
+		switch {
+		case state.err != nil:
+			return FileChanges{}, errors.Wrapf(state.err, "failed to determine state of %s", state.file.Path)
+		case state.conforms():
+			continue
+		case state.file.Forbidden:
+			changes = append(changes, license.FileChange{Path: state.file.Path, Delete: true})
+			summary = append(summary, "* Remove "+state.file.Path)
+		case state.file.Template == "":
+			unfixable = append(unfixable, state.file.Path)
+		case !state.exists:
+			changes = append(changes, license.FileChange{Path: state.file.Path, Content: state.want})
+			summary = append(summary, "* Add "+state.file.Path)
+		default:
+			changes = append(changes, license.FileChange{Path: state.file.Path, Content: state.want})
+			summary = append(summary, "* Update "+state.file.Path)
+		}
+	}
+
+	var fileChanges FileChanges
+	if len(changes) > 0 {
+		fileChanges = FileChanges{
+			Changes: changes,
+			PRParams: license.PRParams{
+				Branch: "cli-update-files",
+				Title:  "Update required files",
+				Body:   "Update files in repository to match specification:\n" + strings.Join(summary, "\n"),
+			},
+		}
+	}
+	if len(unfixable) > 0 {
+		return fileChanges, errors.Errorf("cannot create required files without a template: %v", unfixable)
+	}
+	return fileChanges, nil
+}
+
+// Returns the state of every file in the definition.
+func (d *filesAnalyzer) states(ctx context.Context, def repository.Definition, info repository.Info) []fileState {
+	states := make([]fileState, len(def.Files))
+	for i, file := range def.Files {
+		states[i].file = file
+		if file.Template != "" {
+			states[i].want, states[i].err = d.render(file.Template, def)
+			if states[i].err != nil {
+				continue
+			}
+		}
+		states[i].content, states[i].exists, states[i].err = repository.GetFileContent(ctx, d.client, &info.Repository, file.Path)
+	}
+	return states
+}
+
+// Returns the content of the template at the provided path rendered using the provided definition.
+func (d *filesAnalyzer) render(templatePath string, def repository.Definition) (string, error) {
+	if !filepath.IsAbs(templatePath) {
+		templatePath = filepath.Join(d.templatesDir, templatePath)
+	}
+	tmpl, err := d.template(templatePath)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, repository.NewFileTemplateData(def)); err != nil {
+		return "", errors.Wrapf(err, "failed to render template %s", templatePath)
+	}
+	return buf.String(), nil
+}
+
+// Returns the parsed template at the provided path. Templates are parsed once and cached.
+func (d *filesAnalyzer) template(templatePath string) (*template.Template, error) {
+	d.templatesMutex.Lock()
+	defer d.templatesMutex.Unlock()
+
+	tmpl, ok := d.templates[templatePath]
+	if !ok {
+		bytes, err := ioutil.ReadFile(templatePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read template")
+		}
+		tmpl, err = template.New(templatePath).Parse(string(bytes))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse template %s", templatePath)
+		}
+		d.templates[templatePath] = tmpl
+	}
+	return tmpl, nil
+}
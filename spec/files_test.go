@@ -0,0 +1,150 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+const codeOwnersTemplate = "* {{range $i, $owner := .Owners}}{{if $i}} {{end}}@{{$owner}}{{end}}\n"
+
+func TestFilesAnalyzer(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "CODEOWNERS.tmpl"), []byte(codeOwnersTemplate), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "README.md.tmpl"), []byte("# {{.Name}}\n{{.Description}}\n"), 0644))
+
+	server := newPRServer(t)
+	defer server.Close()
+	files := map[string]string{
+		"README.md":          "# Hello-World\nOld description\n",
+		".github/CODEOWNERS": "* @alice @bob\n",
+		"PATENTS.txt":        "Patents\n",
+	}
+	server.mux.HandleFunc("/repos/octocat/Hello-World/contents/", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[strings.TrimPrefix(r.URL.Path, "/repos/octocat/Hello-World/contents/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		testutil.WriteJSON(t, w, github.RepositoryContent{
+			Type:     github.String("file"),
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+		})
+	})
+
+	def := repository.Definition{
+		FullName:    "octocat/Hello-World",
+		Description: "Example repository",
+		Owners:      []string{"alice", "bob"},
+		Files: []repository.File{
+			{Path: ".github/CODEOWNERS", Template: "CODEOWNERS.tmpl"},
+			{Path: "README.md", Template: "README.md.tmpl"},
+			{Path: "SECURITY.md", Template: filepath.Join(tmpDir, "README.md.tmpl")},
+			{Path: "PATENTS.txt", Forbidden: true},
+			{Path: "CONTRIBUTING.md"},
+		},
+	}
+	analyzer := spec.NewFilesAnalyzer(server.client(), tmpDir)
+
+	assert.Equal(t, "files:\n"+
+		"\tREADME.md: content does not match template README.md.tmpl:\n"+
+		"\t\t--- Expected\n"+
+		"\t\t+++ Actual\n"+
+		"\t\t@@ -2 +2 @@\n"+
+		"\t\t-Example repository\n"+
+		"\t\t+Old description\n"+
+		"\tSECURITY.md: missing\n"+
+		"\tPATENTS.txt: forbidden file exists\n"+
+		"\tCONTRIBUTING.md: missing", analyzer.Diff(context.Background(), def, testInfo("octocat")))
+
+	err = analyzer.Fix(context.Background(), def, testInfo("octocat"), &bytes.Buffer{})
+	assert.EqualError(t, err, "cannot create required files without a template: [CONTRIBUTING.md]")
+
+	// root directory is rebuilt without the forbidden file and the other changes are applied on top of it
+	require.Len(t, server.trees, 2)
+	assert.Equal(t, "", server.trees[0].BaseTree)
+	assert.Equal(t, []github.TreeEntry{
+		{Path: github.String("README.md"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("readme-sha")},
+		{Path: github.String("docs"), Mode: github.String("040000"), Type: github.String("tree"), SHA: github.String("docs-sha")},
+		{Path: github.String("src"), Mode: github.String("040000"), Type: github.String("tree"), SHA: github.String("src-sha")},
+	}, server.trees[0].Entries)
+	assert.Equal(t, "new-tree-sha-1", server.trees[1].BaseTree)
+	assert.Equal(t, []github.TreeEntry{
+		{Path: github.String("README.md"), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String("# Hello-World\nExample repository\n")},
+		{Path: github.String("SECURITY.md"), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String("# Hello-World\nExample repository\n")},
+	}, server.trees[1].Entries)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "Update files in repository to match specification:\n* Update README.md\n* Add SECURITY.md\n* Remove PATENTS.txt", *server.pulls[0].Body)
+}
+
+func TestFilesAnalyzerReadsFilesOnce(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "SECURITY.md.tmpl"), []byte("# {{.Name}}\n"), 0644))
+
+	server := newPRServer(t)
+	defer server.Close()
+	requests := make(map[string]int)
+	server.mux.HandleFunc("/repos/octocat/Hello-World/contents/", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	def := repository.Definition{
+		FullName: "octocat/Hello-World",
+		Files: []repository.File{
+			{Path: "SECURITY.md", Template: "SECURITY.md.tmpl"},
+			{Path: "CONTRIBUTING.md"},
+		},
+	}
+	analyzer := spec.NewFilesAnalyzer(server.client(), tmpDir)
+	repoPlan := spec.NewRepositoryPlan(context.Background(), def, testInfo("octocat"), []spec.Analyzer{analyzer})
+	require.Len(t, repoPlan.Changes, 1)
+	changes, err := repoPlan.Changes[0].FileChanges(context.Background(), analyzer.(spec.FileChangeAnalyzer), def, testInfo("octocat"))
+	assert.EqualError(t, err, "cannot create required files without a template: [CONTRIBUTING.md]")
+	assert.Equal(t, []license.FileChange{{Path: "SECURITY.md", Content: "# Hello-World\n"}}, changes.Changes)
+
+	// the changes were determined along with the differences, so each file was read once
+	assert.Equal(t, map[string]int{
+		"/repos/octocat/Hello-World/contents/SECURITY.md":     1,
+		"/repos/octocat/Hello-World/contents/CONTRIBUTING.md": 1,
+	}, requests)
+}
+
+func TestFilesAnalyzerWithoutClient(t *testing.T) {
+	def := repository.Definition{
+		Files: []repository.File{
+			{Path: "CONTRIBUTING.md"},
+		},
+	}
+	analyzer := spec.NewFilesAnalyzer(nil, "")
+	assert.Equal(t, "files:\n\tunable to determine files: repository contents cannot be read without a GitHub client", analyzer.Diff(context.Background(), def, testInfo("octocat")))
+	assert.False(t, analyzer.CanFix())
+}
@@ -0,0 +1,160 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+type headersAnalyzer struct {
+	client     *github.Client
+	authorName string
+	templates  license.HeaderTemplates
+}
+
+// NewHeadersAnalyzer returns an analyzer that verifies that the supported source files on the default branch of
+// repositories whose definition sets license_headers start with the license header for the license of the repository.
+// The files are read using the Git trees API. templates take precedence over the default header templates. Fix opens a
+// single PR that fixes the headers of all of the files in the repository.
+func NewHeadersAnalyzer(client *github.Client, authorName string, templates license.HeaderTemplates) Analyzer {
+	return &headersAnalyzer{
+		client:     client,
+		authorName: authorName,
+		templates:  templates,
+	}
+}
+
+func (d *headersAnalyzer) Name() string {
+	return "license headers"
+}
+
+func (d *headersAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	return 0
+}
+
+func (d *headersAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	diff, _, _ := d.diffAndFileChanges(ctx, def, info)
+	return diff
+}
+
+func (d *headersAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix opens a PR that adds or replaces the license headers of all of the files with missing or incorrect headers.
+func (d *headersAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, err := d.FileChanges(ctx, def, info)
+	if err != nil {
+		return err
+	}
+	if err := applyFileChanges(ctx, d.client, info, changes, stdout); err != nil {
+		return errors.Wrapf(err, "failed to fix license headers")
+	}
+	return nil
+}
+
+// FileChanges returns the changes that add or replace the license headers of the files with missing or incorrect
+// headers.
+func (d *headersAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error) {
+	_, changes, err := d.diffAndFileChanges(ctx, def, info)
+	return changes, err
+}
+
+// diffAndFileChanges returns the files with missing or incorrect headers as a difference along with the changes that
+// fix them. The tree and the content of the files are retrieved once for both.
+func (d *headersAnalyzer) diffAndFileChanges(ctx context.Context, def repository.Definition, info repository.Info) (string, FileChanges, error) {
+	if !def.LicenseHeaders || info.IsEmpty {
+		return "", FileChanges{}, nil
+	}
+	if d.client == nil {
+		return joinDiff(d.Name(), "unable to determine license headers: repository contents cannot be read without a GitHub client"), FileChanges{}, errors.Errorf("cannot determine license headers without a GitHub client")
+	}
+	changes, err := d.headerChanges(ctx, def, info)
+	if err != nil {
+		return joinDiff(d.Name(), err.Error()), FileChanges{}, err
+	}
+	if len(changes) == 0 {
+		return "", FileChanges{}, nil
+	}
+	var parts []string
+	var body []string
+	for _, change := range changes {
+		parts = append(parts, fmt.Sprintf("%s: missing or incorrect header", change.Path))
+		body = append(body, "* "+change.Path)
+	}
+	prParams := license.PRParams{
+		Branch: "cli-update-license-headers",
+		Title:  "Update license headers",
+		Body:   "Update license headers of files to match specification:\n" + strings.Join(body, "\n"),
+	}
+	return joinDiff(d.Name(), parts...), FileChanges{
+		Changes:  changes,
+		PRParams: prParams,
+	}, nil
+}
+
+// Returns the changes required to fix the headers of the files on the default branch of the repository in the order in
+// which the files appear in the tree.
+func (d *headersAnalyzer) headerChanges(ctx context.Context, def repository.Definition, info repository.Info) ([]license.FileChange, error) {
+	licenseType := strings.TrimPrefix(def.License, "custom-")
+	if licenseType == "" || licenseType == "custom" {
+		return nil, errors.Errorf("cannot verify license headers because the license type is not known")
+	}
+	headers, err := license.NewHeaders(licenseType, d.templates, d.authorName, time.Now().Year())
+	if err != nil {
+		return nil, err
+	}
+	if info.DefaultBranch == nil {
+		return nil, errors.Errorf("default branch of %s is not known", *info.FullName)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := repository.GetTree(ctx, d.client, &info.Repository, *info.DefaultBranch, true)
+	if err != nil {
+		return nil, err
+	}
+	var changes []license.FileChange
+	for _, entry := range entries {
+		if *entry.Type != "blob" || license.IsHeaderExcluded(*entry.Path) || !headers.Supports(*entry.Path) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var blob github.Blob
+		if _, err := repository.Do(ctx, d.client, "GET", fmt.Sprintf("repos/%v/%v/git/blobs/%v", *info.Owner.Login, *info.Name, *entry.SHA), nil, &blob); err != nil {
+			return nil, errors.Wrapf(err, "failed to get content of %s", *entry.Path)
+		}
+		content, err := base64.StdEncoding.DecodeString(*blob.Content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode content of %s", *entry.Path)
+		}
+		fixed, changed, err := headers.Fix(*entry.Path, string(content))
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, license.FileChange{
+				Path:    *entry.Path,
+				Content: fixed,
+				Mode:    *entry.Mode,
+			})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+const mitHeader = "// Copyright 2016 Jane Doe. All rights reserved.\n// Licensed under the MIT License. See LICENSE in the project root\n// for license information.\n"
+
+func TestHeadersAnalyzer(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	blobs := map[string]string{
+		"good-sha":   mitHeader + "\npackage main\n",
+		"bad-sha":    "package pkg\n",
+		"script-sha": "#!/bin/sh\necho hello\n",
+	}
+	server.mux.HandleFunc("/repos/octocat/Hello-World/git/trees/master", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("recursive"))
+		testutil.WriteJSON(t, w, github.Tree{
+			Entries: []github.TreeEntry{
+				{Path: github.String("README.md"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("readme-sha")},
+				{Path: github.String("main.go"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("good-sha")},
+				{Path: github.String("pkg"), Mode: github.String("040000"), Type: github.String("tree"), SHA: github.String("pkg-sha")},
+				{Path: github.String("pkg/pkg.go"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("bad-sha")},
+				{Path: github.String("scripts/build.sh"), Mode: github.String("100755"), Type: github.String("blob"), SHA: github.String("script-sha")},
+				{Path: github.String("vendor/dep/dep.go"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("bad-sha")},
+			},
+		})
+	})
+	server.mux.HandleFunc("/repos/octocat/Hello-World/git/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := blobs[strings.TrimPrefix(r.URL.Path, "/repos/octocat/Hello-World/git/blobs/")]
+		require.True(t, ok, "unexpected request for %s", r.URL.Path)
+		testutil.WriteJSON(t, w, github.Blob{
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+		})
+	})
+
+	def := repository.Definition{
+		FullName:       "octocat/Hello-World",
+		License:        "mit",
+		LicenseHeaders: true,
+	}
+	analyzer := spec.NewHeadersAnalyzer(server.client(), "Jane Doe", nil)
+	assert.Equal(t, "license headers:\n"+
+		"\tpkg/pkg.go: missing or incorrect header\n"+
+		"\tscripts/build.sh: missing or incorrect header", analyzer.Diff(context.Background(), def, testInfo("octocat")))
+	assert.Equal(t, "", analyzer.Diff(context.Background(), repository.Definition{License: "mit"}, testInfo("octocat")))
+
+	require.NoError(t, analyzer.Fix(context.Background(), def, testInfo("octocat"), &bytes.Buffer{}))
+	year := time.Now().Year()
+	require.Len(t, server.trees, 1)
+	assert.Equal(t, []github.TreeEntry{
+		{Path: github.String("pkg/pkg.go"), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String(fmt.Sprintf("// Copyright %d Jane Doe. All rights reserved.\n// Licensed under the MIT License. See LICENSE in the project root\n// for license information.\n\npackage pkg\n", year))},
+		{Path: github.String("scripts/build.sh"), Mode: github.String("100755"), Type: github.String("blob"), Content: github.String(fmt.Sprintf("#!/bin/sh\n# Copyright %d Jane Doe. All rights reserved.\n# Licensed under the MIT License. See LICENSE in the project root\n# for license information.\n\necho hello\n", year))},
+	}, server.trees[0].Entries)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "Update license headers", *server.pulls[0].Title)
+	assert.Equal(t, "Update license headers of files to match specification:\n* pkg/pkg.go\n* scripts/build.sh", *server.pulls[0].Body)
+}
+
+func TestHeadersAnalyzerReadsTreeOnce(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	requests := make(map[string]int)
+	server.mux.HandleFunc("/repos/octocat/Hello-World/git/trees/master", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		testutil.WriteJSON(t, w, github.Tree{
+			Entries: []github.TreeEntry{
+				{Path: github.String("main.go"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("bad-sha")},
+			},
+		})
+	})
+	server.mux.HandleFunc("/repos/octocat/Hello-World/git/blobs/bad-sha", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		testutil.WriteJSON(t, w, github.Blob{
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte("package main\n"))),
+		})
+	})
+
+	def := repository.Definition{
+		FullName:       "octocat/Hello-World",
+		License:        "mit",
+		LicenseHeaders: true,
+	}
+	analyzer := spec.NewHeadersAnalyzer(server.client(), "Jane Doe", nil)
+	repoPlan := spec.NewRepositoryPlan(context.Background(), def, testInfo("octocat"), []spec.Analyzer{analyzer})
+	require.Len(t, repoPlan.Changes, 1)
+	assert.Equal(t, "license headers:\n\tmain.go: missing or incorrect header", repoPlan.Changes[0].Diff)
+	changes, err := repoPlan.Changes[0].FileChanges(context.Background(), analyzer.(spec.FileChangeAnalyzer), def, testInfo("octocat"))
+	require.NoError(t, err)
+	require.Len(t, changes.Changes, 1)
+	assert.Equal(t, "main.go", changes.Changes[0].Path)
+
+	// the changes were determined along with the differences, so the tree and the file were read once
+	assert.Equal(t, map[string]int{
+		"/repos/octocat/Hello-World/git/trees/master":  1,
+		"/repos/octocat/Hello-World/git/blobs/bad-sha": 1,
+	}, requests)
+}
+
+func TestHeadersAnalyzerTruncatedTree(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	server.mux.HandleFunc("/repos/octocat/Hello-World/git/trees/master", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, listTreeResponse{
+			Entries: []github.TreeEntry{
+				{Path: github.String("main.go"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("good-sha")},
+			},
+			Truncated: true,
+		})
+	})
+
+	def := repository.Definition{
+		FullName:       "octocat/Hello-World",
+		License:        "mit",
+		LicenseHeaders: true,
+	}
+	analyzer := spec.NewHeadersAnalyzer(server.client(), "Jane Doe", nil)
+	assert.Equal(t, "license headers:\n\ttree master of octocat/Hello-World has more entries than the GitHub API returns", analyzer.Diff(context.Background(), def, testInfo("octocat")))
+	_, err := analyzer.(spec.FileChangeAnalyzer).FileChanges(context.Background(), def, testInfo("octocat"))
+	assert.EqualError(t, err, "tree master of octocat/Hello-World has more entries than the GitHub API returns")
+	assert.Empty(t, server.trees)
+}
+
+func TestHeadersAnalyzerWithoutClient(t *testing.T) {
+	def := repository.Definition{
+		License:        "mit",
+		LicenseHeaders: true,
+	}
+	analyzer := spec.NewHeadersAnalyzer(nil, "Jane Doe", nil)
+	assert.Equal(t, "license headers:\n\tunable to determine license headers: repository contents cannot be read without a GitHub client", analyzer.Diff(context.Background(), def, testInfo("octocat")))
+	assert.False(t, analyzer.CanFix())
+}
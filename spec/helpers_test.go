@@ -0,0 +1,214 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+// testInfo returns the Info of the repository "Hello-World" of the provided owner, which has the default branch "master"
+// and can be pushed to. Tests set the sections of the Info that they use.
+func testInfo(owner string) repository.Info {
+	return repository.Info{
+		Repository: github.Repository{
+			ID: github.Int(1),
+			Owner: &github.User{
+				Login: github.String(owner),
+			},
+			Name:          github.String("Hello-World"),
+			FullName:      github.String(owner + "/Hello-World"),
+			DefaultBranch: github.String("master"),
+			Permissions:   &map[string]bool{"push": true},
+		},
+	}
+}
+
+type createTreeRequest struct {
+	BaseTree string             `json:"base_tree"`
+	Entries  []github.TreeEntry `json:"tree"`
+}
+
+// listTreeResponse is the response of the API that lists the entries of a tree.
+type listTreeResponse struct {
+	Entries   []github.TreeEntry `json:"tree"`
+	Truncated bool               `json:"truncated"`
+}
+
+type updateRefRequest struct {
+	SHA   string `json:"sha"`
+	Force bool   `json:"force"`
+}
+
+// prServer is a stand-in for the GitHub API that supports the calls made when opening a PR that modifies files.
+// branches and openPulls are the existing branches and open PRs of the repository (the commit of every existing branch
+// has the tree "branch-tree-sha"). If truncated is true, the listing of the root directory of the repository is
+// truncated.
+type prServer struct {
+	*httptest.Server
+	mux        *http.ServeMux
+	branches   []string
+	openPulls  []github.PullRequest
+	truncated  bool
+	trees      []createTreeRequest
+	refs       []github.Reference
+	refUpdates []updateRefRequest
+	pulls      []github.NewPullRequest
+	pullEdits  []github.PullRequest
+}
+
+func newPRServer(t *testing.T) *prServer {
+	mux := http.NewServeMux()
+	s := &prServer{
+		mux: mux,
+	}
+	mux.HandleFunc("/repos/octocat/Hello-World/branches/master", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Branch{
+			Name: github.String("master"),
+			Commit: &github.Commit{
+				SHA: github.String("master-sha"),
+			},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/commits/master-sha", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Commit{
+			SHA: github.String("master-sha"),
+			Tree: &github.Tree{
+				SHA: github.String("base-tree-sha"),
+			},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/commits/branch-sha", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Commit{
+			SHA: github.String("branch-sha"),
+			Tree: &github.Tree{
+				SHA: github.String("branch-tree-sha"),
+			},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/trees/base-tree-sha", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("recursive") != "" {
+			// the recursive listing of a large repository is truncated, so it must not be used to rebuild trees
+			testutil.WriteJSON(t, w, listTreeResponse{
+				Entries: []github.TreeEntry{
+					{Path: github.String("PATENTS.txt"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("patents-sha")},
+				},
+				Truncated: true,
+			})
+			return
+		}
+		testutil.WriteJSON(t, w, listTreeResponse{
+			Entries: []github.TreeEntry{
+				{Path: github.String("PATENTS.txt"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("patents-sha")},
+				{Path: github.String("README.md"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("readme-sha")},
+				{Path: github.String("docs"), Mode: github.String("040000"), Type: github.String("tree"), SHA: github.String("docs-sha")},
+				{Path: github.String("src"), Mode: github.String("040000"), Type: github.String("tree"), SHA: github.String("src-sha")},
+			},
+			Truncated: s.truncated,
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/trees/docs-sha", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, listTreeResponse{
+			Entries: []github.TreeEntry{
+				{Path: github.String("PATENTS"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("docs-patents-sha")},
+				{Path: github.String("index.md"), Mode: github.String("100644"), Type: github.String("blob"), SHA: github.String("index-sha")},
+			},
+		})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		var req createTreeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		s.trees = append(s.trees, req)
+		testutil.WriteJSON(t, w, github.Tree{SHA: github.String(fmt.Sprintf("new-tree-sha-%d", len(s.trees)))})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.Commit{SHA: github.String("new-commit-sha")})
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		var req github.Reference
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		s.refs = append(s.refs, req)
+		testutil.WriteJSON(t, w, req)
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/git/refs/heads/", func(w http.ResponseWriter, r *http.Request) {
+		branch := strings.TrimPrefix(r.URL.Path, "/repos/octocat/Hello-World/git/refs/heads/")
+		if r.Method == "PATCH" {
+			var req updateRefRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			s.refUpdates = append(s.refUpdates, req)
+			testutil.WriteJSON(t, w, github.Reference{Ref: github.String("refs/heads/" + branch)})
+			return
+		}
+		for _, existing := range s.branches {
+			if existing == branch {
+				testutil.WriteJSON(t, w, github.Reference{
+					Ref:    github.String("refs/heads/" + branch),
+					Object: &github.GitObject{SHA: github.String("branch-sha")},
+				})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/pulls/", func(w http.ResponseWriter, r *http.Request) {
+		var req github.PullRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		s.pullEdits = append(s.pullEdits, req)
+		testutil.WriteJSON(t, w, req)
+	})
+	mux.HandleFunc("/repos/octocat/Hello-World/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			assert.Equal(t, "open", r.URL.Query().Get("state"))
+			testutil.WriteJSON(t, w, s.openPulls)
+			return
+		}
+		var req github.NewPullRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		s.pulls = append(s.pulls, req)
+		testutil.WriteJSON(t, w, github.PullRequest{Number: github.Int(1)})
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *prServer) client() *github.Client {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return client
+}
+
+// dryRunClient returns a dry run client for the server (see repository.NewDryRunClient).
+func (s *prServer) dryRunClient() *github.Client {
+	client := repository.NewDryRunClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return client
+}
+
+// applyDryRun applies the file changes of the provided analyzer as a dry run using a dry run client for the server.
+func (s *prServer) applyDryRun(analyzer spec.Analyzer, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, err := analyzer.(spec.FileChangeAnalyzer).FileChanges(context.Background(), def, info)
+	if err != nil {
+		return err
+	}
+	prParams := changes.PRParams
+	prParams.DryRun = true
+	return license.ApplyFileChanges(context.Background(), s.dryRunClient(), info, changes.Changes, prParams, stdout)
+}
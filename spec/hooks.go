@@ -0,0 +1,161 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+type hooksAnalyzer struct {
+	client *github.Client
+}
+
+// NewHooksAnalyzer returns an analyzer that verifies the webhooks of a repository. Hooks are matched by URL. Fix creates
+// missing hooks and updates misconfigured hooks, but does not delete hooks that are not in the definition. The secrets
+// of hooks are read from the environment variables named in the definition and are never printed, and the user
+// information and query of hook URLs are redacted in the differences.
+func NewHooksAnalyzer(client *github.Client) Analyzer {
+	return &hooksAnalyzer{
+		client: client,
+	}
+}
+
+func (d *hooksAnalyzer) Name() string {
+	return "hooks"
+}
+
+func (d *hooksAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	if def.Hooks == nil {
+		return 0
+	}
+	return repository.HooksSection
+}
+
+func (d *hooksAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if def.Hooks == nil {
+		return ""
+	}
+	if info.HooksUnknown {
+		return joinDiff(d.Name(), "unable to determine hooks: hooks could not be listed")
+	}
+	var parts []string
+	for _, want := range def.Hooks {
+		got := findHook(info.Hooks, want.URL)
+		if got == nil {
+			parts = append(parts, fmt.Sprintf("missing: %s", want.RedactedURL()))
+			continue
+		}
+		for _, diff := range hookDiffs(want, *got) {
+			parts = append(parts, fmt.Sprintf("%s: %s", want.RedactedURL(), diff))
+		}
+	}
+	for _, got := range info.Hooks {
+		if findHook(def.Hooks, got.URL) == nil {
+			parts = append(parts, fmt.Sprintf("unknown: %s", got.RedactedURL()))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+func (d *hooksAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix creates the missing hooks and updates the hooks whose configuration differs from the definition. Hooks that are
+// not in the definition are not modified.
+func (d *hooksAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	if def.Hooks == nil {
+		return nil
+	}
+	if info.HooksUnknown {
+		return errors.Errorf("hooks of %s cannot be determined", *info.FullName)
+	}
+	for _, want := range def.Hooks {
+		got := findHook(info.Hooks, want.URL)
+		if got != nil && len(hookDiffs(want, *got)) == 0 {
+			continue
+		}
+		var secret string
+		if want.SecretEnv != "" {
+			if secret = os.Getenv(want.SecretEnv); secret == "" {
+				return errors.Errorf("environment variable %s that contains the secret for hook %s is not set", want.SecretEnv, want.RedactedURL())
+			}
+		}
+		want.Events = hookEvents(want)
+		want.ContentType = hookContentType(want)
+		active := hookActive(want)
+		want.Active = &active
+
+		var err error
+		if got == nil {
+			err = repository.CreateHook(ctx, d.client, &info.Repository, want, secret)
+		} else {
+			err = repository.EditHook(ctx, d.client, &info.Repository, got.ID, want, secret)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to fix hooks")
+		}
+	}
+	return nil
+}
+
+// Returns a description of every setting that differs between want and got. The value of the secret is never
+// compared: a hook is only reported if want requires a secret and got does not have one.
+func hookDiffs(want, got repository.Hook) []string {
+	var diffs []string
+	if wantEvents, gotEvents := sortedCopy(hookEvents(want)), sortedCopy(got.Events); fmt.Sprint(wantEvents) != fmt.Sprint(gotEvents) {
+		diffs = append(diffs, fmt.Sprintf("events: want %v, got %v", wantEvents, gotEvents))
+	}
+	if wantType, gotType := hookContentType(want), hookContentType(got); wantType != gotType {
+		diffs = append(diffs, fmt.Sprintf("content_type: want %s, got %s", wantType, gotType))
+	}
+	if wantActive, gotActive := hookActive(want), hookActive(got); wantActive != gotActive {
+		diffs = append(diffs, fmt.Sprintf("active: want %v, got %v", wantActive, gotActive))
+	}
+	if want.SecretEnv != "" && !got.HasSecret {
+		diffs = append(diffs, "secret: missing")
+	}
+	return diffs
+}
+
+// The following functions return the values of a hook with the GitHub defaults applied.
+
+func hookEvents(hook repository.Hook) []string {
+	if len(hook.Events) == 0 {
+		return []string{"push"}
+	}
+	return hook.Events
+}
+
+func hookContentType(hook repository.Hook) string {
+	if hook.ContentType == "" {
+		return "form"
+	}
+	return hook.ContentType
+}
+
+func hookActive(hook repository.Hook) bool {
+	return hook.Active == nil || *hook.Active
+}
+
+func findHook(hooks []repository.Hook, url string) *repository.Hook {
+	for i := range hooks {
+		if hooks[i].URL == url {
+			return &hooks[i]
+		}
+	}
+	return nil
+}
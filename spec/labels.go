@@ -0,0 +1,171 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+type labelsAnalyzer struct {
+	client      *github.Client
+	labelSets   map[string][]repository.Label
+	deleteExtra bool
+}
+
+// NewLabelsAnalyzer returns an analyzer that verifies the issue labels of a repository. labelSets is a map from the name
+// of a shared label set to the labels in the set and is used to resolve the label set of definitions. Fix creates
+// missing labels and updates labels with the wrong color or description. Labels that are not in the definition are
+// reported but are only deleted by Fix if deleteExtra is true, since deleting a label removes it from all issues.
+func NewLabelsAnalyzer(client *github.Client, labelSets map[string][]repository.Label, deleteExtra bool) Analyzer {
+	return &labelsAnalyzer{
+		client:      client,
+		labelSets:   labelSets,
+		deleteExtra: deleteExtra,
+	}
+}
+
+func (d *labelsAnalyzer) Name() string {
+	return "labels"
+}
+
+func (d *labelsAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	if want, err := d.wantLabels(def); err == nil && want == nil {
+		return 0
+	}
+	return repository.LabelsSection
+}
+
+func (d *labelsAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	want, err := d.wantLabels(def)
+	if err != nil {
+		return joinDiff(d.Name(), err.Error())
+	}
+	if want == nil {
+		return ""
+	}
+	missing, changed, extra := labelDiffs(want, info.Labels)
+	var parts []string
+	for _, label := range missing {
+		parts = append(parts, fmt.Sprintf("missing: %s (color %s, description %q)", label.Name, label.Color, label.Description))
+	}
+	for _, label := range changed {
+		got := findLabel(info.Labels, label.Name)
+		if !strings.EqualFold(label.Color, got.Color) {
+			parts = append(parts, fmt.Sprintf("color: %s: want %s, got %s", label.Name, label.Color, got.Color))
+		}
+		if label.Description != got.Description {
+			parts = append(parts, fmt.Sprintf("description: %s: want %q, got %q", label.Name, label.Description, got.Description))
+		}
+	}
+	for _, label := range extra {
+		parts = append(parts, fmt.Sprintf("unexpected: %s", label.Name))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+func (d *labelsAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix creates the missing labels and updates the labels with the wrong color or description. Labels that are not in the
+// definition are deleted only if the analyzer was created with deleteExtra set to true.
+func (d *labelsAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	want, err := d.wantLabels(def)
+	if err != nil {
+		return err
+	}
+	if want == nil {
+		return nil
+	}
+	missing, changed, extra := labelDiffs(want, info.Labels)
+	for _, label := range missing {
+		if err := repository.CreateLabel(ctx, d.client, &info.Repository, label); err != nil {
+			return err
+		}
+	}
+	for _, label := range changed {
+		if err := repository.EditLabel(ctx, d.client, &info.Repository, findLabel(info.Labels, label.Name).Name, label); err != nil {
+			return err
+		}
+	}
+	if d.deleteExtra {
+		for _, label := range extra {
+			if err := repository.DeleteLabel(ctx, d.client, &info.Repository, label.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the labels required by the definition: the labels in its label set followed by its own labels, where its own
+// labels replace labels in the set with the same name. Returns nil if the definition does not specify labels.
+func (d *labelsAnalyzer) wantLabels(def repository.Definition) ([]repository.Label, error) {
+	if def.LabelSet == "" {
+		return def.Labels, nil
+	}
+	setLabels, ok := d.labelSets[def.LabelSet]
+	if !ok {
+		return nil, errors.Errorf("unknown label set %q", def.LabelSet)
+	}
+	labels := []repository.Label{}
+	for _, label := range setLabels {
+		if findLabel(def.Labels, label.Name) == nil {
+			labels = append(labels, label)
+		}
+	}
+	return append(labels, def.Labels...), nil
+}
+
+// Returns the wanted labels that do not exist, the wanted labels that exist but have a different color or description
+// and the existing labels that are not wanted. Label names are compared case-insensitively. The extra labels are sorted
+// by name.
+func labelDiffs(want, got []repository.Label) (missing, changed, extra []repository.Label) {
+	for _, label := range want {
+		gotLabel := findLabel(got, label.Name)
+		switch {
+		case gotLabel == nil:
+			missing = append(missing, label)
+		case !strings.EqualFold(label.Color, gotLabel.Color) || label.Description != gotLabel.Description:
+			changed = append(changed, label)
+		}
+	}
+	for _, label := range got {
+		if findLabel(want, label.Name) == nil {
+			extra = append(extra, label)
+		}
+	}
+	sort.Sort(labelsByName(extra))
+	return missing, changed, extra
+}
+
+type labelsByName []repository.Label
+
+func (p labelsByName) Len() int { return len(p) }
+func (p labelsByName) Less(i, j int) bool {
+	return strings.ToLower(p[i].Name) < strings.ToLower(p[j].Name)
+}
+func (p labelsByName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func findLabel(labels []repository.Label, name string) *repository.Label {
+	for i := range labels {
+		if strings.EqualFold(labels[i].Name, name) {
+			return &labels[i]
+		}
+	}
+	return nil
+}
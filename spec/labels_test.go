@@ -0,0 +1,129 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+var testLabelSets = map[string][]repository.Label{
+	"triage": {
+		{Name: "bug", Color: "d73a4a", Description: "Something isn't working"},
+		{Name: "enhancement", Color: "a2eeef", Description: "New feature or request"},
+	},
+}
+
+func TestLabelsAnalyzerDiff(t *testing.T) {
+	info := testInfo("octocat")
+	info.Labels = []repository.Label{
+		{Name: "bug", Color: "d73a4a", Description: "Something isn't working"},
+		{Name: "wontfix", Color: "ffffff"},
+	}
+	for i, currCase := range []struct {
+		def  repository.Definition
+		want string
+	}{
+		{
+			def:  repository.Definition{},
+			want: "",
+		},
+		{
+			def: repository.Definition{Labels: []repository.Label{
+				{Name: "BUG", Color: "D73A4A", Description: "Something isn't working"},
+				{Name: "wontfix", Color: "ffffff"},
+			}},
+			want: "",
+		},
+		{
+			def: repository.Definition{LabelSet: "triage"},
+			want: "labels:\n" +
+				"\tmissing: enhancement (color a2eeef, description \"New feature or request\")\n" +
+				"\tunexpected: wontfix",
+		},
+		{
+			def: repository.Definition{LabelSet: "triage", Labels: []repository.Label{
+				{Name: "bug", Color: "ff0000", Description: "Broken"},
+				{Name: "enhancement", Color: "a2eeef", Description: "New feature or request"},
+				{Name: "wontfix", Color: "ffffff"},
+			}},
+			want: "labels:\n" +
+				"\tmissing: enhancement (color a2eeef, description \"New feature or request\")\n" +
+				"\tcolor: bug: want ff0000, got d73a4a\n" +
+				"\tdescription: bug: want \"Broken\", got \"Something isn't working\"",
+		},
+		{
+			def:  repository.Definition{LabelSet: "unknown"},
+			want: "labels:\n\tunknown label set \"unknown\"",
+		},
+	} {
+		got := spec.NewLabelsAnalyzer(nil, testLabelSets, false).Diff(context.Background(), currCase.def, info)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestLabelsAnalyzerFix(t *testing.T) {
+	info := testInfo("octocat")
+	info.Labels = []repository.Label{
+		{Name: "bug", Color: "d73a4a", Description: "Something isn't working"},
+		{Name: "wontfix", Color: "ffffff"},
+	}
+	for i, currCase := range []struct {
+		deleteExtra bool
+		want        []string
+	}{
+		{
+			deleteExtra: false,
+			want: []string{
+				`PATCH /repos/octocat/Hello-World/labels/bug {"color":"ff0000","description":"Broken","name":"bug"}`,
+				`POST /repos/octocat/Hello-World/labels {"color":"a2eeef","description":"New feature or request","name":"enhancement"}`,
+			},
+		},
+		{
+			deleteExtra: true,
+			want: []string{
+				`DELETE /repos/octocat/Hello-World/labels/wontfix null`,
+				`PATCH /repos/octocat/Hello-World/labels/bug {"color":"ff0000","description":"Broken","name":"bug"}`,
+				`POST /repos/octocat/Hello-World/labels {"color":"a2eeef","description":"New feature or request","name":"enhancement"}`,
+			},
+		},
+	} {
+		var got []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			bodyBytes, err := json.Marshal(body)
+			require.NoError(t, err)
+			got = append(got, r.Method+" "+r.URL.String()+" "+string(bodyBytes))
+			_, err = w.Write([]byte("{}"))
+			require.NoError(t, err)
+		}))
+
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+		def := repository.Definition{LabelSet: "triage", Labels: []repository.Label{
+			{Name: "bug", Color: "ff0000", Description: "Broken"},
+		}}
+		require.NoError(t, spec.NewLabelsAnalyzer(client, testLabelSets, currCase.deleteExtra).Fix(context.Background(), def, info, &bytes.Buffer{}), "Case %d", i)
+
+		sort.Strings(got)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+		ts.Close()
+	}
+}
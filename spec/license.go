@@ -5,6 +5,7 @@
 package spec
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -34,7 +35,11 @@ func (d *licenseAnalyzer) Name() string {
 	return "license"
 }
 
-func (d *licenseAnalyzer) Diff(def repository.Definition, info repository.Info) string {
+func (d *licenseAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	return 0
+}
+
+func (d *licenseAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
 	if def.License == "custom" {
 		// custom license -- assume correct
 		return ""
@@ -51,7 +56,7 @@ func (d *licenseAnalyzer) Diff(def repository.Definition, info repository.Info)
 		// detected license type is different from specification
 		return stringDiff("license type", wantLicenseType, gotLicense)
 	}
-	if _, err := license.VerifyRepositoryLicenseCorrect(info.RepoLicense, &info.Repository, d.authorName, d.cache); license.IsIncorrect(err) {
+	if _, err := license.VerifyRepositoryLicenseCorrect(ctx, info.RepoLicense, &info.Repository, d.authorName, d.cache); license.IsIncorrect(err) {
 		// content of license differs from expectation
 		return joinDiff(fmt.Sprintf("%s content (%s)", *info.RepoLicense.Path, *info.RepoLicense.License.Name), strings.Split(license.Diff(err), "\n")...)
 	}
@@ -62,11 +67,36 @@ func (d *licenseAnalyzer) CanFix() bool {
 	return d.client != nil && d.cache != nil
 }
 
-func (d *licenseAnalyzer) Fix(def repository.Definition, info repository.Info, stdout io.Writer) error {
-	prParams := license.DefaultPRParams("")
-	prParams.Body = "Fix license for repository to match specification."
-	if err := license.ApplyStandard(d.client, info, strings.TrimPrefix(def.License, "custom-"), d.authorName, prParams, d.cache, stdout); err != nil {
+func (d *licenseAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, err := d.FileChanges(ctx, def, info)
+	if err != nil {
+		return err
+	}
+	if err := applyFileChanges(ctx, d.client, info, changes, stdout); err != nil {
 		return errors.Wrapf(err, "failed to fix license")
 	}
 	return nil
 }
+
+// FileChanges returns the change that replaces the content of the license file of the repository with the standard
+// license specified by the definition.
+func (d *licenseAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error) {
+	if info.RepoLicense == nil || info.RepoLicense.Path == nil {
+		return FileChanges{}, errors.Errorf("cannot fix license because the license file of %s is not known", *info.FullName)
+	}
+	content, err := license.Create(ctx, strings.TrimPrefix(def.License, "custom-"), d.cache, license.NewAuthorInfo(d.authorName, info.CreatedAt.Time.Year(), info.UpdatedAt.Time.Year()))
+	if err != nil {
+		return FileChanges{}, errors.Wrapf(err, "failed to fix license")
+	}
+	prParams := license.DefaultPRParams("")
+	prParams.Body = "Fix license for repository to match specification."
+	return FileChanges{
+		Changes: []license.FileChange{
+			{
+				Path:    *info.RepoLicense.Path,
+				Content: content,
+			},
+		},
+		PRParams: prParams,
+	}, nil
+}
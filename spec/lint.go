@@ -0,0 +1,418 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+var (
+	// loginRegexp matches valid GitHub logins: alphanumeric characters and single hyphens that neither start nor end
+	// the login.
+	loginRegexp = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+	// repoNameRegexp matches valid names of GitHub repositories (without the owner).
+	repoNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	// typeErrorRegexp matches the messages of the errors in a yaml.TypeError and the message of a syntax error.
+	typeErrorRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+)
+
+// maxLoginLength is the maximum length of a GitHub login.
+const maxLoginLength = 39
+
+// LintProblem is a problem in a specification file.
+type LintProblem struct {
+	File    string
+	Line    int // 1-based line of the problem in the file (0 if the line could not be determined)
+	Message string
+}
+
+func (p LintProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+type lintProblemsByLine []LintProblem
+
+func (p lintProblemsByLine) Len() int { return len(p) }
+func (p lintProblemsByLine) Less(i, j int) bool {
+	// problems without a line are listed last
+	return p[i].Line != 0 && (p[j].Line == 0 || p[i].Line < p[j].Line)
+}
+func (p lintProblemsByLine) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// specFileKeys are the valid top-level keys of a specification file in map form.
+var specFileKeys = map[string]struct{}{
+	"include":      {},
+	"defaults":     {},
+	"repositories": {},
+}
+
+// lintSpecFile is the typed form of a specification file in map form.
+type lintSpecFile struct {
+	Include      []string                `yaml:"include"`
+	Defaults     repository.Definition   `yaml:"defaults"`
+	Repositories []repository.Definition `yaml:"repositories"`
+}
+
+// Lint checks the specification files loaded for the provided path (as resolved by repository.ResolveSpecFiles) without
+// accessing GitHub. It reports unknown fields, fields with invalid types, definitions without names, duplicate names,
+// malformed repository names and patterns, unknown licenses and invalid owner logins. Problems are returned in the
+// order of the files and, within a file, in the order of their lines. Line numbers are determined on a best-effort
+// basis for files that do not use one key per line. Returns an error if the files cannot be read or parsed.
+func Lint(specPath string) ([]LintProblem, error) {
+	files, err := repository.ResolveSpecFiles(specPath)
+	if err != nil {
+		return nil, err
+	}
+	var problems []LintProblem
+	firstDefined := make(map[string]string) // map from definition name to location of its first definition
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", file)
+		}
+		fileProblems, err := lintFile(file, content, firstDefined)
+		if err != nil {
+			return nil, err
+		}
+		sort.Stable(lintProblemsByLine(fileProblems))
+		problems = append(problems, fileProblems...)
+	}
+	return problems, nil
+}
+
+func lintFile(file string, content []byte, firstDefined map[string]string) ([]LintProblem, error) {
+	var problems []LintProblem
+	addProblem := func(line int, format string, args ...interface{}) {
+		problems = append(problems, LintProblem{
+			File:    file,
+			Line:    line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	lines := lineFinder(strings.Split(string(content), "\n"))
+
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", file)
+	}
+	var defaults map[interface{}]interface{}
+	var defs []interface{}
+	var typeErr error
+	switch v := raw.(type) {
+	case nil:
+	case []interface{}:
+		defs = v
+		typeErr = yaml.Unmarshal(content, &[]repository.Definition{})
+	case map[interface{}]interface{}:
+		var keys []string
+		for k := range v {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if _, ok := specFileKeys[k]; !ok {
+				addProblem(lines.key(k, 0, len(lines)), "unknown field %q", k)
+			}
+		}
+		if d, ok := v["defaults"].(map[interface{}]interface{}); ok {
+			defaults = d
+		}
+		if r, ok := v["repositories"].([]interface{}); ok {
+			defs = r
+		}
+		typeErr = yaml.Unmarshal(content, &lintSpecFile{})
+	default:
+		addProblem(0, "specification must be a list of definitions or a map")
+	}
+	if typeErr != nil {
+		msgs := []string{typeErr.Error()}
+		if e, ok := typeErr.(*yaml.TypeError); ok {
+			msgs = e.Errors
+		}
+		for _, msg := range msgs {
+			line := 0
+			if match := typeErrorRegexp.FindStringSubmatch(msg); match != nil {
+				line, _ = strconv.Atoi(match[1])
+				msg = match[2]
+			}
+			addProblem(line, "%s", msg)
+		}
+	}
+
+	if defaults != nil {
+		start := lines.key("defaults", 0, len(lines)) - 1
+		end := lines.blockEnd(start)
+		if _, ok := defaults["name"]; ok {
+			addProblem(lines.key("name", start, end), "defaults cannot specify a name")
+		}
+		for _, problem := range lintDefinition(defaults, lines, start, end) {
+			addProblem(problem.line, "%s", problem.message)
+		}
+	}
+
+	// 0-based index of the line that contains the name of each definition (-1 if unknown)
+	nameLines := make([]int, len(defs))
+	prev := -1
+	for i, def := range defs {
+		nameLines[i] = -1
+		fields, _ := def.(map[interface{}]interface{})
+		if name, ok := fields["name"].(string); ok && name != "" {
+			if idx := lines.find(nameRegexp(name), prev+1, len(lines), false); idx != -1 {
+				nameLines[i] = idx
+				prev = idx
+			}
+		}
+	}
+	for i, def := range defs {
+		// keys of the definition are searched for after its name and then before its name
+		start, end := 0, len(lines)
+		if nameLines[i] != -1 {
+			start = nameLines[i]
+			for _, next := range nameLines[i+1:] {
+				if next != -1 {
+					end = next
+					break
+				}
+			}
+		}
+		fields, ok := def.(map[interface{}]interface{})
+		if !ok {
+			addProblem(start+1, "definition %d is not a map", i+1)
+			continue
+		}
+		name, _ := fields["name"].(string)
+		if name == "" {
+			addProblem(0, "definition %d does not have a name", i+1)
+		} else {
+			location := fmt.Sprintf("%s:%d", file, start+1)
+			if first, ok := firstDefined[name]; ok {
+				addProblem(start+1, "duplicate definition for %s (first defined at %s)", name, first)
+			} else {
+				firstDefined[name] = location
+			}
+			if msg := checkDefinitionName(name); msg != "" {
+				addProblem(start+1, "%s", msg)
+			}
+		}
+		for _, problem := range lintDefinition(fields, lines, start, end) {
+			line := problem.line
+			if line == 0 && nameLines[i] != -1 {
+				line = lines.key(problem.key, prevNameLine(nameLines, i)+1, start)
+			}
+			addProblem(line, "%s", problem.message)
+		}
+	}
+	return problems, nil
+}
+
+// Returns the 0-based index of the line that contains the name of the last definition before the definition with the
+// provided index whose name line is known (-1 if there is none).
+func prevNameLine(nameLines []int, i int) int {
+	for j := i - 1; j >= 0; j-- {
+		if nameLines[j] != -1 {
+			return nameLines[j]
+		}
+	}
+	return -1
+}
+
+type definitionProblem struct {
+	key     string // key of the field that has the problem
+	line    int
+	message string
+}
+
+// Returns the problems with the fields of the provided definition or defaults. Lines are searched for in the lines in
+// the range [start, end) and are 0 if the key of the field is not found in the range.
+func lintDefinition(fields map[interface{}]interface{}, lines lineFinder, start, end int) []definitionProblem {
+	var problems []definitionProblem
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, definitionProblem{
+			key:     key,
+			line:    lines.key(key, start, end),
+			message: fmt.Sprintf(format, args...),
+		})
+	}
+	for _, k := range unknownFields(fields, reflect.TypeOf(repository.Definition{}), "") {
+		key := k
+		if idx := strings.LastIndex(key, "."); idx != -1 {
+			key = key[idx+1:]
+		}
+		add(key, "unknown field %q", k)
+	}
+	if licenseID, ok := fields["license"].(string); ok && !isValidLicense(licenseID) {
+		add("license", "unknown license %q", licenseID)
+	}
+	if owners, ok := fields["owners"].([]interface{}); ok {
+		for _, owner := range owners {
+			if login, ok := owner.(string); ok && !isValidLogin(login) {
+				add("owners", "invalid owner login %q", login)
+			}
+		}
+	}
+	return problems
+}
+
+// Returns true if the provided license of a definition is empty, "custom", "custom-{{ID}}" for a known license or the
+// SPDX ID or an alias of a known license.
+func isValidLicense(licenseID string) bool {
+	if licenseID == "" || licenseID == "custom" {
+		return true
+	}
+	return license.IsKnown(strings.TrimPrefix(licenseID, "custom-"))
+}
+
+func isValidLogin(login string) bool {
+	return len(login) <= maxLoginLength && loginRegexp.MatchString(login)
+}
+
+// Returns a message that describes the problem with the provided definition name or the empty string if the name is
+// valid.
+func checkDefinitionName(name string) string {
+	if repository.IsNamePattern(name) {
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Sprintf("invalid pattern %q: %v", name, err)
+		}
+		return ""
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 {
+		return fmt.Sprintf("invalid repository name %q: must be of the form \"owner/repo\"", name)
+	}
+	if !isValidLogin(parts[0]) {
+		return fmt.Sprintf("invalid repository name %q: invalid owner login %q", name, parts[0])
+	}
+	if !repoNameRegexp.MatchString(parts[1]) || parts[1] == "." || parts[1] == ".." {
+		return fmt.Sprintf("invalid repository name %q: invalid repository %q", name, parts[1])
+	}
+	return ""
+}
+
+// Returns the paths of the keys in the provided value (as unmarshalled from YML) that do not correspond to a field of
+// the provided type based on the "yaml" tags of the fields of the type and the types of its fields.
+func unknownFields(value interface{}, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		fieldTypes := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fieldTypes[name] = field.Type
+		}
+		var keys []string
+		for k := range m {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fieldType, ok := fieldTypes[k]
+			if !ok {
+				unknown = append(unknown, prefix+k)
+				continue
+			}
+			unknown = append(unknown, unknownFields(m[k], fieldType, prefix+k+".")...)
+		}
+	case reflect.Slice:
+		s, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, elem := range s {
+			unknown = append(unknown, unknownFields(elem, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i))...)
+		}
+	case reflect.Map:
+		m, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		for k, v := range m {
+			unknown = append(unknown, unknownFields(v, t.Elem(), fmt.Sprintf("%s%v.", prefix, k))...)
+		}
+		sort.Strings(unknown)
+	}
+	return unknown
+}
+
+// lineFinder finds the lines of keys in the content of a YML or JSON file.
+type lineFinder []string
+
+// Returns the regular expression that matches a line that specifies the provided name for a definition.
+func nameRegexp(name string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*(?:[-{]\s*)?"?name"?\s*:\s*["']?` + regexp.QuoteMeta(name) + `["']?\s*,?\s*$`)
+}
+
+// Returns the 1-based line of the provided key in the lines in the range [start, end). If multiple lines specify the
+// key, the least indented one is returned. Returns 0 if no line specifies the key.
+func (f lineFinder) key(key string, start, end int) int {
+	return f.find(regexp.MustCompile(`^\s*(?:[-{]\s*)?"?`+regexp.QuoteMeta(key)+`"?\s*:`), start, end, true) + 1
+}
+
+// Returns the 0-based index of the first line in the range [start, end) that matches the provided regular expression
+// or, if leastIndented is true, the least indented such line. Returns -1 if no line matches.
+func (f lineFinder) find(re *regexp.Regexp, start, end int, leastIndented bool) int {
+	found, foundIndent := -1, 0
+	for i := start; i >= 0 && i < end && i < len(f); i++ {
+		if !re.MatchString(f[i]) {
+			continue
+		}
+		indent := len(f[i]) - len(strings.TrimLeft(f[i], " \t-{"))
+		if found == -1 || indent < foundIndent {
+			found, foundIndent = i, indent
+		}
+		if !leastIndented {
+			break
+		}
+	}
+	return found
+}
+
+// Returns the 0-based index of the line after the block that starts with the line with the provided 0-based index:
+// the first subsequent line that is not blank or a comment and is not indented further than the first line.
+func (f lineFinder) blockEnd(start int) int {
+	if start < 0 {
+		return len(f)
+	}
+	indent := len(f[start]) - len(strings.TrimLeft(f[start], " \t"))
+	for i := start + 1; i < len(f); i++ {
+		trimmed := strings.TrimLeft(f[i], " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(f[i])-len(trimmed) <= indent {
+			return i
+		}
+	}
+	return len(f)
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestLint(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	for i, currCase := range []struct {
+		content string
+		want    []string
+	}{
+		{
+			content: `- name: nmiyake/foo
+  description: Foo
+  owners: [nmiyake]
+  license: custom-mit
+  settings:
+    has_wiki: false
+- name: myorg/service-*
+  license: Apache
+`,
+		},
+		{
+			content: `- name: nmiyake/foo
+  licence: mit
+  settings:
+    has_wikis: false
+  labels:
+  - name: bug
+    colour: ff0000
+- name: nmiyake/bar
+  owners:
+  - bad_user
+  - nmiyake
+  license: mitt
+- name: nmiyake/foo
+- name: foo
+- name: -bad/foo
+- name: myorg/[
+- description: Missing name
+- name: nmiyake/baz
+  owners: nmiyake
+  license: custom-foo
+`,
+			want: []string{
+				`spec.yml:2: unknown field "licence"`,
+				`spec.yml:4: unknown field "settings.has_wikis"`,
+				`spec.yml:7: unknown field "labels[0].colour"`,
+				`spec.yml:9: invalid owner login "bad_user"`,
+				`spec.yml:12: unknown license "mitt"`,
+				`spec.yml:13: duplicate definition for nmiyake/foo (first defined at spec.yml:1)`,
+				`spec.yml:14: invalid repository name "foo": must be of the form "owner/repo"`,
+				`spec.yml:15: invalid repository name "-bad/foo": invalid owner login "-bad"`,
+				`spec.yml:16: invalid pattern "myorg/[": syntax error in pattern`,
+				"spec.yml:19: cannot unmarshal !!str `nmiyake` into []string",
+				`spec.yml:20: unknown license "custom-foo"`,
+				`spec.yml: definition 7 does not have a name`,
+			},
+		},
+		{
+			content: `defaults:
+  license: mit
+  name: nmiyake/foo
+  owner: [nmiyake]
+repository:
+- name: nmiyake/foo
+`,
+			want: []string{
+				`spec.yml:3: defaults cannot specify a name`,
+				`spec.yml:4: unknown field "owner"`,
+				`spec.yml:5: unknown field "repository"`,
+			},
+		},
+		{
+			content: `{
+  "repositories": [
+    {
+      "name": "nmiyake/foo",
+      "licence": "mit"
+    },
+    {
+      "name": "nmiyake/foo"
+    }
+  ]
+}
+`,
+			want: []string{
+				`spec.yml:5: unknown field "licence"`,
+				`spec.yml:8: duplicate definition for nmiyake/foo (first defined at spec.yml:4)`,
+			},
+		},
+	} {
+		specPath := filepath.Join(tmpDir, "spec.yml")
+		require.NoError(t, ioutil.WriteFile(specPath, []byte(currCase.content), 0644), "Case %d", i)
+		problems, err := spec.Lint(specPath)
+		require.NoError(t, err, "Case %d", i)
+
+		var got []string
+		for _, problem := range problems {
+			got = append(got, strings.Replace(problem.String(), tmpDir+"/", "", -1))
+		}
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestLintDuplicatesAcrossFiles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "a.yml"), []byte("- name: nmiyake/foo\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "b.yml"), []byte("# comment\n- name: nmiyake/bar\n- name: nmiyake/foo\n"), 0644))
+
+	problems, err := spec.Lint(tmpDir)
+	require.NoError(t, err)
+	assert.Equal(t, []spec.LintProblem{
+		{
+			File:    filepath.Join(tmpDir, "b.yml"),
+			Line:    3,
+			Message: "duplicate definition for nmiyake/foo (first defined at " + filepath.Join(tmpDir, "a.yml") + ":1)",
+		},
+	}, problems)
+}
@@ -5,48 +5,157 @@
 package spec
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sort"
 
+	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 
 	"github.com/nmiyake/ghcli/repository"
 )
 
-type ownersAnalyzer struct{}
+// ExtraOwnersMode specifies how the owners analyzer treats admins of a repository that are not listed as owners in its
+// definition.
+type ExtraOwnersMode int
 
-func NewOwnersAnalyzer() Analyzer {
-	return &ownersAnalyzer{}
+const (
+	// IgnoreExtraOwners does not report or modify admins that are not listed in the definition.
+	IgnoreExtraOwners ExtraOwnersMode = iota
+	// DowngradeExtraOwners reports admins that are not listed in the definition and fixes them by changing their
+	// permission to "push".
+	DowngradeExtraOwners
+	// RemoveExtraOwners reports admins that are not listed in the definition and fixes them by removing them as
+	// collaborators.
+	RemoveExtraOwners
+)
+
+// ParseExtraOwnersMode returns the ExtraOwnersMode represented by the provided string. The empty string maps to
+// IgnoreExtraOwners, "downgrade" maps to DowngradeExtraOwners and "remove" maps to RemoveExtraOwners.
+func ParseExtraOwnersMode(mode string) (ExtraOwnersMode, error) {
+	switch mode {
+	case "":
+		return IgnoreExtraOwners, nil
+	case "downgrade":
+		return DowngradeExtraOwners, nil
+	case "remove":
+		return RemoveExtraOwners, nil
+	default:
+		return IgnoreExtraOwners, errors.Errorf(`invalid mode %q: must be "downgrade" or "remove"`, mode)
+	}
+}
+
+type ownersAnalyzer struct {
+	client          *github.Client
+	extraOwnersMode ExtraOwnersMode
+}
+
+// NewOwnersAnalyzer returns an analyzer that verifies that the owners in the definition are admin collaborators of the
+// repository. Owners with a pending invitation to be an admin collaborator are considered to be in progress rather than
+// missing. If extraOwnersMode is not IgnoreExtraOwners, admins that are not listed in the definition are also reported
+// and are downgraded or removed by Fix.
+func NewOwnersAnalyzer(client *github.Client, extraOwnersMode ExtraOwnersMode) Analyzer {
+	return &ownersAnalyzer{
+		client:          client,
+		extraOwnersMode: extraOwnersMode,
+	}
 }
 
 func (d *ownersAnalyzer) Name() string {
 	return "owners"
 }
 
-func (d *ownersAnalyzer) Diff(def repository.Definition, info repository.Info) string {
-	if len(def.Owners) == 0 || len(info.Owners) == 0 {
+func (d *ownersAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	if len(def.Owners) == 0 {
+		return 0
+	}
+	return repository.PendingOwnersSection
+}
+
+func (d *ownersAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if len(def.Owners) == 0 || info.IsEmpty {
 		return ""
 	}
-	missing := difference(def.Owners, info.Owners)
-	if len(missing) == 0 {
+	if info.OwnersUnknown {
+		return joinDiff(
+			d.Name(),
+			fmt.Sprintf("required: %v", def.Owners),
+			"unable to determine owners: collaborators of repository could not be listed",
+		)
+	}
+	missing := d.missing(def, info)
+	extra := d.extra(def, info)
+	if len(missing) == 0 && len(extra) == 0 {
 		return ""
 	}
-	return joinDiff(
-		d.Name(),
+	parts := []string{
 		fmt.Sprintf("required: %v", def.Owners),
 		fmt.Sprintf("got:      %v", info.Owners),
 		fmt.Sprintf("missing:  %v", missing),
-	)
+	}
+	if pending := intersection(difference(def.Owners, info.Owners), info.PendingOwners); len(pending) > 0 {
+		parts = append(parts, fmt.Sprintf("pending:  %v (invitation in progress)", pending))
+	}
+	if len(extra) > 0 {
+		parts = append(parts, fmt.Sprintf("extra:    %v", extra))
+	}
+	return joinDiff(d.Name(), parts...)
 }
 
 func (d *ownersAnalyzer) CanFix() bool {
-	return false
+	return d.client != nil
+}
+
+// Fix adds the missing owners as admin collaborators of the repository. If the analyzer was created with an
+// ExtraOwnersMode other than IgnoreExtraOwners, admins that are not listed in the definition are downgraded or removed.
+func (d *ownersAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	if info.OwnersUnknown {
+		return errors.Errorf("owners of %s cannot be determined", *info.FullName)
+	}
+	for _, owner := range d.missing(def, info) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := repository.AddCollaborator(ctx, d.client, &info.Repository, owner, "admin"); err != nil {
+			return errors.Wrapf(err, "failed to add %s as admin of %s", owner, *info.FullName)
+		}
+	}
+	for _, owner := range d.extra(def, info) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch d.extraOwnersMode {
+		case DowngradeExtraOwners:
+			if err := repository.AddCollaborator(ctx, d.client, &info.Repository, owner, "push"); err != nil {
+				return errors.Wrapf(err, "failed to downgrade permissions of %s for %s", owner, *info.FullName)
+			}
+		case RemoveExtraOwners:
+			if err := repository.RemoveCollaborator(ctx, d.client, &info.Repository, owner); err != nil {
+				return errors.Wrapf(err, "failed to remove %s as collaborator of %s", owner, *info.FullName)
+			}
+		}
+	}
+	return nil
 }
 
-// TODO(nmiyake): fix by adding owners
-func (d *ownersAnalyzer) Fix(def repository.Definition, info repository.Info, stdout io.Writer) error {
-	return errors.Errorf("not implemented")
+// Returns the owners in the definition that are neither admins of the repository nor have a pending invitation to be
+// an admin of the repository.
+func (d *ownersAnalyzer) missing(def repository.Definition, info repository.Info) []string {
+	return difference(difference(def.Owners, info.Owners), info.PendingOwners)
+}
+
+// Returns the admins of the repository that are not in the definition. The owner of the repository is never considered
+// to be extra. Always returns nil if the mode of the analyzer is IgnoreExtraOwners.
+func (d *ownersAnalyzer) extra(def repository.Definition, info repository.Info) []string {
+	if d.extraOwnersMode == IgnoreExtraOwners {
+		return nil
+	}
+	var repoOwner []string
+	if info.Owner != nil && info.Owner.Login != nil {
+		repoOwner = []string{*info.Owner.Login}
+	}
+	return difference(difference(info.Owners, def.Owners), repoOwner)
 }
 
 // Returns the elements in want that are not in got. Returned slice is sorted using case-insensitive sort.
@@ -62,6 +171,19 @@ func difference(want, got []string) []string {
 	return diff
 }
 
+// Returns the elements in a that are also in b. Returned slice is sorted using case-insensitive sort.
+func intersection(a, b []string) []string {
+	var output []string
+	bSet := toSet(b)
+	for _, k := range a {
+		if _, ok := bSet[k]; ok {
+			output = append(output, k)
+		}
+	}
+	sort.Sort(repository.CaseInsensitiveStrings(output))
+	return output
+}
+
 func toSet(input []string) map[string]struct{} {
 	m := make(map[string]struct{}, len(input))
 	for i := range input {
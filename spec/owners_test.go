@@ -0,0 +1,162 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestOwnersAnalyzerDiff(t *testing.T) {
+	for i, currCase := range []struct {
+		def  repository.Definition
+		info repository.Info
+		mode spec.ExtraOwnersMode
+		want string
+	}{
+		// all owners present
+		{
+			def:  repository.Definition{Owners: []string{"alice", "bob"}},
+			info: ownersInfo([]string{"alice", "bob"}, nil),
+			want: "",
+		},
+		// missing owner
+		{
+			def:  repository.Definition{Owners: []string{"alice", "bob"}},
+			info: ownersInfo([]string{"alice"}, nil),
+			want: "owners:\n\trequired: [alice bob]\n\tgot:      [alice]\n\tmissing:  [bob]",
+		},
+		// owner with pending invitation is not missing
+		{
+			def:  repository.Definition{Owners: []string{"alice", "bob"}},
+			info: ownersInfo([]string{"alice"}, []string{"bob"}),
+			want: "",
+		},
+		// pending invitation is reported along with missing owners
+		{
+			def:  repository.Definition{Owners: []string{"alice", "bob", "carol"}},
+			info: ownersInfo([]string{"alice"}, []string{"bob"}),
+			want: "owners:\n\trequired: [alice bob carol]\n\tgot:      [alice]\n\tmissing:  [carol]\n\tpending:  [bob] (invitation in progress)",
+		},
+		// extra owners ignored by default
+		{
+			def:  repository.Definition{Owners: []string{"alice"}},
+			info: ownersInfo([]string{"alice", "mallory"}, nil),
+			want: "",
+		},
+		// extra owners reported in strict mode, but repository owner is never extra
+		{
+			def:  repository.Definition{Owners: []string{"alice"}},
+			info: ownersInfo([]string{"alice", "mallory", "octocat"}, nil),
+			mode: spec.DowngradeExtraOwners,
+			want: "owners:\n\trequired: [alice]\n\tgot:      [alice mallory octocat]\n\tmissing:  []\n\textra:    [mallory]",
+		},
+		// owners that cannot be determined are reported
+		{
+			def: repository.Definition{Owners: []string{"alice"}},
+			info: func() repository.Info {
+				info := ownersInfo(nil, nil)
+				info.OwnersUnknown = true
+				return info
+			}(),
+			want: "owners:\n\trequired: [alice]\n\tunable to determine owners: collaborators of repository could not be listed",
+		},
+	} {
+		got := spec.NewOwnersAnalyzer(nil, currCase.mode).Diff(context.Background(), currCase.def, currCase.info)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestOwnersAnalyzerFix(t *testing.T) {
+	for i, currCase := range []struct {
+		mode spec.ExtraOwnersMode
+		want []string
+	}{
+		{
+			mode: spec.IgnoreExtraOwners,
+			want: []string{
+				`PUT /repos/octocat/Hello-World/collaborators/carol {"permission":"admin"}`,
+			},
+		},
+		{
+			mode: spec.DowngradeExtraOwners,
+			want: []string{
+				`PUT /repos/octocat/Hello-World/collaborators/carol {"permission":"admin"}`,
+				`PUT /repos/octocat/Hello-World/collaborators/mallory {"permission":"push"}`,
+			},
+		},
+		{
+			mode: spec.RemoveExtraOwners,
+			want: []string{
+				`DELETE /repos/octocat/Hello-World/collaborators/mallory `,
+				`PUT /repos/octocat/Hello-World/collaborators/carol {"permission":"admin"}`,
+			},
+		},
+	} {
+		var got []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			var bodyString string
+			if body != nil {
+				bytes, err := json.Marshal(body)
+				require.NoError(t, err)
+				bodyString = string(bytes)
+			}
+			got = append(got, r.Method+" "+r.URL.String()+" "+bodyString)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+		def := repository.Definition{Owners: []string{"alice", "bob", "carol"}}
+		info := ownersInfo([]string{"alice", "mallory", "octocat"}, []string{"bob"})
+
+		err := spec.NewOwnersAnalyzer(client, currCase.mode).Fix(context.Background(), def, info, &bytes.Buffer{})
+		require.NoError(t, err, "Case %d", i)
+
+		sort.Strings(got)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+		ts.Close()
+	}
+}
+
+func TestParseExtraOwnersMode(t *testing.T) {
+	for _, currCase := range []struct {
+		in   string
+		want spec.ExtraOwnersMode
+	}{
+		{"", spec.IgnoreExtraOwners},
+		{"downgrade", spec.DowngradeExtraOwners},
+		{"remove", spec.RemoveExtraOwners},
+	} {
+		got, err := spec.ParseExtraOwnersMode(currCase.in)
+		require.NoError(t, err)
+		assert.Equal(t, currCase.want, got)
+	}
+	_, err := spec.ParseExtraOwnersMode("delete")
+	assert.EqualError(t, err, `invalid mode "delete": must be "downgrade" or "remove"`)
+}
+
+func ownersInfo(owners, pendingOwners []string) repository.Info {
+	info := testInfo("octocat")
+	info.Owners = owners
+	info.PendingOwners = pendingOwners
+	return info
+}
@@ -5,40 +5,111 @@
 package spec
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 
+	"github.com/nmiyake/ghcli/license"
 	"github.com/nmiyake/ghcli/repository"
 )
 
-type hasPatentsAnalyzer struct{}
+const defaultPatentsPath = "PATENTS"
 
-func NewHasPatentsAnalyzer() Analyzer {
-	return &hasPatentsAnalyzer{}
+type hasPatentsAnalyzer struct {
+	client   *github.Client
+	template string
+}
+
+// NewHasPatentsAnalyzer returns an analyzer that verifies whether or not a repository has a patents file. If template is
+// non-empty, the content of existing patents files must match it exactly and it is used as the content of patents files
+// added by Fix.
+func NewHasPatentsAnalyzer(client *github.Client, template string) Analyzer {
+	return &hasPatentsAnalyzer{
+		client:   client,
+		template: template,
+	}
 }
 
 func (d *hasPatentsAnalyzer) Name() string {
 	return "patents"
 }
 
-func (d *hasPatentsAnalyzer) Diff(def repository.Definition, info repository.Info) string {
-	if def.HasPatents == info.HasPatents {
+func (d *hasPatentsAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	return 0
+}
+
+func (d *hasPatentsAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if def.HasPatents != info.HasPatents {
+		return joinDiff(
+			"has patents",
+			fmt.Sprintf("want: %v", def.HasPatents),
+			fmt.Sprintf("got:  %v", info.HasPatents),
+		)
+	}
+	if !def.HasPatents || d.template == "" || info.PatentsContent == d.template {
 		return ""
 	}
-	return joinDiff(
-		"has patents",
-		fmt.Sprintf("want: %v", def.HasPatents),
-		fmt.Sprintf("got:  %v", info.HasPatents),
-	)
+	return joinDiff(fmt.Sprintf("%s content", info.PatentsPath), strings.Split(contentDiff(d.template, info.PatentsContent), "\n")...)
 }
 
 func (d *hasPatentsAnalyzer) CanFix() bool {
-	return false
+	return d.client != nil
+}
+
+// Fix opens a PR that adds or updates the patents file if the definition specifies that the repository has patents and
+// opens a PR that removes the patents file otherwise.
+func (d *hasPatentsAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, err := d.FileChanges(ctx, def, info)
+	if err != nil {
+		return err
+	}
+	if err := applyFileChanges(ctx, d.client, info, changes, stdout); err != nil {
+		return errors.Wrapf(err, "failed to fix patents file")
+	}
+	return nil
 }
 
-// TODO(nmiyake): fix by adding PATENTS file
-func (d *hasPatentsAnalyzer) Fix(def repository.Definition, info repository.Info, stdout io.Writer) error {
-	return errors.Errorf("not implemented")
+// FileChanges returns the change that adds or updates the patents file if the definition specifies that the repository
+// has patents and the change that removes the patents file otherwise.
+func (d *hasPatentsAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error) {
+	var change license.FileChange
+	var prParams license.PRParams
+	switch {
+	case !def.HasPatents:
+		change = license.FileChange{
+			Path:   info.PatentsPath,
+			Delete: true,
+		}
+		prParams = patentsPRParams("Remove " + info.PatentsPath)
+	case d.template == "":
+		return FileChanges{}, errors.Errorf("cannot add patents file because no template was provided")
+	case info.HasPatents:
+		change = license.FileChange{
+			Path:    info.PatentsPath,
+			Content: d.template,
+		}
+		prParams = patentsPRParams("Update " + info.PatentsPath)
+	default:
+		change = license.FileChange{
+			Path:    defaultPatentsPath,
+			Content: d.template,
+		}
+		prParams = patentsPRParams("Add " + defaultPatentsPath)
+	}
+	return FileChanges{
+		Changes:  []license.FileChange{change},
+		PRParams: prParams,
+	}, nil
+}
+
+func patentsPRParams(title string) license.PRParams {
+	return license.PRParams{
+		Branch: "cli-update-patents",
+		Title:  title,
+		Body:   "Update patents file for repository to match specification.",
+	}
 }
@@ -0,0 +1,331 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+const patentsTemplate = "Additional Grant of Patent Rights\n"
+
+func TestHasPatentsAnalyzerDiff(t *testing.T) {
+	for i, currCase := range []struct {
+		template string
+		def      repository.Definition
+		info     repository.Info
+		want     string
+	}{
+		{
+			def:  repository.Definition{HasPatents: true},
+			info: repository.Info{},
+			want: "has patents:\n\twant: true\n\tgot:  false",
+		},
+		{
+			def:  repository.Definition{HasPatents: true},
+			info: repository.Info{HasPatents: true, PatentsPath: "PATENTS", PatentsContent: "Modified\n"},
+			want: "",
+		},
+		{
+			template: patentsTemplate,
+			def:      repository.Definition{HasPatents: true},
+			info:     repository.Info{HasPatents: true, PatentsPath: "PATENTS", PatentsContent: patentsTemplate},
+			want:     "",
+		},
+		{
+			template: patentsTemplate,
+			def:      repository.Definition{HasPatents: true},
+			info:     repository.Info{HasPatents: true, PatentsPath: "PATENTS", PatentsContent: "Modified\n"},
+			want:     "PATENTS content:\n\t--- Expected\n\t+++ Actual\n\t@@ -1 +1 @@\n\t-Additional Grant of Patent Rights\n\t+Modified",
+		},
+	} {
+		got := spec.NewHasPatentsAnalyzer(nil, currCase.template).Diff(context.Background(), currCase.def, currCase.info)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestHasPatentsAnalyzerFixAdd(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+
+	def := repository.Definition{HasPatents: true}
+	info := testInfo("octocat")
+	require.NoError(t, spec.NewHasPatentsAnalyzer(server.client(), patentsTemplate).Fix(context.Background(), def, info, &bytes.Buffer{}))
+
+	require.Len(t, server.trees, 1)
+	assert.Equal(t, "base-tree-sha", server.trees[0].BaseTree)
+	assert.Equal(t, []github.TreeEntry{
+		{
+			Path:    github.String("PATENTS"),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(patentsTemplate),
+		},
+	}, server.trees[0].Entries)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "Add PATENTS", *server.pulls[0].Title)
+	assert.Equal(t, "cli-update-patents", *server.pulls[0].Head)
+}
+
+func TestHasPatentsAnalyzerFixRemove(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+
+	def := repository.Definition{HasPatents: false}
+	info := testInfo("octocat")
+	info.HasPatents = true
+	info.PatentsPath = "PATENTS.txt"
+	require.NoError(t, spec.NewHasPatentsAnalyzer(server.client(), "").Fix(context.Background(), def, info, &bytes.Buffer{}))
+
+	// only the root directory is rebuilt: its subtrees are reused
+	require.Len(t, server.trees, 1)
+	assert.Equal(t, "", server.trees[0].BaseTree)
+	assert.Equal(t, []github.TreeEntry{
+		{
+			Path: github.String("README.md"),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  github.String("readme-sha"),
+		},
+		{
+			Path: github.String("docs"),
+			Mode: github.String("040000"),
+			Type: github.String("tree"),
+			SHA:  github.String("docs-sha"),
+		},
+		{
+			Path: github.String("src"),
+			Mode: github.String("040000"),
+			Type: github.String("tree"),
+			SHA:  github.String("src-sha"),
+		},
+	}, server.trees[0].Entries)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "Remove PATENTS.txt", *server.pulls[0].Title)
+}
+
+func TestHasPatentsAnalyzerFixRemoveFromDirectory(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+
+	def := repository.Definition{HasPatents: false}
+	info := testInfo("octocat")
+	info.HasPatents = true
+	info.PatentsPath = "docs/PATENTS"
+	require.NoError(t, spec.NewHasPatentsAnalyzer(server.client(), "").Fix(context.Background(), def, info, &bytes.Buffer{}))
+
+	// directory of the file is rebuilt first and then the root directory is rebuilt to refer to it
+	require.Len(t, server.trees, 2)
+	assert.Equal(t, []github.TreeEntry{
+		{
+			Path: github.String("index.md"),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  github.String("index-sha"),
+		},
+	}, server.trees[0].Entries)
+	assert.Equal(t, []github.TreeEntry{
+		{
+			Path: github.String("PATENTS.txt"),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  github.String("patents-sha"),
+		},
+		{
+			Path: github.String("README.md"),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  github.String("readme-sha"),
+		},
+		{
+			Path: github.String("docs"),
+			Mode: github.String("040000"),
+			Type: github.String("tree"),
+			SHA:  github.String("new-tree-sha-1"),
+		},
+		{
+			Path: github.String("src"),
+			Mode: github.String("040000"),
+			Type: github.String("tree"),
+			SHA:  github.String("src-sha"),
+		},
+	}, server.trees[1].Entries)
+	require.Len(t, server.pulls, 1)
+}
+
+func TestHasPatentsAnalyzerFixRemoveTruncatedTree(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	server.truncated = true
+
+	def := repository.Definition{HasPatents: false}
+	info := testInfo("octocat")
+	info.HasPatents = true
+	info.PatentsPath = "PATENTS.txt"
+	err := spec.NewHasPatentsAnalyzer(server.client(), "").Fix(context.Background(), def, info, &bytes.Buffer{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tree base-tree-sha of octocat/Hello-World has more entries than the GitHub API returns")
+
+	// a partial tree would remove the files that were not listed, so nothing is created
+	assert.Empty(t, server.trees)
+	assert.Empty(t, server.refs)
+	assert.Empty(t, server.pulls)
+}
+
+func TestHasPatentsAnalyzerFixDryRun(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+
+	def := repository.Definition{HasPatents: true}
+	info := testInfo("octocat")
+	buf := &bytes.Buffer{}
+	analyzer := spec.NewHasPatentsAnalyzer(server.dryRunClient(), patentsTemplate)
+	require.NoError(t, server.applyDryRun(analyzer, def, info, buf))
+
+	assert.Empty(t, server.trees)
+	assert.Empty(t, server.refs)
+	assert.Empty(t, server.pulls)
+	assert.Equal(t, `User has push permissions to repository
+Dry run: the following changes would be made
+Target repository: octocat/Hello-World
+Branch: cli-update-patents
+Commit message: Add PATENTS
+Files:
+	PATENTS (mode 100644):
+		Additional Grant of Patent Rights
+Pull request: octocat/Hello-World from cli-update-patents into master
+Title: Add PATENTS
+Body:
+	Update patents file for repository to match specification.
+`, buf.String())
+}
+
+func TestHasPatentsAnalyzerFixWithDryRunClient(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+
+	// the client rejects the requests of a fix that is not a dry run
+	err := spec.NewHasPatentsAnalyzer(server.dryRunClient(), patentsTemplate).Fix(context.Background(), repository.Definition{HasPatents: true}, testInfo("octocat"), &bytes.Buffer{})
+	assert.True(t, repository.IsDryRunError(err), "unexpected error: %v", err)
+	assert.Empty(t, server.trees)
+	assert.Empty(t, server.refs)
+	assert.Empty(t, server.pulls)
+}
+
+func TestHasPatentsAnalyzerFixDryRunWithoutFork(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	server.mux.HandleFunc("/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, []github.Repository{})
+	})
+	server.mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		testutil.WriteJSON(t, w, github.User{Login: github.String("hubot")})
+	})
+
+	def := repository.Definition{HasPatents: true}
+	info := testInfo("octocat")
+	info.Permissions = &map[string]bool{"push": false}
+	buf := &bytes.Buffer{}
+	require.NoError(t, server.applyDryRun(spec.NewHasPatentsAnalyzer(server.dryRunClient(), patentsTemplate), def, info, buf))
+
+	assert.Empty(t, server.trees)
+	assert.Contains(t, buf.String(), "Target repository: hubot/Hello-World (fork of octocat/Hello-World)\n")
+	assert.Contains(t, buf.String(), "Pull request: octocat/Hello-World from hubot:cli-update-patents into master\n")
+}
+
+func TestHasPatentsAnalyzerFixUpdatesExistingPR(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	server.branches = []string{"cli-update-patents"}
+	server.openPulls = []github.PullRequest{{Number: github.Int(7)}}
+
+	def := repository.Definition{HasPatents: true}
+	info := testInfo("octocat")
+	buf := &bytes.Buffer{}
+	require.NoError(t, spec.NewHasPatentsAnalyzer(server.client(), patentsTemplate).Fix(context.Background(), def, info, buf))
+
+	require.Len(t, server.trees, 1)
+	assert.Empty(t, server.refs)
+	assert.Equal(t, []updateRefRequest{{SHA: "new-commit-sha", Force: true}}, server.refUpdates)
+	assert.Empty(t, server.pulls)
+	require.Len(t, server.pullEdits, 1)
+	assert.Equal(t, "Add PATENTS", *server.pullEdits[0].Title)
+	assert.Equal(t, "Update patents file for repository to match specification.", *server.pullEdits[0].Body)
+	assert.Contains(t, buf.String(), "Updating pull request #7...OK\n")
+}
+
+func TestHasPatentsAnalyzerFixRecreatesClosedPR(t *testing.T) {
+	server := newPRServer(t)
+	defer server.Close()
+	// branch of a closed PR still exists, but there is no open PR
+	server.branches = []string{"cli-update-patents"}
+
+	def := repository.Definition{HasPatents: true}
+	info := testInfo("octocat")
+	require.NoError(t, spec.NewHasPatentsAnalyzer(server.client(), patentsTemplate).Fix(context.Background(), def, info, &bytes.Buffer{}))
+
+	assert.Empty(t, server.refs)
+	assert.Equal(t, []updateRefRequest{{SHA: "new-commit-sha", Force: true}}, server.refUpdates)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "cli-update-patents", *server.pulls[0].Head)
+	assert.Empty(t, server.pullEdits)
+}
+
+func TestHasPatentsAnalyzerFixContextDone(t *testing.T) {
+	for i, currCase := range []struct {
+		cancelAfter string // path of the request after which the context is cancelled
+		wantErr     bool
+		wantRefs    int
+		wantPulls   int
+	}{
+		// commit that is not on a branch is abandoned
+		{cancelAfter: "/repos/octocat/Hello-World/git/commits", wantErr: true},
+		// once the branch exists, the PR is opened as well
+		{cancelAfter: "/repos/octocat/Hello-World/git/refs", wantRefs: 1, wantPulls: 1},
+	} {
+		server := newPRServer(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		client := github.NewClient(&http.Client{Transport: cancelAfterTransport{path: currCase.cancelAfter, cancel: cancel}})
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+
+		err := spec.NewHasPatentsAnalyzer(client, patentsTemplate).Fix(ctx, repository.Definition{HasPatents: true}, testInfo("octocat"), &bytes.Buffer{})
+		if currCase.wantErr {
+			assert.Equal(t, context.Canceled, errors.Cause(err), "Case %d", i)
+		} else {
+			assert.NoError(t, err, "Case %d", i)
+		}
+		assert.Len(t, server.refs, currCase.wantRefs, "Case %d", i)
+		assert.Len(t, server.pulls, currCase.wantPulls, "Case %d", i)
+
+		cancel()
+		server.Close()
+	}
+}
+
+// cancelAfterTransport cancels a context once a request for the provided path has been made.
+type cancelAfterTransport struct {
+	path   string
+	cancel context.CancelFunc
+}
+
+func (t cancelAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if req.URL.Path == t.path {
+		t.cancel()
+	}
+	return resp, err
+}
@@ -0,0 +1,198 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+// planVersion is the version of the plan file format written by WritePlan.
+const planVersion = 1
+
+// Plan is the set of changes that applying a specification makes to repositories. It is created by comparing the
+// repositories with their definitions and is executed later, which allows the changes to be reviewed before they are
+// made.
+type Plan struct {
+	Version      int              `json:"version"`
+	Repositories []RepositoryPlan `json:"repositories"`
+}
+
+// RepositoryPlan is the set of changes for a single repository. Definition is the definition of the repository when
+// the plan was created and is the definition that is applied when the plan is executed.
+type RepositoryPlan struct {
+	FullName   string                `json:"name"`
+	Definition repository.Definition `json:"definition"`
+	Changes    []PlannedChange       `json:"changes"`
+}
+
+// PlannedChange is the change that an analyzer makes to a repository. Diff is the difference reported by the analyzer
+// when the plan was created, and CanFix is false if the analyzer cannot fix the difference. For analyzers that fix
+// differences by changing files, Files are the file changes that the fix makes. They are recorded because the diff
+// does not always include the content that is written (for example, for license headers and plugins).
+type PlannedChange struct {
+	Analyzer string        `json:"analyzer"`
+	Diff     string        `json:"diff"`
+	CanFix   bool          `json:"can_fix"`
+	Files    []PlannedFile `json:"files,omitempty"`
+
+	// fileChanges and fileChangesErr are the result of FileChanges for analyzers that determine it along with Diff (nil
+	// if it was not determined).
+	fileChanges    *FileChanges
+	fileChangesErr error
+}
+
+// PlannedFile is a file change in a PlannedChange. The content of the file is identified by its SHA-256 hash so that
+// the plan does not contain the content of every changed file.
+type PlannedFile struct {
+	Path          string `json:"path"`
+	Delete        bool   `json:"delete,omitempty"`
+	ContentSHA256 string `json:"content_sha256,omitempty"` // hex-encoded SHA-256 hash of the content (empty if Delete is true)
+}
+
+// Returns the planned files for the provided file changes.
+func plannedFiles(changes []license.FileChange) []PlannedFile {
+	var files []PlannedFile
+	for _, change := range changes {
+		file := PlannedFile{
+			Path:   change.Path,
+			Delete: change.Delete,
+		}
+		if !change.Delete {
+			sum := sha256.Sum256([]byte(change.Content))
+			file.ContentSHA256 = hex.EncodeToString(sum[:])
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// FileChanges returns the file changes of the provided analyzer (which must be the analyzer of the change) for the
+// repository with the provided definition and information. If the changes were determined when the plan was created,
+// they are returned without calling the analyzer again.
+func (c PlannedChange) FileChanges(ctx context.Context, analyzer FileChangeAnalyzer, def repository.Definition, info repository.Info) (FileChanges, error) {
+	if c.fileChanges != nil {
+		return *c.fileChanges, c.fileChangesErr
+	}
+	return analyzer.FileChanges(ctx, def, info)
+}
+
+// NewRepositoryPlan returns the plan for the repository with the provided information based on the differences that the
+// provided analyzers report between the repository and the provided definition. The file changes of the
+// FileChangeAnalyzers that can fix their differences are determined as well. The returned plan has no changes if the
+// repository matches its definition.
+func NewRepositoryPlan(ctx context.Context, def repository.Definition, info repository.Info, analyzers []Analyzer) RepositoryPlan {
+	plan := RepositoryPlan{
+		FullName:   *info.FullName,
+		Definition: def,
+	}
+	for _, analyzer := range analyzers {
+		var change PlannedChange
+		if differ, ok := analyzer.(fileChangeDiffer); ok {
+			diff, changes, err := differ.diffAndFileChanges(ctx, def, info)
+			change = PlannedChange{
+				Diff:           diff,
+				fileChanges:    &changes,
+				fileChangesErr: err,
+			}
+		} else {
+			change.Diff = analyzer.Diff(ctx, def, info)
+		}
+		if change.Diff == "" {
+			continue
+		}
+		change.Analyzer = analyzer.Name()
+		change.CanFix = analyzer.CanFix()
+		if fileAnalyzer, ok := analyzer.(FileChangeAnalyzer); ok && change.CanFix {
+			if change.fileChanges == nil {
+				changes, err := fileAnalyzer.FileChanges(ctx, def, info)
+				change.fileChanges, change.fileChangesErr = &changes, err
+			}
+			change.Files = plannedFiles(change.fileChanges.Changes)
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan
+}
+
+// CheckDrift returns the current plan for the repository with the provided information (see NewRepositoryPlan) along
+// with an error that describes how the repository has drifted since the plan was created: that is, the differences that
+// the provided analyzers report for the definition of the plan or the file changes that fix them are not the same as
+// the ones in the plan. The error is nil if the repository has not drifted, in which case the current plan has the same
+// changes as the plan and should be used to fix them.
+func (p RepositoryPlan) CheckDrift(ctx context.Context, info repository.Info, analyzers []Analyzer) (RepositoryPlan, error) {
+	current := NewRepositoryPlan(ctx, p.Definition, info, analyzers)
+	currentChanges := make(map[string]PlannedChange)
+	for _, change := range current.Changes {
+		currentChanges[change.Analyzer] = change
+	}
+	analyzerNames := make(map[string]struct{})
+	for _, analyzer := range analyzers {
+		analyzerNames[analyzer.Name()] = struct{}{}
+	}
+
+	var drift []string
+	plannedAnalyzers := make(map[string]struct{})
+	for _, change := range p.Changes {
+		plannedAnalyzers[change.Analyzer] = struct{}{}
+		if _, ok := analyzerNames[change.Analyzer]; !ok {
+			drift = append(drift, fmt.Sprintf("%s: analyzer is not configured", change.Analyzer))
+		} else if currentChanges[change.Analyzer].Diff != change.Diff {
+			drift = append(drift, fmt.Sprintf("%s: differences have changed since the plan was created", change.Analyzer))
+		} else if !reflect.DeepEqual(currentChanges[change.Analyzer].Files, change.Files) {
+			drift = append(drift, fmt.Sprintf("%s: file changes have changed since the plan was created", change.Analyzer))
+		}
+	}
+	for _, change := range current.Changes {
+		if _, ok := plannedAnalyzers[change.Analyzer]; !ok {
+			drift = append(drift, fmt.Sprintf("%s: new differences since the plan was created", change.Analyzer))
+		}
+	}
+	if len(drift) == 0 {
+		return current, nil
+	}
+	return current, errors.Errorf("%s", strings.Join(drift, "\n"))
+}
+
+// WritePlan writes the provided plan as JSON to the file at the provided path.
+func WritePlan(plan Plan, planPath string) error {
+	plan.Version = planVersion
+	bytes, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal plan as JSON")
+	}
+	if err := ioutil.WriteFile(planPath, append(bytes, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write plan to %s", planPath)
+	}
+	return nil
+}
+
+// ReadPlan returns the plan in the file at the provided path. Returns an error if the file was not written by a
+// compatible version of WritePlan.
+func ReadPlan(planPath string) (Plan, error) {
+	bytes, err := ioutil.ReadFile(planPath)
+	if err != nil {
+		return Plan{}, errors.Wrapf(err, "failed to read plan %s", planPath)
+	}
+	var plan Plan
+	if err := json.Unmarshal(bytes, &plan); err != nil {
+		return Plan{}, errors.Wrapf(err, "failed to unmarshal plan %s", planPath)
+	}
+	if plan.Version != planVersion {
+		return Plan{}, errors.Errorf("plan %s has version %d, but only version %d is supported", planPath, plan.Version, planVersion)
+	}
+	return plan, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+// descriptionOnlyAnalyzer is an analyzer that reports the description of a repository if it differs from the
+// definition and cannot fix it.
+type descriptionOnlyAnalyzer struct{}
+
+func (descriptionOnlyAnalyzer) Name() string {
+	return "description"
+}
+
+func (descriptionOnlyAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if info.Description != nil && *info.Description != def.Description {
+		return "description:\n\t" + *info.Description
+	}
+	return ""
+}
+
+func (descriptionOnlyAnalyzer) CanFix() bool {
+	return false
+}
+
+func (descriptionOnlyAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	return nil
+}
+
+func TestRepositoryPlan(t *testing.T) {
+	def := repository.Definition{
+		FullName:    "octocat/Hello-World",
+		Description: "Hello",
+	}
+	info := repository.Info{
+		Repository: github.Repository{
+			FullName:    github.String("octocat/Hello-World"),
+			Description: github.String("Goodbye"),
+		},
+	}
+	analyzers := []spec.Analyzer{descriptionOnlyAnalyzer{}}
+
+	repoPlan := spec.NewRepositoryPlan(context.Background(), def, info, analyzers)
+	assert.Equal(t, spec.RepositoryPlan{
+		FullName:   "octocat/Hello-World",
+		Definition: def,
+		Changes: []spec.PlannedChange{
+			{
+				Analyzer: "description",
+				Diff:     "description:\n\tGoodbye",
+			},
+		},
+	}, repoPlan)
+	currentPlan, err := repoPlan.CheckDrift(context.Background(), info, analyzers)
+	assert.NoError(t, err)
+	assert.Equal(t, repoPlan, currentPlan)
+
+	info.Description = github.String("Changed")
+	_, err = repoPlan.CheckDrift(context.Background(), info, analyzers)
+	assert.EqualError(t, err, "description: differences have changed since the plan was created")
+
+	_, err = repoPlan.CheckDrift(context.Background(), info, nil)
+	assert.EqualError(t, err, "description: analyzer is not configured")
+
+	noChangesPlan := spec.NewRepositoryPlan(context.Background(), repository.Definition{Description: "Hello"}, info, analyzers)
+	noChangesPlan.Changes = nil
+	_, err = noChangesPlan.CheckDrift(context.Background(), info, analyzers)
+	assert.EqualError(t, err, "description: new differences since the plan was created")
+}
+
+// headerFileAnalyzer is a FileChangeAnalyzer that reports that the header of main.go is incorrect without including
+// the header in the difference.
+type headerFileAnalyzer struct {
+	header string
+}
+
+func (headerFileAnalyzer) Name() string {
+	return "headers"
+}
+
+func (headerFileAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	return "headers:\n\tmain.go: missing or incorrect header"
+}
+
+func (headerFileAnalyzer) CanFix() bool {
+	return true
+}
+
+func (headerFileAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	return nil
+}
+
+func (a headerFileAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (spec.FileChanges, error) {
+	return spec.FileChanges{
+		Changes: []license.FileChange{
+			{Path: "main.go", Content: a.header + "\npackage main\n"},
+			{Path: "old.go", Delete: true},
+		},
+	}, nil
+}
+
+func TestRepositoryPlanFileChanges(t *testing.T) {
+	def := repository.Definition{FullName: "octocat/Hello-World"}
+	info := repository.Info{
+		Repository: github.Repository{
+			FullName: github.String("octocat/Hello-World"),
+		},
+	}
+
+	repoPlan := spec.NewRepositoryPlan(context.Background(), def, info, []spec.Analyzer{headerFileAnalyzer{header: "// Copyright 2016"}})
+	require.Len(t, repoPlan.Changes, 1)
+	assert.Equal(t, []spec.PlannedFile{
+		{Path: "main.go", ContentSHA256: "da7fec5878dfbf911cb226c19482d6ebae1bc8bb60e6176454e4ad6dded0d7c5"},
+		{Path: "old.go", Delete: true},
+	}, repoPlan.Changes[0].Files)
+
+	_, err := repoPlan.CheckDrift(context.Background(), info, []spec.Analyzer{headerFileAnalyzer{header: "// Copyright 2016"}})
+	assert.NoError(t, err)
+
+	// the difference is the same, but the content that would be written is not
+	_, err = repoPlan.CheckDrift(context.Background(), info, []spec.Analyzer{headerFileAnalyzer{header: "// Copyright 2017"}})
+	assert.EqualError(t, err, "headers: file changes have changed since the plan was created")
+}
+
+func TestWriteReadPlan(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	plan := spec.Plan{
+		Repositories: []spec.RepositoryPlan{
+			{
+				FullName: "octocat/Hello-World",
+				Definition: repository.Definition{
+					FullName: "octocat/Hello-World",
+					Owners:   []string{"octocat"},
+					License:  "mit",
+					Settings: &repository.Settings{HasWiki: github.Bool(false)},
+				},
+				Changes: []spec.PlannedChange{
+					{
+						Analyzer: "license",
+						Diff:     "license:\n\tmissing",
+						CanFix:   true,
+						Files: []spec.PlannedFile{
+							{Path: "LICENSE", ContentSHA256: "0000000000000000000000000000000000000000000000000000000000000000"},
+							{Path: "LICENSE.txt", Delete: true},
+						},
+					},
+				},
+			},
+		},
+	}
+	planPath := filepath.Join(tmpDir, "plan.json")
+	require.NoError(t, spec.WritePlan(plan, planPath))
+	got, err := spec.ReadPlan(planPath)
+	require.NoError(t, err)
+	plan.Version = 1
+	assert.Equal(t, plan, got)
+
+	require.NoError(t, ioutil.WriteFile(planPath, []byte(`{"version": 2}`), 0644))
+	_, err = spec.ReadPlan(planPath)
+	assert.EqualError(t, err, "plan "+planPath+" has version 2, but only version 1 is supported")
+}
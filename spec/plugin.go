@@ -0,0 +1,264 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/license"
+	"github.com/nmiyake/ghcli/repository"
+)
+
+// defaultPluginTimeout is the maximum amount of time that a plugin can run if its configuration does not specify a
+// timeout.
+const defaultPluginTimeout = time.Minute
+
+// pluginWaitDelay is the maximum amount of time to wait for the output of a plugin to be closed after it was killed.
+const pluginWaitDelay = time.Second
+
+// Plugin is the configuration for an external executable that acts as an analyzer.
+//
+// The executable is run for every repository that is verified or fixed, and the findings and the fix plan of a single
+// run are used for both. It receives a PluginRequest as JSON on stdin and must write a PluginResponse as JSON to stdout
+// and exit with status 0. Anything written to stderr is included in the error if the plugin fails.
+type Plugin struct {
+	Name    string   `yaml:"name" json:"name"`
+	Command string   `yaml:"command" json:"command"` // path to the executable
+	Args    []string `yaml:"args,omitempty" json:"args,omitempty"`
+	// Timeout is the maximum amount of time that a single run of the plugin can take, as a duration string such as
+	// "30s". Defaults to one minute.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// PluginRequest is the input provided to a plugin.
+type PluginRequest struct {
+	Definition repository.Definition `json:"definition"`
+	Info       repository.Info       `json:"info"`
+}
+
+// PluginResponse is the output of a plugin. Findings are the differences between the definition and the repository
+// (the repository conforms to the definition if there are none). Fix is the plan that resolves the findings and may be
+// nil if the plugin cannot fix them.
+type PluginResponse struct {
+	Findings []string       `json:"findings"`
+	Fix      *PluginFixPlan `json:"fix,omitempty"`
+}
+
+// PluginFixPlan describes a PR that fixes the findings of a plugin.
+type PluginFixPlan struct {
+	Branch  string             `json:"branch,omitempty"` // defaults to "cli-plugin-{{name}}"
+	Title   string             `json:"title"`
+	Body    string             `json:"body,omitempty"`
+	Changes []PluginFileChange `json:"changes"`
+}
+
+// PluginFileChange is a change to a file in a PluginFixPlan.
+type PluginFileChange struct {
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
+	Delete  bool   `json:"delete,omitempty"`
+}
+
+type pluginAnalyzer struct {
+	client  *github.Client
+	plugin  Plugin
+	timeout time.Duration
+}
+
+// NewPluginAnalyzer returns an analyzer that runs the provided plugin. Failures of the plugin (including timeouts,
+// non-zero exit codes and invalid output) are reported as differences by Diff and as errors by Fix so that they do not
+// affect other analyzers.
+func NewPluginAnalyzer(client *github.Client, plugin Plugin) (Analyzer, error) {
+	if plugin.Name == "" || plugin.Command == "" {
+		return nil, errors.Errorf("plugin must specify a name and a command: %+v", plugin)
+	}
+	timeout := defaultPluginTimeout
+	if plugin.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(plugin.Timeout); err != nil {
+			return nil, errors.Wrapf(err, "invalid timeout for plugin %s", plugin.Name)
+		}
+	}
+	return &pluginAnalyzer{
+		client:  client,
+		plugin:  plugin,
+		timeout: timeout,
+	}, nil
+}
+
+// AddPluginAnalyzers returns the provided analyzers followed by analyzers that run the provided plugins. Returns an
+// error if a plugin has the same name as one of the analyzers or as another plugin, since changes in a plan are
+// associated with their analyzer by name.
+func AddPluginAnalyzers(analyzers []Analyzer, client *github.Client, plugins []Plugin) ([]Analyzer, error) {
+	names := make(map[string]struct{})
+	for _, analyzer := range analyzers {
+		names[analyzer.Name()] = struct{}{}
+	}
+	for _, plugin := range plugins {
+		if _, ok := names[plugin.Name]; ok {
+			return nil, errors.Errorf("plugin name %s is already used by another analyzer", plugin.Name)
+		}
+		names[plugin.Name] = struct{}{}
+		analyzer, err := NewPluginAnalyzer(client, plugin)
+		if err != nil {
+			return nil, err
+		}
+		analyzers = append(analyzers, analyzer)
+	}
+	return analyzers, nil
+}
+
+func (d *pluginAnalyzer) Name() string {
+	return d.plugin.Name
+}
+
+func (d *pluginAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	diff, _, _ := d.diffAndFileChanges(ctx, def, info)
+	return diff
+}
+
+func (d *pluginAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix runs the plugin and opens a PR that applies its fix plan. Returns an error if the plugin reports findings but
+// does not provide a fix plan.
+func (d *pluginAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	changes, err := d.FileChanges(ctx, def, info)
+	if err != nil {
+		return err
+	}
+	if err := applyFileChanges(ctx, d.client, info, changes, stdout); err != nil {
+		return errors.Wrapf(err, "failed to apply fix of plugin %s", d.Name())
+	}
+	return nil
+}
+
+// FileChanges runs the plugin and returns the changes in its fix plan. Returns an error if the plugin reports findings
+// but does not provide a fix plan.
+func (d *pluginAnalyzer) FileChanges(ctx context.Context, def repository.Definition, info repository.Info) (FileChanges, error) {
+	_, changes, err := d.diffAndFileChanges(ctx, def, info)
+	return changes, err
+}
+
+// diffAndFileChanges runs the plugin once and returns its findings as a difference along with the changes in its fix
+// plan.
+func (d *pluginAnalyzer) diffAndFileChanges(ctx context.Context, def repository.Definition, info repository.Info) (string, FileChanges, error) {
+	resp, err := d.run(ctx, def, info)
+	if err != nil {
+		return joinDiff(d.Name(), err.Error()), FileChanges{}, err
+	}
+	if len(resp.Findings) == 0 {
+		return "", FileChanges{}, nil
+	}
+	changes, err := d.fileChanges(resp)
+	return joinDiff(d.Name(), resp.Findings...), changes, err
+}
+
+// Returns the changes in the fix plan of the provided response.
+func (d *pluginAnalyzer) fileChanges(resp PluginResponse) (FileChanges, error) {
+	if resp.Fix == nil || len(resp.Fix.Changes) == 0 {
+		return FileChanges{}, errors.Errorf("plugin %s does not provide a fix", d.Name())
+	}
+
+	prParams := license.PRParams{
+		Branch: resp.Fix.Branch,
+		Title:  resp.Fix.Title,
+		Body:   resp.Fix.Body,
+	}
+	if prParams.Branch == "" {
+		prParams.Branch = "cli-plugin-" + d.Name()
+	}
+	var changes []license.FileChange
+	for _, change := range resp.Fix.Changes {
+		changes = append(changes, license.FileChange{
+			Path:    change.Path,
+			Content: change.Content,
+			Delete:  change.Delete,
+		})
+	}
+	return FileChanges{
+		Changes:  changes,
+		PRParams: prParams,
+	}, nil
+}
+
+// Runs the plugin with the provided definition and information and returns its response. The plugin and any processes
+// that it started are killed if it does not finish within the timeout of the plugin or if the provided context is done.
+func (d *pluginAnalyzer) run(ctx context.Context, def repository.Definition, info repository.Info) (PluginResponse, error) {
+	input, err := json.Marshal(PluginRequest{
+		Definition: def,
+		Info:       info,
+	})
+	if err != nil {
+		return PluginResponse{}, errors.Wrapf(err, "failed to marshal input for plugin %s", d.Name())
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	cmd := exec.Command(d.plugin.Command, d.plugin.Args...)
+	setProcessGroup(cmd)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := waitPlugin(runCtx, cmd); err != nil {
+		if err := ctx.Err(); err != nil {
+			return PluginResponse{}, errors.Wrapf(err, "plugin %s was stopped", d.Name())
+		}
+		if runCtx.Err() == context.DeadlineExceeded {
+			return PluginResponse{}, errors.Errorf("plugin %s timed out after %v", d.Name(), d.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.Errorf("%v: %s", err, msg)
+		}
+		return PluginResponse{}, errors.Wrapf(err, "plugin %s failed", d.Name())
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return PluginResponse{}, errors.Wrapf(err, "plugin %s returned invalid output", d.Name())
+	}
+	return resp, nil
+}
+
+// waitPlugin starts the provided command and waits for it to finish. If the provided context is done first, the process
+// group of the command is killed and the error of waiting for the command is returned. Wait only returns once the output
+// of the command is closed, which a process that escaped the process group could hold open indefinitely, so an error
+// is returned if the output is not closed within pluginWaitDelay of the command being killed.
+func waitPlugin(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- cmd.Wait()
+	}()
+	select {
+	case err := <-waitErr:
+		return err
+	case <-ctx.Done():
+	}
+	if err := killProcessGroup(cmd.Process); err != nil {
+		return errors.Wrapf(err, "failed to kill process")
+	}
+	timer := time.NewTimer(pluginWaitDelay)
+	defer timer.Stop()
+	select {
+	case err := <-waitErr:
+		return err
+	case <-timer.C:
+		return errors.Errorf("output of killed process was not closed within %v", pluginWaitDelay)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestPluginAnalyzerDiff(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	for i, currCase := range []struct {
+		script  string
+		timeout string
+		want    string
+	}{
+		{
+			script: `cat > /dev/null; echo '{"findings": []}'`,
+			want:   "",
+		},
+		{
+			// plugin receives the definition and the information for the repository
+			script: `input=$(cat)
+echo "$input" | grep -q '"definition":{"name":"octocat/Hello-World"' || exit 1
+echo "$input" | grep -q '"info":{"id":1,"owner":{"login":"octocat"' || exit 1
+echo '{"findings": ["README.md: missing badge"]}'`,
+			want: "test-plugin:\n\tREADME.md: missing badge",
+		},
+		{
+			script: `echo "something went wrong" >&2; exit 3`,
+			want:   "test-plugin:\n\tplugin test-plugin failed: exit status 3: something went wrong",
+		},
+		{
+			script: `echo "not JSON"`,
+			want:   "test-plugin:\n\tplugin test-plugin returned invalid output: invalid character 'o' in literal null (expecting 'u')",
+		},
+		{
+			// processes started by the plugin are killed along with it
+			script:  `sleep 5; echo '{"findings": []}'`,
+			timeout: "100ms",
+			want:    "test-plugin:\n\tplugin test-plugin timed out after 100ms",
+		},
+		{
+			script:  `sleep 5 & wait`,
+			timeout: "100ms",
+			want:    "test-plugin:\n\tplugin test-plugin timed out after 100ms",
+		},
+		{
+			// a process that escapes the process group is not waited for once the plugin is killed
+			script:  `setsid sleep 5 & wait`,
+			timeout: "100ms",
+			want:    "test-plugin:\n\tplugin test-plugin timed out after 100ms",
+		},
+	} {
+		analyzer, err := spec.NewPluginAnalyzer(nil, spec.Plugin{
+			Name:    "test-plugin",
+			Command: writePlugin(t, tmpDir, currCase.script),
+			Timeout: currCase.timeout,
+		})
+		require.NoError(t, err, "Case %d", i)
+		start := time.Now()
+		assert.Equal(t, currCase.want, analyzer.Diff(context.Background(), repository.Definition{FullName: "octocat/Hello-World"}, testInfo("octocat")), "Case %d", i)
+		assert.True(t, time.Since(start) < 3*time.Second, "Case %d: plugin took %v", i, time.Since(start))
+	}
+}
+
+func TestAddPluginAnalyzers(t *testing.T) {
+	builtIn := []spec.Analyzer{spec.NewDescriptionAnalyzer(nil)}
+
+	analyzers, err := spec.AddPluginAnalyzers(builtIn, nil, []spec.Plugin{
+		{Name: "badges", Command: "badges"},
+		{Name: "docs", Command: "docs"},
+	})
+	require.NoError(t, err)
+	var names []string
+	for _, analyzer := range analyzers {
+		names = append(names, analyzer.Name())
+	}
+	assert.Equal(t, []string{builtIn[0].Name(), "badges", "docs"}, names)
+
+	_, err = spec.AddPluginAnalyzers(builtIn, nil, []spec.Plugin{
+		{Name: builtIn[0].Name(), Command: "description"},
+	})
+	assert.EqualError(t, err, "plugin name "+builtIn[0].Name()+" is already used by another analyzer")
+
+	_, err = spec.AddPluginAnalyzers(builtIn, nil, []spec.Plugin{
+		{Name: "badges", Command: "badges"},
+		{Name: "badges", Command: "other-badges"},
+	})
+	assert.EqualError(t, err, "plugin name badges is already used by another analyzer")
+}
+
+func TestPluginAnalyzerFix(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	server := newPRServer(t)
+	defer server.Close()
+
+	analyzer, err := spec.NewPluginAnalyzer(server.client(), spec.Plugin{
+		Name: "badges",
+		Command: writePlugin(t, tmpDir, `cat > /dev/null
+printf '%s' '{"findings": ["README.md: missing badge"], "fix": {"title": "Add badge", "changes": [{"path": "README.md", "content": "[badge]\n"}]}}'`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, analyzer.Fix(context.Background(), repository.Definition{}, testInfo("octocat"), &bytes.Buffer{}))
+
+	require.Len(t, server.trees, 1)
+	assert.Equal(t, []github.TreeEntry{
+		{Path: github.String("README.md"), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String("[badge]\n")},
+	}, server.trees[0].Entries)
+	require.Len(t, server.refs, 1)
+	assert.Equal(t, "refs/heads/cli-plugin-badges", *server.refs[0].Ref)
+	require.Len(t, server.pulls, 1)
+	assert.Equal(t, "Add badge", *server.pulls[0].Title)
+}
+
+func TestPluginAnalyzerFixWithoutPlan(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	analyzer, err := spec.NewPluginAnalyzer(github.NewClient(nil), spec.Plugin{
+		Name:    "badges",
+		Command: writePlugin(t, tmpDir, `cat > /dev/null; echo '{"findings": ["README.md: missing badge"]}'`),
+	})
+	require.NoError(t, err)
+	assert.EqualError(t, analyzer.Fix(context.Background(), repository.Definition{}, testInfo("octocat"), &bytes.Buffer{}), "plugin badges does not provide a fix")
+}
+
+func writePlugin(t *testing.T, dir, script string) string {
+	f, err := ioutil.TempFile(dir, "plugin")
+	require.NoError(t, err)
+	_, err = f.WriteString("#!/bin/sh\n" + script + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Chmod(f.Name(), 0755))
+	path, err := filepath.Abs(f.Name())
+	require.NoError(t, err)
+	return path
+}
+
+func TestPluginAnalyzerPlanRunsOnce(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	runsFile := filepath.Join(tmpDir, "runs")
+	analyzer, err := spec.NewPluginAnalyzer(github.NewClient(nil), spec.Plugin{
+		Name: "badges",
+		Command: writePlugin(t, tmpDir, `cat > /dev/null
+echo run >> `+runsFile+`
+printf '%s' '{"findings": ["README.md: missing badge"], "fix": {"title": "Add badge", "changes": [{"path": "README.md", "content": "[badge]\n"}]}}'`),
+	})
+	require.NoError(t, err)
+
+	repoPlan := spec.NewRepositoryPlan(context.Background(), repository.Definition{}, testInfo("octocat"), []spec.Analyzer{analyzer})
+	require.Len(t, repoPlan.Changes, 1)
+	assert.Equal(t, "badges:\n\tREADME.md: missing badge", repoPlan.Changes[0].Diff)
+	require.Len(t, repoPlan.Changes[0].Files, 1)
+	assert.Equal(t, "README.md", repoPlan.Changes[0].Files[0].Path)
+	changes, err := repoPlan.Changes[0].FileChanges(context.Background(), analyzer.(spec.FileChangeAnalyzer), repository.Definition{}, testInfo("octocat"))
+	require.NoError(t, err)
+	assert.Equal(t, "[badge]\n", changes.Changes[0].Content)
+
+	runs, err := ioutil.ReadFile(runsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "run\n", string(runs))
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+type branchProtectionAnalyzer struct {
+	client *github.Client
+}
+
+func NewBranchProtectionAnalyzer(client *github.Client) Analyzer {
+	return &branchProtectionAnalyzer{
+		client: client,
+	}
+}
+
+func (d *branchProtectionAnalyzer) Name() string {
+	return "branch protection"
+}
+
+func (d *branchProtectionAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	if len(def.BranchProtection) == 0 {
+		return 0
+	}
+	return repository.ProtectionsSection
+}
+
+func (d *branchProtectionAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if len(def.BranchProtection) == 0 || info.IsEmpty {
+		return ""
+	}
+	if info.ProtectionsUnknown {
+		return joinDiff(d.Name(), "unable to determine protection of branches: protection settings could not be read")
+	}
+	var parts []string
+	for _, branch := range info.Branches {
+		want, ok := wantProtection(def, info, branch)
+		if !ok {
+			continue
+		}
+		got, ok := info.Protections[branch]
+		if !ok {
+			parts = append(parts, fmt.Sprintf("%s: not protected", branch))
+			continue
+		}
+		for _, diff := range protectionDiffs(want, got) {
+			parts = append(parts, fmt.Sprintf("%s: %s", branch, diff))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+func (d *branchProtectionAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix updates the protection settings of every branch whose protection differs from its definition.
+func (d *branchProtectionAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	if info.ProtectionsUnknown {
+		return errors.Errorf("protection of branches of %s cannot be determined", *info.FullName)
+	}
+	for _, branch := range info.Branches {
+		want, ok := wantProtection(def, info, branch)
+		if !ok {
+			continue
+		}
+		if got, ok := info.Protections[branch]; ok && len(protectionDiffs(want, got)) == 0 {
+			continue
+		}
+		if err := repository.UpdateBranchProtection(ctx, d.client, &info.Repository, branch, want); err != nil {
+			return errors.Wrapf(err, "failed to fix branch protection")
+		}
+	}
+	return nil
+}
+
+// Returns the first protection in the definition that matches the provided branch. The empty string matches the
+// default branch of the repository and all other values are matched using path.Match. Returns false if no protection
+// in the definition matches the branch.
+func wantProtection(def repository.Definition, info repository.Info, branch string) (repository.BranchProtection, bool) {
+	for _, protection := range def.BranchProtection {
+		if protection.Branch == "" {
+			if info.DefaultBranch != nil && branch == *info.DefaultBranch {
+				return protection, true
+			}
+			continue
+		}
+		if ok, _ := path.Match(protection.Branch, branch); ok {
+			return protection, true
+		}
+	}
+	return repository.BranchProtection{}, false
+}
+
+// Returns a description of every protection setting that differs between want and got. Review settings are only
+// compared if want requires reviews.
+func protectionDiffs(want, got repository.BranchProtection) []string {
+	var diffs []string
+	addDiff := func(name string, want, got interface{}) {
+		if fmt.Sprintf("%v", want) != fmt.Sprintf("%v", got) {
+			diffs = append(diffs, fmt.Sprintf("%s: want %v, got %v", name, want, got))
+		}
+	}
+	addDiff("required_reviews", want.RequiredReviews, got.RequiredReviews)
+	if want.RequiredReviews {
+		addDiff("required_review_count", want.RequiredReviewCount, got.RequiredReviewCount)
+		addDiff("require_code_owner_reviews", want.RequireCodeOwnerReviews, got.RequireCodeOwnerReviews)
+	}
+	addDiff("required_status_checks", sortedCopy(want.RequiredStatusChecks), sortedCopy(got.RequiredStatusChecks))
+	addDiff("strict_status_checks", want.StrictStatusChecks, got.StrictStatusChecks)
+	addDiff("enforce_admins", want.EnforceAdmins, got.EnforceAdmins)
+	addDiff("require_linear_history", want.RequireLinearHistory, got.RequireLinearHistory)
+	addDiff("require_signed_commits", want.RequireSignedCommits, got.RequireSignedCommits)
+	return diffs
+}
+
+func sortedCopy(input []string) []string {
+	output := make([]string, len(input))
+	copy(output, input)
+	sort.Strings(output)
+	return output
+}
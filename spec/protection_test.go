@@ -0,0 +1,140 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestBranchProtectionAnalyzerDiff(t *testing.T) {
+	info := testInfo("octocat")
+	info.Branches = []string{"develop", "master", "release/1.0"}
+	info.Protections = map[string]repository.BranchProtection{
+		"master": {
+			Branch:              "master",
+			RequiredReviews:     true,
+			RequiredReviewCount: 2,
+			EnforceAdmins:       true,
+		},
+	}
+	for i, currCase := range []struct {
+		def  repository.Definition
+		want string
+	}{
+		{
+			def:  repository.Definition{},
+			want: "",
+		},
+		{
+			def: repository.Definition{BranchProtection: []repository.BranchProtection{
+				{RequiredReviews: true, RequiredReviewCount: 2, EnforceAdmins: true},
+			}},
+			want: "",
+		},
+		{
+			def: repository.Definition{BranchProtection: []repository.BranchProtection{
+				{RequiredReviews: true, RequiredReviewCount: 1, RequiredStatusChecks: []string{"ci"}},
+				{Branch: "release/*", RequireSignedCommits: true},
+			}},
+			want: "branch protection:\n" +
+				"\tmaster: required_review_count: want 1, got 2\n" +
+				"\tmaster: required_status_checks: want [ci], got []\n" +
+				"\tmaster: enforce_admins: want false, got true\n" +
+				"\trelease/1.0: not protected",
+		},
+	} {
+		got := spec.NewBranchProtectionAnalyzer(nil).Diff(context.Background(), currCase.def, info)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestBranchProtectionAnalyzerFix(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodyBytes, err := json.Marshal(body)
+		require.NoError(t, err)
+		got = append(got, r.Method+" "+r.URL.String()+" "+string(bodyBytes))
+
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			_, err := w.Write([]byte(`{"message": "Branch not protected"}`))
+			require.NoError(t, err)
+			return
+		}
+		_, err = w.Write([]byte("{}"))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	info := testInfo("octocat")
+	info.Branches = []string{"develop", "master", "release/1.0"}
+	info.Protections = map[string]repository.BranchProtection{
+		"master": {
+			Branch:              "master",
+			RequiredReviews:     true,
+			RequiredReviewCount: 2,
+			EnforceAdmins:       true,
+		},
+	}
+	def := repository.Definition{BranchProtection: []repository.BranchProtection{
+		{RequiredReviews: true, RequiredReviewCount: 2, EnforceAdmins: true},
+		{Branch: "release/*", RequiredStatusChecks: []string{"ci"}, RequireSignedCommits: true},
+	}}
+	require.NoError(t, spec.NewBranchProtectionAnalyzer(client).Fix(context.Background(), def, info, &bytes.Buffer{}))
+	assert.Equal(t, []string{
+		`GET /repos/octocat/Hello-World/branches/release%2F1.0/protection null`,
+		`PUT /repos/octocat/Hello-World/branches/release%2F1.0/protection {"enforce_admins":false,"required_linear_history":false,"required_pull_request_reviews":null,"required_status_checks":{"contexts":["ci"],"strict":false},"restrictions":null}`,
+		`POST /repos/octocat/Hello-World/branches/release%2F1.0/protection/required_signatures null`,
+	}, got)
+}
+
+func TestBranchProtectionAnalyzerFixEscapesBranch(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.EscapedPath())
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			_, err := w.Write([]byte(`{"message": "Branch not protected"}`))
+			require.NoError(t, err)
+			return
+		}
+		_, err := w.Write([]byte("{}"))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	info := testInfo("octocat")
+	info.Branches = []string{"fix#1?x=%"}
+	def := repository.Definition{BranchProtection: []repository.BranchProtection{
+		{Branch: "fix*", EnforceAdmins: true},
+	}}
+	require.NoError(t, spec.NewBranchProtectionAnalyzer(client).Fix(context.Background(), def, info, &bytes.Buffer{}))
+	assert.Equal(t, []string{
+		`GET /repos/octocat/Hello-World/branches/fix%231%3Fx=%25/protection`,
+		`PUT /repos/octocat/Hello-World/branches/fix%231%3Fx=%25/protection`,
+		`DELETE /repos/octocat/Hello-World/branches/fix%231%3Fx=%25/protection/required_signatures`,
+	}, got)
+}
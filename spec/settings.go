@@ -0,0 +1,100 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+// settingsFields are the fields of repository.Settings in the order in which they are reported.
+var settingsFields = []struct {
+	name  string
+	field func(s *repository.Settings) **bool
+}{
+	{"private", func(s *repository.Settings) **bool { return &s.Private }},
+	{"has_issues", func(s *repository.Settings) **bool { return &s.HasIssues }},
+	{"has_wiki", func(s *repository.Settings) **bool { return &s.HasWiki }},
+	{"has_projects", func(s *repository.Settings) **bool { return &s.HasProjects }},
+	{"allow_merge_commit", func(s *repository.Settings) **bool { return &s.AllowMergeCommit }},
+	{"allow_squash_merge", func(s *repository.Settings) **bool { return &s.AllowSquashMerge }},
+	{"allow_rebase_merge", func(s *repository.Settings) **bool { return &s.AllowRebaseMerge }},
+	{"delete_branch_on_merge", func(s *repository.Settings) **bool { return &s.DeleteBranchOnMerge }},
+	{"is_template", func(s *repository.Settings) **bool { return &s.IsTemplate }},
+}
+
+type settingsAnalyzer struct {
+	client *github.Client
+}
+
+func NewSettingsAnalyzer(client *github.Client) Analyzer {
+	return &settingsAnalyzer{
+		client: client,
+	}
+}
+
+func (d *settingsAnalyzer) Name() string {
+	return "settings"
+}
+
+func (d *settingsAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	if def.Settings == nil {
+		return 0
+	}
+	return repository.SettingsSection
+}
+
+func (d *settingsAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if def.Settings == nil {
+		return ""
+	}
+	var parts []string
+	for _, currField := range settingsFields {
+		want := *currField.field(def.Settings)
+		got := *currField.field(&info.Settings)
+		if want == nil || (got != nil && *want == *got) {
+			continue
+		}
+		gotStr := "unknown"
+		if got != nil {
+			gotStr = fmt.Sprintf("%v", *got)
+		}
+		parts = append(parts, fmt.Sprintf("%s: want %v, got %s", currField.name, *want, gotStr))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+func (d *settingsAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix edits the repository so that the settings that differ from the definition match the definition.
+func (d *settingsAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	if def.Settings == nil {
+		return nil
+	}
+	var edit repository.Settings
+	for _, currField := range settingsFields {
+		want := *currField.field(def.Settings)
+		got := *currField.field(&info.Settings)
+		if want == nil || (got != nil && *want == *got) {
+			continue
+		}
+		*currField.field(&edit) = want
+	}
+	if err := repository.EditSettings(ctx, d.client, &info.Repository, edit); err != nil {
+		return errors.Wrapf(err, "failed to fix settings")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestSettingsAnalyzerDiff(t *testing.T) {
+	info := testInfo("octocat")
+	info.Settings = repository.Settings{
+		Private:          github.Bool(false),
+		HasIssues:        github.Bool(true),
+		AllowRebaseMerge: github.Bool(true),
+	}
+	for i, currCase := range []struct {
+		def  repository.Definition
+		want string
+	}{
+		{
+			def:  repository.Definition{},
+			want: "",
+		},
+		{
+			def: repository.Definition{Settings: &repository.Settings{
+				Private:   github.Bool(false),
+				HasIssues: github.Bool(true),
+			}},
+			want: "",
+		},
+		{
+			def: repository.Definition{Settings: &repository.Settings{
+				Private:             github.Bool(true),
+				HasIssues:           github.Bool(true),
+				AllowRebaseMerge:    github.Bool(false),
+				DeleteBranchOnMerge: github.Bool(true),
+			}},
+			want: "settings:\n\tprivate: want true, got false\n\tallow_rebase_merge: want false, got true\n\tdelete_branch_on_merge: want true, got unknown",
+		},
+	} {
+		got := spec.NewSettingsAnalyzer(nil).Diff(context.Background(), currCase.def, info)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestSettingsAnalyzerFix(t *testing.T) {
+	var got map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PATCH", r.Method)
+		assert.Equal(t, "/repos/octocat/Hello-World", r.URL.String())
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, err := w.Write([]byte("{}"))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	info := testInfo("octocat")
+	info.Settings = repository.Settings{
+		Private:          github.Bool(false),
+		HasIssues:        github.Bool(true),
+		AllowRebaseMerge: github.Bool(true),
+	}
+	def := repository.Definition{Settings: &repository.Settings{
+		Private:          github.Bool(true),
+		HasIssues:        github.Bool(true),
+		AllowRebaseMerge: github.Bool(false),
+	}}
+	require.NoError(t, spec.NewSettingsAnalyzer(client).Fix(context.Background(), def, info, &bytes.Buffer{}))
+	assert.Equal(t, map[string]interface{}{
+		"name":               "Hello-World",
+		"private":            true,
+		"allow_rebase_merge": false,
+	}, got)
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/nmiyake/ghcli/repository"
+)
+
+type teamsAnalyzer struct {
+	client       *github.Client
+	teamIDsMutex sync.Mutex
+	teamIDs      map[string]map[string]int // map from organization to the map from team slug to team ID for the organization
+}
+
+// NewTeamsAnalyzer returns an analyzer that verifies that the teams with access to a repository owned by an organization
+// and their permissions match the definition. Fix grants teams that are missing or have the wrong permission the
+// permission in the definition. Teams that have access to the repository but are not in the definition are reported
+// but are not removed by Fix.
+func NewTeamsAnalyzer(client *github.Client) Analyzer {
+	return &teamsAnalyzer{
+		client:  client,
+		teamIDs: make(map[string]map[string]int),
+	}
+}
+
+func (d *teamsAnalyzer) Name() string {
+	return "teams"
+}
+
+func (d *teamsAnalyzer) InfoSections(def repository.Definition) repository.InfoSections {
+	if def.Teams == nil {
+		return 0
+	}
+	return repository.TeamsSection
+}
+
+func (d *teamsAnalyzer) Diff(ctx context.Context, def repository.Definition, info repository.Info) string {
+	if def.Teams == nil || !repository.IsOrgRepo(&info.Repository) {
+		return ""
+	}
+	if info.TeamsUnknown {
+		return joinDiff(d.Name(), "unable to determine teams: teams of repository could not be listed")
+	}
+	var parts []string
+	for _, slug := range sortedKeys(def.Teams) {
+		want := def.Teams[slug]
+		got, ok := info.Teams[slug]
+		switch {
+		case !ok:
+			parts = append(parts, fmt.Sprintf("missing: %s (%s)", slug, want))
+		case got != want:
+			parts = append(parts, fmt.Sprintf("permission: %s: want %s, got %s", slug, want, got))
+		}
+	}
+	for _, slug := range sortedKeys(info.Teams) {
+		if _, ok := def.Teams[slug]; !ok {
+			parts = append(parts, fmt.Sprintf("extra: %s (%s)", slug, info.Teams[slug]))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return joinDiff(d.Name(), parts...)
+}
+
+func (d *teamsAnalyzer) CanFix() bool {
+	return d.client != nil
+}
+
+// Fix grants every team in the definition that is missing or has the wrong permission the permission in the definition.
+func (d *teamsAnalyzer) Fix(ctx context.Context, def repository.Definition, info repository.Info, stdout io.Writer) error {
+	if def.Teams == nil || !repository.IsOrgRepo(&info.Repository) {
+		return nil
+	}
+	if info.TeamsUnknown {
+		return errors.Errorf("teams of %s cannot be determined", *info.FullName)
+	}
+	org := *info.Owner.Login
+	for _, slug := range sortedKeys(def.Teams) {
+		if got, ok := info.Teams[slug]; ok && got == def.Teams[slug] {
+			continue
+		}
+		teamID, err := d.teamID(ctx, org, slug)
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := repository.Do(ctx, d.client, "PUT", fmt.Sprintf("teams/%v/repos/%v/%v", teamID, org, *info.Name), &github.OrganizationAddTeamRepoOptions{
+			Permission: def.Teams[slug],
+		}, nil); err != nil {
+			return errors.Wrapf(err, "failed to grant %s permission to team %s for %s", def.Teams[slug], slug, *info.FullName)
+		}
+	}
+	return nil
+}
+
+// Returns the ID of the team with the provided slug in the provided organization. The teams of an organization are
+// retrieved once and cached.
+func (d *teamsAnalyzer) teamID(ctx context.Context, org, slug string) (int, error) {
+	d.teamIDsMutex.Lock()
+	defer d.teamIDsMutex.Unlock()
+
+	ids, ok := d.teamIDs[org]
+	if !ok {
+		var err error
+		if ids, err = repository.GetTeamIDs(ctx, d.client, org); err != nil {
+			return 0, err
+		}
+		d.teamIDs[org] = ids
+	}
+	id, ok := ids[slug]
+	if !ok {
+		return 0, errors.Errorf("team %s does not exist in organization %s", slug, org)
+	}
+	return id, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
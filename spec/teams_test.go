@@ -0,0 +1,104 @@
+// Copyright 2016 Nick Miyake. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nmiyake/ghcli/internal/testutil"
+	"github.com/nmiyake/ghcli/repository"
+	"github.com/nmiyake/ghcli/spec"
+)
+
+func TestTeamsAnalyzerDiff(t *testing.T) {
+	for i, currCase := range []struct {
+		def       repository.Definition
+		ownerType string
+		want      string
+	}{
+		{
+			def:       repository.Definition{},
+			ownerType: "Organization",
+			want:      "",
+		},
+		{
+			def:       repository.Definition{Teams: map[string]string{"core": "admin"}},
+			ownerType: "User",
+			want:      "",
+		},
+		{
+			def:       repository.Definition{Teams: map[string]string{"core": "admin", "docs": "pull"}},
+			ownerType: "Organization",
+			want:      "",
+		},
+		{
+			def:       repository.Definition{Teams: map[string]string{"core": "admin", "docs": "push", "ops": "pull"}},
+			ownerType: "Organization",
+			want:      "teams:\n\tpermission: docs: want push, got pull\n\tmissing: ops (pull)",
+		},
+		{
+			def:       repository.Definition{Teams: map[string]string{"core": "admin"}},
+			ownerType: "Organization",
+			want:      "teams:\n\textra: docs (pull)",
+		},
+	} {
+		info := testInfo("octo-org")
+		info.Owner.Type = github.String(currCase.ownerType)
+		info.Teams = map[string]string{
+			"core": "admin",
+			"docs": "pull",
+		}
+		got := spec.NewTeamsAnalyzer(nil).Diff(context.Background(), currCase.def, info)
+		assert.Equal(t, currCase.want, got, "Case %d", i)
+	}
+}
+
+func TestTeamsAnalyzerFix(t *testing.T) {
+	var got []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/octo-org/teams", func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.String())
+		testutil.WriteJSON(t, w, []github.Team{
+			{ID: github.Int(1), Slug: github.String("core")},
+			{ID: github.Int(2), Slug: github.String("docs")},
+			{ID: github.Int(3), Slug: github.String("ops")},
+		})
+	})
+	mux.HandleFunc("/teams/", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		got = append(got, r.Method+" "+r.URL.String()+" "+body["permission"].(string))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	info := testInfo("octo-org")
+	info.Owner.Type = github.String("Organization")
+	info.Teams = map[string]string{
+		"core": "admin",
+		"docs": "pull",
+	}
+	def := repository.Definition{Teams: map[string]string{"core": "admin", "docs": "push", "ops": "pull"}}
+	require.NoError(t, spec.NewTeamsAnalyzer(client).Fix(context.Background(), def, info, &bytes.Buffer{}))
+	assert.Equal(t, []string{
+		"GET /orgs/octo-org/teams?page=1",
+		"PUT /teams/2/repos/octo-org/Hello-World push",
+		"PUT /teams/3/repos/octo-org/Hello-World pull",
+	}, got)
+}